@@ -0,0 +1,706 @@
+// Typed values of the registers the driver writes and tests, datasheet 9.3.
+// Encode returns the register byte, the Decode functions split it into the fields.
+// The masks of the fields are declared here only, the Registers table uses them.
+
+package mfrc522
+
+// A typed register value, see writeReg
+type RegisterValue interface {
+	Address() byte
+	Encode() byte
+}
+
+func (r *MFRC522) writeReg(value RegisterValue) error {
+	return r.writeRegister(value.Address(), value.Encode())
+}
+
+// Sets the bits of value that are 1, the other bits of the register are kept
+func (r *MFRC522) setBits(value RegisterValue) error {
+	return r.setRegisterBitMask(value.Address(), value.Encode())
+}
+
+// Clears the bits of value that are 1, the other bits of the register are kept
+func (r *MFRC522) clearBits(value RegisterValue) error {
+	return r.clearRegisterBitMask(value.Address(), value.Encode())
+}
+
+func bitIf(set bool, f RegisterField) byte {
+	if set {
+		return f.Mask
+	}
+	return 0
+}
+
+func isSet(value byte, f RegisterField) bool {
+	return value&f.Mask != 0
+}
+
+var (
+	fieldRcvOff    = regField("RcvOff", 0x20)
+	fieldPowerDown = regField("PowerDown", 0x10)
+	fieldCommand   = regField("Command", 0x0F)
+)
+
+// CommandReg
+type Command struct {
+	RcvOff    bool // analog part of the receiver is switched off
+	PowerDown bool // soft power-down
+	Command   byte // PCD_Idle, PCD_Transceive, ...
+}
+
+func (Command) Address() byte { return CommandReg }
+
+func (c Command) Encode() byte {
+	return bitIf(c.RcvOff, fieldRcvOff) | bitIf(c.PowerDown, fieldPowerDown) | fieldCommand.Set(c.Command)
+}
+
+func DecodeCommand(value byte) Command {
+	return Command{
+		RcvOff: isSet(value, fieldRcvOff), PowerDown: isSet(value, fieldPowerDown), Command: fieldCommand.Get(value),
+	}
+}
+
+var (
+	fieldIRqInv     = regField("IRqInv", 0x80)
+	fieldTxIEn      = regField("TxIEn", 0x40)
+	fieldRxIEn      = regField("RxIEn", 0x20)
+	fieldIdleIEn    = regField("IdleIEn", 0x10)
+	fieldHiAlertIEn = regField("HiAlertIEn", 0x08)
+	fieldLoAlertIEn = regField("LoAlertIEn", 0x04)
+	fieldErrIEn     = regField("ErrIEn", 0x02)
+	fieldTimerIEn   = regField("TimerIEn", 0x01)
+)
+
+// ComIEnReg
+type ComIEn struct {
+	IRqInv     bool // IRQ pin is inverted
+	TxIEn      bool
+	RxIEn      bool
+	IdleIEn    bool
+	HiAlertIEn bool
+	LoAlertIEn bool
+	ErrIEn     bool
+	TimerIEn   bool
+}
+
+func (ComIEn) Address() byte { return ComIEnReg }
+
+func (c ComIEn) Encode() byte {
+	return bitIf(c.IRqInv, fieldIRqInv) | bitIf(c.TxIEn, fieldTxIEn) | bitIf(c.RxIEn, fieldRxIEn) |
+		bitIf(c.IdleIEn, fieldIdleIEn) | bitIf(c.HiAlertIEn, fieldHiAlertIEn) | bitIf(c.LoAlertIEn, fieldLoAlertIEn) |
+		bitIf(c.ErrIEn, fieldErrIEn) | bitIf(c.TimerIEn, fieldTimerIEn)
+}
+
+func DecodeComIEn(value byte) ComIEn {
+	return ComIEn{
+		IRqInv: isSet(value, fieldIRqInv), TxIEn: isSet(value, fieldTxIEn), RxIEn: isSet(value, fieldRxIEn),
+		IdleIEn: isSet(value, fieldIdleIEn), HiAlertIEn: isSet(value, fieldHiAlertIEn), LoAlertIEn: isSet(value, fieldLoAlertIEn),
+		ErrIEn: isSet(value, fieldErrIEn), TimerIEn: isSet(value, fieldTimerIEn),
+	}
+}
+
+var (
+	fieldSet1       = regField("Set1", 0x80)
+	fieldTxIRq      = regField("TxIRq", 0x40)
+	fieldRxIRq      = regField("RxIRq", 0x20)
+	fieldIdleIRq    = regField("IdleIRq", 0x10)
+	fieldHiAlertIRq = regField("HiAlertIRq", 0x08)
+	fieldLoAlertIRq = regField("LoAlertIRq", 0x04)
+	fieldErrIRq     = regField("ErrIRq", 0x02)
+	fieldTimerIRq   = regField("TimerIRq", 0x01)
+)
+
+// ComIrqReg. Written with Set1 the marked bits are set, without it they are cleared.
+type ComIrq struct {
+	Set1       bool
+	TxIRq      bool // the last bit of the data is transmitted
+	RxIRq      bool // the end of the received data stream
+	IdleIRq    bool // a command terminates
+	HiAlertIRq bool
+	LoAlertIRq bool
+	ErrIRq     bool // a bit in ErrorReg is set
+	TimerIRq   bool // the timer reached zero
+}
+
+// All interrupt request bits of ComIrqReg, written without Set1 it clears them
+var ComIrqAll = ComIrq{TxIRq: true, RxIRq: true, IdleIRq: true, HiAlertIRq: true, LoAlertIRq: true, ErrIRq: true, TimerIRq: true}
+
+func (ComIrq) Address() byte { return ComIrqReg }
+
+func (c ComIrq) Encode() byte {
+	return bitIf(c.Set1, fieldSet1) | bitIf(c.TxIRq, fieldTxIRq) | bitIf(c.RxIRq, fieldRxIRq) |
+		bitIf(c.IdleIRq, fieldIdleIRq) | bitIf(c.HiAlertIRq, fieldHiAlertIRq) | bitIf(c.LoAlertIRq, fieldLoAlertIRq) |
+		bitIf(c.ErrIRq, fieldErrIRq) | bitIf(c.TimerIRq, fieldTimerIRq)
+}
+
+func DecodeComIrq(value byte) ComIrq {
+	return ComIrq{
+		Set1: isSet(value, fieldSet1), TxIRq: isSet(value, fieldTxIRq), RxIRq: isSet(value, fieldRxIRq),
+		IdleIRq: isSet(value, fieldIdleIRq), HiAlertIRq: isSet(value, fieldHiAlertIRq), LoAlertIRq: isSet(value, fieldLoAlertIRq),
+		ErrIRq: isSet(value, fieldErrIRq), TimerIRq: isSet(value, fieldTimerIRq),
+	}
+}
+
+var (
+	fieldSet2       = regField("Set2", 0x80)
+	fieldMfinActIRq = regField("MfinActIRq", 0x10)
+	fieldCRCIRq     = regField("CRCIRq", 0x04)
+)
+
+// DivIrqReg
+type DivIrq struct {
+	Set2       bool
+	MfinActIRq bool
+	CRCIRq     bool // the CRC command is active and all data are processed
+}
+
+func (DivIrq) Address() byte { return DivIrqReg }
+
+func (d DivIrq) Encode() byte {
+	return bitIf(d.Set2, fieldSet2) | bitIf(d.MfinActIRq, fieldMfinActIRq) | bitIf(d.CRCIRq, fieldCRCIRq)
+}
+
+func DecodeDivIrq(value byte) DivIrq {
+	return DivIrq{
+		Set2: isSet(value, fieldSet2), MfinActIRq: isSet(value, fieldMfinActIRq), CRCIRq: isSet(value, fieldCRCIRq),
+	}
+}
+
+var (
+	fieldWrErr       = regField("WrErr", 0x80)
+	fieldTempErr     = regField("TempErr", 0x40)
+	fieldBufferOvfl  = regField("BufferOvfl", 0x10)
+	fieldCollErr     = regField("CollErr", 0x08)
+	fieldCRCErr      = regField("CRCErr", 0x04)
+	fieldParityErr   = regField("ParityErr", 0x02)
+	fieldProtocolErr = regField("ProtocolErr", 0x01)
+)
+
+// ErrorReg, read only
+type ErrorFlags struct {
+	WrErr       bool
+	TempErr     bool
+	BufferOvfl  bool
+	CollErr     bool
+	CRCErr      bool
+	ParityErr   bool
+	ProtocolErr bool
+}
+
+func (ErrorFlags) Address() byte { return ErrorReg }
+
+func (e ErrorFlags) Encode() byte {
+	return bitIf(e.WrErr, fieldWrErr) | bitIf(e.TempErr, fieldTempErr) | bitIf(e.BufferOvfl, fieldBufferOvfl) |
+		bitIf(e.CollErr, fieldCollErr) | bitIf(e.CRCErr, fieldCRCErr) | bitIf(e.ParityErr, fieldParityErr) |
+		bitIf(e.ProtocolErr, fieldProtocolErr)
+}
+
+func DecodeErrorFlags(value byte) ErrorFlags {
+	return ErrorFlags{
+		WrErr: isSet(value, fieldWrErr), TempErr: isSet(value, fieldTempErr), BufferOvfl: isSet(value, fieldBufferOvfl),
+		CollErr: isSet(value, fieldCollErr), CRCErr: isSet(value, fieldCRCErr), ParityErr: isSet(value, fieldParityErr),
+		ProtocolErr: isSet(value, fieldProtocolErr),
+	}
+}
+
+var (
+	fieldCRCOk    = regField("CRCOk", 0x40)
+	fieldCRCReady = regField("CRCReady", 0x20)
+	fieldIRq      = regField("IRq", 0x10)
+	fieldTRunning = regField("TRunning", 0x08)
+	fieldHiAlert  = regField("HiAlert", 0x02)
+	fieldLoAlert  = regField("LoAlert", 0x01)
+)
+
+// Status1Reg, read only
+type Status1 struct {
+	CRCOk    bool
+	CRCReady bool
+	IRq      bool // an enabled interrupt request is pending
+	TRunning bool
+	HiAlert  bool
+	LoAlert  bool
+}
+
+func (Status1) Address() byte { return Status1Reg }
+
+func (s Status1) Encode() byte {
+	return bitIf(s.CRCOk, fieldCRCOk) | bitIf(s.CRCReady, fieldCRCReady) | bitIf(s.IRq, fieldIRq) |
+		bitIf(s.TRunning, fieldTRunning) | bitIf(s.HiAlert, fieldHiAlert) | bitIf(s.LoAlert, fieldLoAlert)
+}
+
+func DecodeStatus1(value byte) Status1 {
+	return Status1{
+		CRCOk: isSet(value, fieldCRCOk), CRCReady: isSet(value, fieldCRCReady), IRq: isSet(value, fieldIRq),
+		TRunning: isSet(value, fieldTRunning), HiAlert: isSet(value, fieldHiAlert), LoAlert: isSet(value, fieldLoAlert),
+	}
+}
+
+var (
+	fieldTempSensClear = regField("TempSensClear", 0x80)
+	fieldI2CForceHS    = regField("I2CForceHS", 0x40)
+	fieldMFCrypto1On   = regField("MFCrypto1On", 0x08)
+	fieldModemState    = regField("ModemState", 0x07)
+)
+
+// Status2Reg
+type Status2 struct {
+	TempSensClear bool
+	I2CForceHS    bool
+	MFCrypto1On   bool // set by a successful MFAuthent, cleared by software
+	ModemState    byte
+}
+
+func (Status2) Address() byte { return Status2Reg }
+
+func (s Status2) Encode() byte {
+	return bitIf(s.TempSensClear, fieldTempSensClear) | bitIf(s.I2CForceHS, fieldI2CForceHS) | bitIf(s.MFCrypto1On, fieldMFCrypto1On) |
+		fieldModemState.Set(s.ModemState)
+}
+
+func DecodeStatus2(value byte) Status2 {
+	return Status2{
+		TempSensClear: isSet(value, fieldTempSensClear), I2CForceHS: isSet(value, fieldI2CForceHS), MFCrypto1On: isSet(value, fieldMFCrypto1On),
+		ModemState: fieldModemState.Get(value),
+	}
+}
+
+var (
+	fieldFlushBuffer = regField("FlushBuffer", 0x80)
+	fieldFIFOLevel   = regField("FIFOLevel", 0x7F)
+)
+
+// FIFOLevelReg
+type FIFOLevel struct {
+	FlushBuffer bool
+	FIFOLevel   byte // bytes in the FIFO, read only
+}
+
+func (FIFOLevel) Address() byte { return FIFOLevelReg }
+
+func (f FIFOLevel) Encode() byte {
+	return bitIf(f.FlushBuffer, fieldFlushBuffer) | fieldFIFOLevel.Set(f.FIFOLevel)
+}
+
+func DecodeFIFOLevel(value byte) FIFOLevel {
+	return FIFOLevel{FlushBuffer: isSet(value, fieldFlushBuffer), FIFOLevel: fieldFIFOLevel.Get(value)}
+}
+
+var (
+	fieldTStopNow   = regField("TStopNow", 0x80)
+	fieldTStartNow  = regField("TStartNow", 0x40)
+	fieldRxLastBits = regField("RxLastBits", 0x07)
+)
+
+// ControlReg
+type Control struct {
+	TStopNow   bool
+	TStartNow  bool
+	RxLastBits byte // valid bits of the last received byte, 0 is a whole byte
+}
+
+func (Control) Address() byte { return ControlReg }
+
+func (c Control) Encode() byte {
+	return bitIf(c.TStopNow, fieldTStopNow) | bitIf(c.TStartNow, fieldTStartNow) | fieldRxLastBits.Set(c.RxLastBits)
+}
+
+func DecodeControl(value byte) Control {
+	return Control{
+		TStopNow: isSet(value, fieldTStopNow), TStartNow: isSet(value, fieldTStartNow), RxLastBits: fieldRxLastBits.Get(value),
+	}
+}
+
+var (
+	fieldStartSend  = regField("StartSend", 0x80)
+	fieldRxAlign    = regField("RxAlign", 0x70)
+	fieldTxLastBits = regField("TxLastBits", 0x07)
+)
+
+// BitFramingReg
+type BitFraming struct {
+	StartSend  bool // starts the transmission of Transceive
+	RxAlign    byte // position of the first received bit in the first byte
+	TxLastBits byte // bits of the last transmitted byte, 0 is a whole byte
+}
+
+func (BitFraming) Address() byte { return BitFramingReg }
+
+func (b BitFraming) Encode() byte {
+	return bitIf(b.StartSend, fieldStartSend) | fieldRxAlign.Set(b.RxAlign) | fieldTxLastBits.Set(b.TxLastBits)
+}
+
+func DecodeBitFraming(value byte) BitFraming {
+	return BitFraming{
+		StartSend: isSet(value, fieldStartSend), RxAlign: fieldRxAlign.Get(value), TxLastBits: fieldTxLastBits.Get(value),
+	}
+}
+
+var (
+	fieldValuesAfterColl = regField("ValuesAfterColl", 0x80)
+	fieldCollPosNotValid = regField("CollPosNotValid", 0x20)
+	fieldCollPos         = regField("CollPos", 0x1F)
+)
+
+// CollReg
+type Coll struct {
+	ValuesAfterColl bool // 0: the received bits are cleared after a collision
+	CollPosNotValid bool
+	CollPos         byte // bit of the first collision, 0 is the 32nd bit
+}
+
+func (Coll) Address() byte { return CollReg }
+
+func (c Coll) Encode() byte {
+	return bitIf(c.ValuesAfterColl, fieldValuesAfterColl) | bitIf(c.CollPosNotValid, fieldCollPosNotValid) | fieldCollPos.Set(c.CollPos)
+}
+
+func DecodeColl(value byte) Coll {
+	return Coll{
+		ValuesAfterColl: isSet(value, fieldValuesAfterColl), CollPosNotValid: isSet(value, fieldCollPosNotValid), CollPos: fieldCollPos.Get(value),
+	}
+}
+
+var (
+	fieldMSBFirst  = regField("MSBFirst", 0x80)
+	fieldTxWaitRF  = regField("TxWaitRF", 0x20)
+	fieldPolMFin   = regField("PolMFin", 0x08)
+	fieldCRCPreset = regField("CRCPreset", 0x03)
+)
+
+// Bits 4 and 2 of ModeReg are reserved, they read 1 after reset
+const modeReserved = 0x14
+
+// ModeReg
+type Mode struct {
+	MSBFirst  bool
+	TxWaitRF  bool
+	PolMFin   bool
+	CRCPreset byte // 0: 0000, 1: 6363, 2: A671, 3: FFFF
+}
+
+func (Mode) Address() byte { return ModeReg }
+
+func (m Mode) Encode() byte {
+	return bitIf(m.MSBFirst, fieldMSBFirst) | bitIf(m.TxWaitRF, fieldTxWaitRF) | bitIf(m.PolMFin, fieldPolMFin) |
+		fieldCRCPreset.Set(m.CRCPreset) | modeReserved
+}
+
+func DecodeMode(value byte) Mode {
+	return Mode{
+		MSBFirst: isSet(value, fieldMSBFirst), TxWaitRF: isSet(value, fieldTxWaitRF), PolMFin: isSet(value, fieldPolMFin),
+		CRCPreset: fieldCRCPreset.Get(value),
+	}
+}
+
+var (
+	fieldTxCRCEn = regField("TxCRCEn", 0x80)
+	fieldTxSpeed = regField("TxSpeed", 0x70)
+	fieldInvMod  = regField("InvMod", 0x08)
+)
+
+// TxModeReg
+type TxMode struct {
+	TxCRCEn bool
+	TxSpeed BitRate
+	InvMod  bool
+}
+
+func (TxMode) Address() byte { return TxModeReg }
+
+func (t TxMode) Encode() byte {
+	return bitIf(t.TxCRCEn, fieldTxCRCEn) | fieldTxSpeed.Set(byte(t.TxSpeed)) | bitIf(t.InvMod, fieldInvMod)
+}
+
+func DecodeTxMode(value byte) TxMode {
+	return TxMode{
+		TxCRCEn: isSet(value, fieldTxCRCEn), TxSpeed: BitRate(fieldTxSpeed.Get(value)), InvMod: isSet(value, fieldInvMod),
+	}
+}
+
+var (
+	fieldRxCRCEn    = regField("RxCRCEn", 0x80)
+	fieldRxSpeed    = regField("RxSpeed", 0x70)
+	fieldRxNoErr    = regField("RxNoErr", 0x08)
+	fieldRxMultiple = regField("RxMultiple", 0x04)
+)
+
+// RxModeReg
+type RxMode struct {
+	RxCRCEn    bool
+	RxSpeed    BitRate
+	RxNoErr    bool
+	RxMultiple bool
+}
+
+func (RxMode) Address() byte { return RxModeReg }
+
+func (r RxMode) Encode() byte {
+	return bitIf(r.RxCRCEn, fieldRxCRCEn) | fieldRxSpeed.Set(byte(r.RxSpeed)) | bitIf(r.RxNoErr, fieldRxNoErr) |
+		bitIf(r.RxMultiple, fieldRxMultiple)
+}
+
+func DecodeRxMode(value byte) RxMode {
+	return RxMode{
+		RxCRCEn: isSet(value, fieldRxCRCEn), RxSpeed: BitRate(fieldRxSpeed.Get(value)), RxNoErr: isSet(value, fieldRxNoErr),
+		RxMultiple: isSet(value, fieldRxMultiple),
+	}
+}
+
+var (
+	fieldInvTx2RFOn  = regField("InvTx2RFOn", 0x80)
+	fieldInvTx1RFOn  = regField("InvTx1RFOn", 0x40)
+	fieldInvTx2RFOff = regField("InvTx2RFOff", 0x20)
+	fieldInvTx1RFOff = regField("InvTx1RFOff", 0x10)
+	fieldTx2CW       = regField("Tx2CW", 0x08)
+	fieldTx2RFEn     = regField("Tx2RFEn", 0x02)
+	fieldTx1RFEn     = regField("Tx1RFEn", 0x01)
+)
+
+// TxControlReg
+type TxControl struct {
+	InvTx2RFOn  bool
+	InvTx1RFOn  bool
+	InvTx2RFOff bool
+	InvTx1RFOff bool
+	Tx2CW       bool
+	Tx2RFEn     bool // TX2 drives the carrier
+	Tx1RFEn     bool // TX1 drives the carrier
+}
+
+func (TxControl) Address() byte { return TxControlReg }
+
+func (t TxControl) Encode() byte {
+	return bitIf(t.InvTx2RFOn, fieldInvTx2RFOn) | bitIf(t.InvTx1RFOn, fieldInvTx1RFOn) | bitIf(t.InvTx2RFOff, fieldInvTx2RFOff) |
+		bitIf(t.InvTx1RFOff, fieldInvTx1RFOff) | bitIf(t.Tx2CW, fieldTx2CW) | bitIf(t.Tx2RFEn, fieldTx2RFEn) |
+		bitIf(t.Tx1RFEn, fieldTx1RFEn)
+}
+
+func DecodeTxControl(value byte) TxControl {
+	return TxControl{
+		InvTx2RFOn: isSet(value, fieldInvTx2RFOn), InvTx1RFOn: isSet(value, fieldInvTx1RFOn), InvTx2RFOff: isSet(value, fieldInvTx2RFOff),
+		InvTx1RFOff: isSet(value, fieldInvTx1RFOff), Tx2CW: isSet(value, fieldTx2CW), Tx2RFEn: isSet(value, fieldTx2RFEn),
+		Tx1RFEn: isSet(value, fieldTx1RFEn),
+	}
+}
+
+var (
+	fieldForce100ASK = regField("Force100ASK", 0x40)
+)
+
+// TxASKReg
+type TxASK struct {
+	Force100ASK bool // 100 % ASK independent of ModGsPReg
+}
+
+func (TxASK) Address() byte { return TxASKReg }
+
+func (t TxASK) Encode() byte {
+	return bitIf(t.Force100ASK, fieldForce100ASK)
+}
+
+func DecodeTxASK(value byte) TxASK {
+	return TxASK{Force100ASK: isSet(value, fieldForce100ASK)}
+}
+
+var (
+	fieldParityDisable = regField("ParityDisable", 0x10)
+)
+
+// MfRxReg
+type MfRx struct {
+	ParityDisable bool // no parity bits are generated or checked, see RawConfig
+}
+
+func (MfRx) Address() byte { return MfRxReg }
+
+func (m MfRx) Encode() byte {
+	return bitIf(m.ParityDisable, fieldParityDisable)
+}
+
+func DecodeMfRx(value byte) MfRx {
+	return MfRx{ParityDisable: isSet(value, fieldParityDisable)}
+}
+
+var (
+	fieldTAuto        = regField("TAuto", 0x80)
+	fieldTGated       = regField("TGated", 0x60)
+	fieldTAutoRestart = regField("TAutoRestart", 0x10)
+	fieldTPrescalerHi = regField("TPrescaler_Hi", 0x0F)
+)
+
+// TModeReg
+type TMode struct {
+	TAuto        bool // the timer starts at the end of the transmission
+	TGated       byte
+	TAutoRestart bool
+	TPrescalerHi byte // bits 11..8 of TPrescaler
+}
+
+func (TMode) Address() byte { return TModeReg }
+
+func (t TMode) Encode() byte {
+	return bitIf(t.TAuto, fieldTAuto) | fieldTGated.Set(t.TGated) | bitIf(t.TAutoRestart, fieldTAutoRestart) |
+		fieldTPrescalerHi.Set(t.TPrescalerHi)
+}
+
+func DecodeTMode(value byte) TMode {
+	return TMode{
+		TAuto: isSet(value, fieldTAuto), TGated: fieldTGated.Get(value), TAutoRestart: isSet(value, fieldTAutoRestart),
+		TPrescalerHi: fieldTPrescalerHi.Get(value),
+	}
+}
+
+var (
+	fieldAmpRcv   = regField("AmpRcv", 0x40)
+	fieldSelfTest = regField("SelfTest", 0x0F)
+)
+
+// AutoTestReg
+type AutoTest struct {
+	AmpRcv   bool
+	SelfTest byte // 9 enables the digital self-test
+}
+
+func (AutoTest) Address() byte { return AutoTestReg }
+
+func (a AutoTest) Encode() byte {
+	return bitIf(a.AmpRcv, fieldAmpRcv) | fieldSelfTest.Set(a.SelfTest)
+}
+
+func DecodeAutoTest(value byte) AutoTest {
+	return AutoTest{AmpRcv: isSet(value, fieldAmpRcv), SelfTest: fieldSelfTest.Get(value)}
+}
+
+var (
+	fieldMinLevel  = regField("MinLevel", 0xF0)
+	fieldCollLevel = regField("CollLevel", 0x07)
+)
+
+// RxThresholdReg
+type RxThreshold struct {
+	MinLevel  byte // minimum signal strength the decoder accepts
+	CollLevel byte // minimum strength of the weaker half-bit of a collision
+}
+
+func (RxThreshold) Address() byte { return RxThresholdReg }
+
+func (r RxThreshold) Encode() byte {
+	return fieldMinLevel.Set(r.MinLevel) | fieldCollLevel.Set(r.CollLevel)
+}
+
+func DecodeRxThreshold(value byte) RxThreshold {
+	return RxThreshold{MinLevel: fieldMinLevel.Get(value), CollLevel: fieldCollLevel.Get(value)}
+}
+
+var (
+	fieldAddIQ        = regField("AddIQ", 0xC0)
+	fieldFixIQ        = regField("FixIQ", 0x20)
+	fieldTPrescalEven = regField("TPrescalEven", 0x10)
+	fieldTauRcv       = regField("TauRcv", 0x0C)
+	fieldTauSync      = regField("TauSync", 0x03)
+)
+
+// DemodReg
+type Demod struct {
+	AddIQ        byte
+	FixIQ        bool // the receiver uses the I or Q channel only, see AddIQ
+	TPrescalEven bool // the timer runs at 13.56 MHz/(2*TPrescaler+2), TimerSettings expects 0
+	TauRcv       byte
+	TauSync      byte
+}
+
+func (Demod) Address() byte { return DemodReg }
+
+func (d Demod) Encode() byte {
+	return fieldAddIQ.Set(d.AddIQ) | bitIf(d.FixIQ, fieldFixIQ) | bitIf(d.TPrescalEven, fieldTPrescalEven) |
+		fieldTauRcv.Set(d.TauRcv) | fieldTauSync.Set(d.TauSync)
+}
+
+func DecodeDemod(value byte) Demod {
+	return Demod{
+		AddIQ: fieldAddIQ.Get(value), FixIQ: isSet(value, fieldFixIQ), TPrescalEven: isSet(value, fieldTPrescalEven),
+		TauRcv: fieldTauRcv.Get(value), TauSync: fieldTauSync.Get(value),
+	}
+}
+
+var (
+	fieldRxGain = regField("RxGain", 0x70)
+)
+
+// Bit 3 of RFCfgReg is reserved, it reads 1 after reset
+const rfCfgReserved = 0x08
+
+// RFCfgReg
+type RFCfg struct {
+	RxGain RxGain
+}
+
+func (RFCfg) Address() byte { return RFCfgReg }
+
+func (r RFCfg) Encode() byte {
+	return fieldRxGain.Set(byte(r.RxGain)) | rfCfgReserved
+}
+
+func DecodeRFCfg(value byte) RFCfg {
+	return RFCfg{RxGain: RxGain(fieldRxGain.Get(value))}
+}
+
+var (
+	fieldCWGsN  = regField("CWGsN", 0xF0)
+	fieldModGsN = regField("ModGsN", 0x0F)
+)
+
+// GsNReg, conductance of the n-channel drivers
+type GsN struct {
+	CWGsN  byte // during the carrier
+	ModGsN byte // during modulation
+}
+
+func (GsN) Address() byte { return GsNReg }
+
+func (g GsN) Encode() byte {
+	return fieldCWGsN.Set(g.CWGsN) | fieldModGsN.Set(g.ModGsN)
+}
+
+func DecodeGsN(value byte) GsN {
+	return GsN{CWGsN: fieldCWGsN.Get(value), ModGsN: fieldModGsN.Get(value)}
+}
+
+var (
+	fieldCWGsP = regField("CWGsP", 0x3F)
+)
+
+// CWGsPReg, conductance of the p-channel drivers during the carrier
+type CWGsP struct {
+	CWGsP byte
+}
+
+func (CWGsP) Address() byte { return CWGsPReg }
+
+func (c CWGsP) Encode() byte {
+	return fieldCWGsP.Set(c.CWGsP)
+}
+
+func DecodeCWGsP(value byte) CWGsP {
+	return CWGsP{CWGsP: fieldCWGsP.Get(value)}
+}
+
+var (
+	fieldModGsP = regField("ModGsP", 0x3F)
+)
+
+// ModGsPReg, conductance of the p-channel drivers during modulation
+type ModGsP struct {
+	ModGsP byte
+}
+
+func (ModGsP) Address() byte { return ModGsPReg }
+
+func (m ModGsP) Encode() byte {
+	return fieldModGsP.Set(m.ModGsP)
+}
+
+func DecodeModGsP(value byte) ModGsP {
+	return ModGsP{ModGsP: fieldModGsP.Get(value)}
+}
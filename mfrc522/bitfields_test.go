@@ -0,0 +1,99 @@
+package mfrc522
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestBitfieldLayout(t *testing.T) {
+	is := is.New(t)
+	// Every struct field is a field of the Registers table and Encode sets its bits
+	for _, value := range []RegisterValue{Command{}, ComIEn{}, ComIrq{}, DivIrq{}, ErrorFlags{}, Status1{}, Status2{},
+		FIFOLevel{}, Control{}, BitFraming{}, Coll{}, Mode{}, TxMode{}, RxMode{}, TxControl{}, TxASK{}, MfRx{}, TMode{}, AutoTest{},
+		RxThreshold{}, Demod{}, RFCfg{}, GsN{}, CWGsP{}, ModGsP{}} {
+		reg := RegisterAt(value.Address())
+		is.True(reg != nil)
+		reserved := value.Encode() // Mode and RFCfg set their reserved bits
+		typ := reflect.TypeOf(value)
+		for i := 0; i < typ.NumField(); i++ {
+			field := reflect.New(typ).Elem()
+			switch f := field.Field(i); f.Kind() {
+			case reflect.Bool:
+				f.SetBool(true)
+			default:
+				f.SetUint(0xFF)
+			}
+			encoded := field.Interface().(RegisterValue).Encode() &^ reserved
+
+			name := typ.Field(i).Name
+			var mask byte
+			for _, f := range reg.Fields {
+				if strings.Replace(f.Name, "_", "", -1) == name {
+					mask = f.Mask
+				}
+			}
+			if encoded != mask {
+				t.Errorf("%s.%s: encoded %02x, datasheet mask %02x", reg.Name, name, encoded, mask)
+			}
+		}
+	}
+}
+
+func TestBitfieldEncodeDecode(t *testing.T) {
+	is := is.New(t)
+
+	// Reset values, datasheet 9.3
+	is.Equal(DecodeCommand(0x20), Command{RcvOff: true, Command: PCD_Idle})
+	is.Equal(DecodeComIrq(0x14), ComIrq{IdleIRq: true, LoAlertIRq: true})
+	is.Equal(DecodeColl(0x80), Coll{ValuesAfterColl: true})
+	is.Equal(DecodeMode(0x3F), Mode{TxWaitRF: true, PolMFin: true, CRCPreset: 3})
+	is.Equal(Mode{TxWaitRF: true, PolMFin: true, CRCPreset: 3}.Encode(), byte(0x3F))
+	is.Equal(DecodeTxControl(0x80), TxControl{InvTx2RFOn: true})
+	is.Equal(DecodeAutoTest(0x40), AutoTest{AmpRcv: true})
+	is.Equal(DecodeRFCfg(0x48), RFCfg{RxGain: RX_GAIN_33DB})
+	is.Equal(RFCfg{RxGain: RX_GAIN_33DB}.Encode(), byte(0x48))
+	is.Equal(DecodeGsN(0x88), GsN{CWGsN: 8, ModGsN: 8})
+	is.Equal(DecodeCWGsP(0x20), CWGsP{CWGsP: 0x20})
+	is.Equal(DecodeRxThreshold(0x84), RxThreshold{MinLevel: 8, CollLevel: 4})
+	is.Equal(DecodeDemod(0x4D), Demod{AddIQ: 1, TauRcv: 3, TauSync: 1})
+
+	// The values written by the driver
+	is.Equal(ComIrqAll.Encode(), byte(0x7F))
+	is.Equal(ComIrq{RxIRq: true, IdleIRq: true, TimerIRq: true}.Encode(), byte(0x31))
+	is.Equal(BitFraming{StartSend: true, RxAlign: 7, TxLastBits: 7}.Encode(), byte(0xF7))
+	is.Equal(TMode{TAuto: true, TPrescalerHi: 0x0D}.Encode(), byte(0x8D))
+	is.Equal(TxMode{TxCRCEn: true, TxSpeed: BIT_RATE_424}.Encode(), byte(0xA0))
+	is.Equal(DecodeRxMode(0x90), RxMode{RxCRCEn: true, RxSpeed: BIT_RATE_212})
+	is.Equal(TxASK{Force100ASK: true}.Encode(), byte(0x40))
+	is.Equal(AutoTest{SelfTest: 9}.Encode(), byte(0x09))
+
+	// Values out of range do not spill into the neighbouring fields
+	is.Equal(BitFraming{RxAlign: 0x0F, TxLastBits: 0x0F}.Encode(), byte(0x77))
+	is.Equal(Command{Command: 0xFF}.Encode(), byte(0x0F))
+
+	// Decode inverts Encode
+	for v := 0; v < 256; v++ {
+		b := byte(v)
+		is.Equal(DecodeComIrq(b).Encode(), b)
+		is.Equal(DecodeComIEn(b).Encode(), b)
+		is.Equal(DecodeBitFraming(b).Encode(), b&0xF7)
+		is.Equal(DecodeTMode(b).Encode(), b)
+		is.Equal(DecodeErrorFlags(b).Encode(), b&0xDF)
+		is.Equal(DecodeColl(b).Encode(), b&0xBF)
+		is.Equal(DecodeGsN(b).Encode(), b)
+		is.Equal(DecodeDemod(b).Encode(), b)
+		is.Equal(DecodeRxThreshold(b).Encode(), b&0xF7)
+	}
+}
+
+func TestBitfieldErrorReg(t *testing.T) {
+	is := is.New(t)
+	is.Equal(errorRegError(ErrorFlags{WrErr: true}.Encode()), ErrFIFOWrite)
+	is.Equal(errorRegError(ErrorFlags{TempErr: true, CRCErr: true}.Encode()), ErrTemperature)
+	is.Equal(errorRegError(ErrorFlags{CollErr: true, ParityErr: true}.Encode()), ErrCollision)
+	is.Equal(errorRegError(ErrorFlags{ProtocolErr: true}.Encode()), ErrProtocol)
+	is.NoErr(errorRegError(0))
+}
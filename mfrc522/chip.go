@@ -0,0 +1,209 @@
+// Chip identification: the MFRC522 versions and the clones found on RC522 modules.
+
+package mfrc522
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// How far a chip follows the MFRC522 datasheet
+type Compatibility int
+
+const (
+	COMPAT_FULL    Compatibility = iota // NXP MFRC522, the self-test result is known
+	COMPAT_CLONE                        // known clone, the self-test result is known
+	COMPAT_LIMITED                      // known clone with quirks, the core features are checked instead of the self-test
+	COMPAT_UNKNOWN                      // unknown VersionReg, treated like COMPAT_LIMITED
+)
+
+func (c Compatibility) String() string {
+	switch c {
+	case COMPAT_FULL:
+		return "full"
+	case COMPAT_CLONE:
+		return "clone"
+	case COMPAT_LIMITED:
+		return "limited"
+	case COMPAT_UNKNOWN:
+		return "unknown"
+	}
+	return fmt.Sprintf("Compatibility(%d)", int(c))
+}
+
+// Deviations of a chip from the datasheet, the driver works around them
+type Quirk uint
+
+const (
+	// The digital self-test result is unknown or differs between batches.
+	// PCD_PerformSelfTest checks the FIFO and the CRC coprocessor instead.
+	QUIRK_NO_SELF_TEST Quirk = 1 << iota
+	// The timer periods differ from the NXP chip by the drift given to
+	// WithTimerDrift, setTimer corrects them. Opt-in, no chip has it by default.
+	QUIRK_TIMER_DRIFT
+)
+
+func (q Quirk) String() string {
+	var names []string
+	for _, quirk := range []struct {
+		flag Quirk
+		name string
+	}{
+		{QUIRK_NO_SELF_TEST, "no self-test"},
+		{QUIRK_TIMER_DRIFT, "timer drift"},
+	} {
+		if q&quirk.flag != 0 {
+			names = append(names, quirk.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+type ChipInfo struct {
+	Version       byte
+	Name          string
+	Compatibility Compatibility
+	Quirks        Quirk
+	SelfTest      []byte // expected self-test result, nil with QUIRK_NO_SELF_TEST
+}
+
+func (c ChipInfo) String() string {
+	return fmt.Sprintf("%s (VersionReg %02x, compatibility %s, quirks: %s)", c.Name, c.Version, c.Compatibility, c.Quirks)
+}
+
+// Signatures of the known chips by VersionReg
+var KnownChips = []ChipInfo{
+	{VER_0_0, "MFRC522 v0.0", COMPAT_FULL, 0, MFRC522_VER_0_0},
+	{VER_1_0, "MFRC522 v1.0", COMPAT_FULL, 0, MFRC522_VER_1_0},
+	{VER_2_0, "MFRC522 v2.0", COMPAT_FULL, 0, MFRC522_VER_2_0},
+	{VER_FM17522, "FM17522 clone", COMPAT_CLONE, 0, FM17522_SELF_TEST},
+	{VER_COUNTERFEIT, "counterfeit MFRC522", COMPAT_LIMITED, QUIRK_NO_SELF_TEST, nil},
+	{VER_CLONE_B2, "MFRC522 clone", COMPAT_LIMITED, QUIRK_NO_SELF_TEST, nil},
+}
+
+/**
+ * The chip with the VersionReg value version. An unknown version gets
+ * COMPAT_UNKNOWN, its self-test result is unknown. 00 and FF mean that no chip answers.
+ */
+func IdentifyChip(version byte) (ChipInfo, error) {
+	if version == 0x00 || version == 0xFF {
+		return ChipInfo{}, CommonError(fmt.Sprintf("No chip answers, VersionReg: %02x", version))
+	}
+	for _, chip := range KnownChips {
+		if chip.Version == version {
+			return chip, nil
+		}
+	}
+	return ChipInfo{
+		Version:       version,
+		Name:          "unknown chip",
+		Compatibility: COMPAT_UNKNOWN,
+		Quirks:        QUIRK_NO_SELF_TEST,
+	}, nil
+}
+
+/**
+ * Reads VersionReg and identifies the chip, PCD_Init does it too.
+ * The quirks of the chip apply from then on.
+ */
+func (r *MFRC522) PCD_Identify() (ChipInfo, error) {
+	r.lock()
+	defer r.unlock()
+	return r.identify()
+}
+
+func (r *MFRC522) identify() (ChipInfo, error) {
+	version, err := r.readRegister(VersionReg)
+	if err != nil {
+		return ChipInfo{}, err
+	}
+	chip, err := IdentifyChip(version)
+	if err != nil {
+		return ChipInfo{}, err
+	}
+	if r.timerDrift != 0 {
+		chip.Quirks |= QUIRK_TIMER_DRIFT
+	}
+	if r.chip.Version != version {
+		r.logger.Log(LOG_INFO, "chip", "info", chip)
+	}
+	r.chip = chip
+	return chip, nil
+}
+
+/**
+ * The chip identified by PCD_Init or PCD_Identify, zero before.
+ */
+func (r *MFRC522) Chip() ChipInfo {
+	r.lock()
+	defer r.unlock()
+	return r.chip
+}
+
+/**
+ * Checks the features the driver needs: FIFO bursts and the CRC coprocessor.
+ * It replaces the self-test of chips with QUIRK_NO_SELF_TEST.
+ */
+func (r *MFRC522) checkCoreFeatures() error {
+	data := make([]byte, FIFO_SIZE)
+	for i := range data {
+		data[i] = byte(i*37) ^ 0x5A
+	}
+	if err := r.writeReg(FIFOLevel{FlushBuffer: true}); err != nil {
+		return err
+	}
+	if err := r.writeFIFOBuffer(data); err != nil {
+		return err
+	}
+	read, err := r.readFIFOBuffer(len(data))
+	if err != nil {
+		return err
+	}
+	if bytes.Compare(read, data) != 0 {
+		return CommonError(fmt.Sprintf("FIFO check failed:\nexpected: [% x]\n  actual: [% x]", data, read))
+	}
+
+	crc, err := r.calculateCRC(CRC_RESET_VALUE_6363, data[:16], INTERUPT_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	if expected := ISO14443aCRC(data[:16]); bytes.Compare(crc, expected) != 0 {
+		return CommonError(fmt.Sprintf("CRC coprocessor check failed: expected [% x], actual [% x]", expected, crc))
+	}
+	return nil
+}
+
+/**
+ * Self-test of the chip: the digital self-test if its result is known,
+ * otherwise the core features. A clone whose self-test result differs is
+ * accepted if the core features work. The chip is reset.
+ */
+func (r *MFRC522) selfTestChip(ctx context.Context) error {
+	chip, err := r.identify()
+	if err != nil {
+		return err
+	}
+	if chip.Quirks&QUIRK_NO_SELF_TEST == 0 {
+		result, err := r.selfTestResult()
+		if err != nil {
+			return err
+		}
+		if bytes.Compare(result, chip.SelfTest) == 0 {
+			return nil
+		}
+		if chip.Compatibility == COMPAT_FULL {
+			return CommonError(fmt.Sprintf("MFRC522 Self test [ERROR]:\nexpected: [% x]\n   "+
+				"actual: [% x]", chip.SelfTest, result))
+		}
+		r.logger.Log(LOG_WARN, "self-test result of the clone differs, checking the core features", "chip", chip.Name, "result", result)
+	}
+	if err := r.reset(ctx); err != nil {
+		return err
+	}
+	return r.checkCoreFeatures()
+}
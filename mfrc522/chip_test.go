@@ -0,0 +1,64 @@
+package mfrc522
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestIdentifyChip(t *testing.T) {
+	is := is.New(t)
+	for _, test := range []struct {
+		version byte
+		compat  Compatibility
+		quirks  Quirk
+	}{
+		{VER_2_0, COMPAT_FULL, 0},
+		{VER_FM17522, COMPAT_CLONE, 0},
+		{VER_COUNTERFEIT, COMPAT_LIMITED, QUIRK_NO_SELF_TEST},
+		{0x3C, COMPAT_UNKNOWN, QUIRK_NO_SELF_TEST},
+	} {
+		chip, err := IdentifyChip(test.version)
+		is.NoErr(err)
+		is.Equal(chip.Compatibility, test.compat)
+		is.Equal(chip.Quirks, test.quirks)
+	}
+	_, err := IdentifyChip(0xFF)
+	is.True(err != nil)
+}
+
+func TestCloneSelfTest(t *testing.T) {
+	is := is.New(t)
+	for _, version := range []byte{VER_0_0, VER_FM17522, VER_COUNTERFEIT, VER_CLONE_B2, 0x3C} {
+		sim := NewMFRC522Simulator()
+		sim.Version = version
+		reader := newSimulatedMFRC522(t, sim)
+		is.NoErr(reader.PCD_PerformSelfTest()) // accepted: known result or working core features
+		is.Equal(reader.Chip().Version, version)
+	}
+}
+
+func TestTimerDrift(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	sim.Version = VER_CLONE_B2
+	reader := newSimulatedMFRC522(t, sim)
+	is.NoErr(reader.PCD_Init())
+	is.Equal(reader.Chip().Quirks, QUIRK_NO_SELF_TEST) // no drift unless asked for
+	is.NoErr(reader.PCD_SetTimer(INTERUPT_TIMEOUT))
+	genuine := sim.TimerPeriod()
+
+	sim = NewMFRC522Simulator()
+	sim.Version = VER_CLONE_B2
+	reader, err := New(SPIPort(sim), WithTimerDrift(-0.2)) // the timer runs 20% fast
+	is.NoErr(err)
+	is.NoErr(reader.PCD_Init())
+	is.Equal(reader.Chip().Quirks, QUIRK_NO_SELF_TEST|QUIRK_TIMER_DRIFT)
+	is.NoErr(reader.PCD_SetTimer(INTERUPT_TIMEOUT))
+	is.True(sim.TimerPeriod() > genuine*5/4-genuine/100)
+	is.True(sim.TimerPeriod() < genuine*5/4+genuine/100)
+
+	_, err = New(SPIPort(NewMFRC522Simulator()), WithTimerDrift(-1))
+	is.True(errors.Is(err, ErrUsage))
+}
@@ -0,0 +1,524 @@
+// Reader configuration file: transport, pins, timing, RF profile, keys and logging.
+// The format is JSON, the standard library has no YAML decoder.
+
+package mfrc522
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spireg"
+)
+
+const (
+	TRANSPORT_SPI  = "spi"
+	TRANSPORT_I2C  = "i2c"
+	TRANSPORT_UART = "uart"
+)
+
+/**
+ * Configuration of a reader, see LoadConfig. Missing values are the defaults of New.
+ *
+ *	{
+ *	  "transport": {"type": "spi", "port": "", "speed": "1MHz"},
+ *	  "pins": {"reset": "GPIO25", "irq": "GPIO4"},
+ *	  "timeouts": {"default": "5ms"},
+ *	  "rf": {"rx_gain_db": 43, "cw_gsp": 48},
+ *	  "antenna_on": true,
+ *	  "keys": [{"name": "door", "env": "DOOR_KEY"}],
+ *	  "log": {"level": "info", "output": "stderr"}
+ *	}
+ */
+type Config struct {
+	Transport   TransportConfig `json:"transport"`
+	Pins        PinsConfig      `json:"pins"`
+	Timeouts    TimeoutsConfig  `json:"timeouts"`
+	RF          *RFProfile      `json:"rf,omitempty"` // nil keeps the chip defaults
+	AntennaOn   bool            `json:"antenna_on"`
+	HardwareCRC bool            `json:"hardware_crc"`
+	Keys        []KeyConfig     `json:"keys,omitempty"`
+	Log         LogConfig       `json:"log"`
+}
+
+type TransportConfig struct {
+	Type    string `json:"type"`    // spi (default), i2c or uart
+	Port    string `json:"port"`    // spireg or i2creg name, "" is the first one; the serial device of uart
+	Speed   string `json:"speed"`   // SPI clock, SPI_DEFAULT_SPEED by default
+	Mode    int    `json:"mode"`    // SPI mode 0..3
+	Address uint16 `json:"address"` // I2C address, MFRC522_I2C_ADDR by default
+}
+
+// gpioreg names, empty if not wired
+type PinsConfig struct {
+	Reset string `json:"reset"`
+	IRQ   string `json:"irq"`
+}
+
+// Zero values are the DefaultTimeouts
+type TimeoutsConfig struct {
+	Anticollision Duration `json:"anticollision"`
+	Default       Duration `json:"default"`
+	Write         Duration `json:"write"`
+}
+
+// time.Duration written as a string like "25ms"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"25ms\": %s", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+/**
+ * RFConfig in the configuration file, missing fields are the DefaultRFConfig.
+ */
+type RFProfile struct {
+	RxGainDB  int  `json:"rx_gain_db"`
+	CWGsN     byte `json:"cw_gsn"`
+	ModGsN    byte `json:"mod_gsn"`
+	CWGsP     byte `json:"cw_gsp"`
+	ModGsP    byte `json:"mod_gsp"`
+	MinLevel  byte `json:"min_level"`
+	CollLevel byte `json:"coll_level"`
+	AddIQ     byte `json:"add_iq"`
+	FixIQ     bool `json:"fix_iq"`
+	TauRcv    byte `json:"tau_rcv"`
+	TauSync   byte `json:"tau_sync"`
+}
+
+/**
+ * The profile of c, for example to save CalibrationReport.Recommended.
+ */
+func NewRFProfile(c RFConfig) *RFProfile {
+	return &RFProfile{
+		RxGainDB: c.RxGain.DB(),
+		CWGsN:    c.CWGsN, ModGsN: c.ModGsN,
+		CWGsP: c.CWGsP, ModGsP: c.ModGsP,
+		MinLevel: c.MinLevel, CollLevel: c.CollLevel,
+		AddIQ: c.AddIQ, FixIQ: c.FixIQ,
+		TauRcv: c.TauRcv, TauSync: c.TauSync,
+	}
+}
+
+func (p *RFProfile) UnmarshalJSON(data []byte) error {
+	type plain RFProfile
+	profile := plain(*NewRFProfile(DefaultRFConfig))
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return err
+	}
+	*p = RFProfile(profile)
+	return nil
+}
+
+func (p *RFProfile) RFConfig() (RFConfig, error) {
+	gain, err := RxGainFromDB(p.RxGainDB)
+	if err != nil {
+		return RFConfig{}, err
+	}
+	c := RFConfig{
+		RxGain: gain,
+		CWGsN:  p.CWGsN, ModGsN: p.ModGsN,
+		CWGsP: p.CWGsP, ModGsP: p.ModGsP,
+		MinLevel: p.MinLevel, CollLevel: p.CollLevel,
+		AddIQ: p.AddIQ, FixIQ: p.FixIQ,
+		TauRcv: p.TauRcv, TauSync: p.TauSync,
+	}
+	return c, c.validate()
+}
+
+/**
+ * A named key in hex from exactly one source: inline, an environment variable or a file.
+ * Env and File keep the secret out of the configuration.
+ */
+type KeyConfig struct {
+	Name string `json:"name"`
+	Hex  string `json:"hex,omitempty"`
+	Env  string `json:"env,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+// MIFARE Classic keys are 6 bytes, AES keys (NTAG 424 DNA) 16 bytes
+func parseKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 6 && len(key) != 16 {
+		return nil, fmt.Errorf("key must be 6 or 16 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+type LogConfig struct {
+	Level  string `json:"level"`  // trace, debug, info, warn or error; empty disables logging
+	Output string `json:"output"` // stderr (default), stdout or a file, appended
+}
+
+/**
+ * Reads and validates a configuration file.
+ */
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, ConfigError(fmt.Sprintf("config: %v", err))
+	}
+	defer f.Close()
+	config, err := ParseConfig(f)
+	if err != nil {
+		// The path goes into the "config: " prefix of ParseConfig
+		return nil, ConfigError(fmt.Sprintf("config %s: %s", path, strings.TrimPrefix(err.Error(), "config: ")))
+	}
+	return config, nil
+}
+
+/**
+ * Decodes and validates a configuration, unknown fields are errors.
+ */
+func ParseConfig(r io.Reader) (*Config, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	config := &Config{}
+	if err := decoder.Decode(config); err != nil {
+		return nil, ConfigError(fmt.Sprintf("config: %v", err))
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+/**
+ * Checks the values and sets the defaults of the missing ones.
+ * The keys in environment variables and files are checked by LoadKeys.
+ */
+func (c *Config) Validate() error {
+	fail := func(field string, format string, args ...interface{}) error {
+		return ConfigError(fmt.Sprintf("config: %s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	t := &c.Transport
+	t.Type = strings.ToLower(t.Type)
+	switch t.Type {
+	case "":
+		t.Type = TRANSPORT_SPI
+	case TRANSPORT_SPI, TRANSPORT_I2C, TRANSPORT_UART:
+	default:
+		return fail("transport.type", "%q is not one of spi, i2c or uart", t.Type)
+	}
+	if t.Type == TRANSPORT_SPI {
+		if t.Speed == "" {
+			t.Speed = SPI_DEFAULT_SPEED.String()
+		}
+		if _, err := t.spiSpeed(); err != nil {
+			return fail("transport.speed", "%v", err)
+		}
+		if t.Mode < 0 || t.Mode > 3 {
+			return fail("transport.mode", "SPI mode %d out of range [0, 3]", t.Mode)
+		}
+	} else if t.Speed != "" || t.Mode != 0 {
+		return fail("transport", "speed and mode need the spi transport")
+	}
+	if t.Type == TRANSPORT_I2C {
+		if t.Address == 0 {
+			t.Address = MFRC522_I2C_ADDR
+		}
+		if t.Address > 0x7F {
+			return fail("transport.address", "I2C address %#x is not 7 bits", t.Address)
+		}
+	} else if t.Address != 0 {
+		return fail("transport.address", "the address needs the i2c transport")
+	}
+	if t.Type == TRANSPORT_UART && t.Port == "" {
+		return fail("transport.port", "the uart transport needs the serial device")
+	}
+
+	for _, timeout := range []struct {
+		field string
+		value *Duration
+		def   time.Duration
+	}{
+		{"timeouts.anticollision", &c.Timeouts.Anticollision, DefaultTimeouts.Anticollision},
+		{"timeouts.default", &c.Timeouts.Default, DefaultTimeouts.Default},
+		{"timeouts.write", &c.Timeouts.Write, DefaultTimeouts.Write},
+	} {
+		if *timeout.value < 0 {
+			return fail(timeout.field, "must be positive: %s", time.Duration(*timeout.value))
+		}
+		if *timeout.value == 0 {
+			*timeout.value = Duration(timeout.def)
+		}
+		// The timer of the chip must be able to count it
+		if _, _, err := TimerSettings(time.Duration(*timeout.value)); err != nil {
+			return fail(timeout.field, "%v", err)
+		}
+	}
+
+	if c.RF != nil {
+		if _, err := c.RF.RFConfig(); err != nil {
+			return fail("rf", "%v", err)
+		}
+	}
+
+	names := map[string]bool{}
+	for i, key := range c.Keys {
+		field := fmt.Sprintf("keys[%d]", i)
+		if key.Name == "" {
+			return fail(field, "name is missing")
+		}
+		if names[key.Name] {
+			return fail(field, "duplicate name %q", key.Name)
+		}
+		names[key.Name] = true
+		sources := 0
+		for _, source := range []string{key.Hex, key.Env, key.File} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fail(field, "key %q needs exactly one of hex, env or file", key.Name)
+		}
+		if key.Hex != "" {
+			if _, err := parseKey(key.Hex); err != nil {
+				return fail(field, "key %q: %v", key.Name, err)
+			}
+		}
+	}
+
+	if _, err := c.Log.level(); err != nil {
+		return fail("log.level", "%v", err)
+	}
+	return nil
+}
+
+func (t TransportConfig) spiSpeed() (physic.Frequency, error) {
+	var speed physic.Frequency
+	if err := speed.Set(t.Speed); err != nil {
+		return 0, err
+	}
+	if speed <= 0 || speed > SPI_DEFAULT_SPEED {
+		return 0, fmt.Errorf("SPI speed %s out of range (0, %s]", speed, SPI_DEFAULT_SPEED)
+	}
+	return speed, nil
+}
+
+func (l LogConfig) level() (LogLevel, error) {
+	for level := LOG_TRACE; level <= LOG_ERROR; level++ {
+		if strings.EqualFold(l.Level, level.String()) {
+			return level, nil
+		}
+	}
+	if l.Level == "" {
+		return LOG_ERROR, nil
+	}
+	return 0, fmt.Errorf("%q is not one of trace, debug, info, warn or error", l.Level)
+}
+
+/**
+ * Resolves the keys by name.
+ */
+func (c *Config) LoadKeys() (map[string][]byte, error) {
+	keys := make(map[string][]byte, len(c.Keys))
+	for _, k := range c.Keys {
+		value := k.Hex
+		switch {
+		case k.Env != "":
+			var ok bool
+			if value, ok = os.LookupEnv(k.Env); !ok {
+				return nil, ConfigError(fmt.Sprintf("config: key %q: environment variable %s is not set", k.Name, k.Env))
+			}
+		case k.File != "":
+			data, err := ioutil.ReadFile(k.File)
+			if err != nil {
+				return nil, ConfigError(fmt.Sprintf("config: key %q: %v", k.Name, err))
+			}
+			value = string(data)
+		}
+		key, err := parseKey(value)
+		if err != nil {
+			return nil, ConfigError(fmt.Sprintf("config: key %q: %v", k.Name, err))
+		}
+		keys[k.Name] = key
+	}
+	return keys, nil
+}
+
+/**
+ * Closes all closers in reverse order, returns the first error.
+ */
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var first error
+	for i := len(m) - 1; i >= 0; i-- {
+		if err := m[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+/**
+ * Options of New without the transport. Pins are looked up in gpioreg,
+ * the log file is opened: the closer closes it once the reader is not used anymore.
+ */
+func (c *Config) Options() ([]Option, io.Closer, error) {
+	var opts []Option
+	if c.Transport.Type == TRANSPORT_SPI {
+		speed, err := c.Transport.spiSpeed()
+		if err != nil {
+			return nil, nil, ConfigError(fmt.Sprintf("config: transport.speed: %v", err))
+		}
+		opts = append(opts, WithSPISpeed(speed), WithSPIMode(spi.Mode(c.Transport.Mode)))
+	}
+
+	pin := func(field, name string) (gpio.PinIO, error) {
+		p := gpioreg.ByName(name)
+		if p == nil {
+			return nil, ConfigError(fmt.Sprintf("config: %s: no GPIO pin %q, is the host initialized?", field, name))
+		}
+		return p, nil
+	}
+	if c.Pins.Reset != "" {
+		p, err := pin("pins.reset", c.Pins.Reset)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, WithResetPin(p))
+	}
+	if c.Pins.IRQ != "" {
+		p, err := pin("pins.irq", c.Pins.IRQ)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, WithIRQPin(p))
+	}
+
+	opts = append(opts, WithTimeouts(Timeouts{
+		Anticollision: time.Duration(c.Timeouts.Anticollision),
+		Default:       time.Duration(c.Timeouts.Default),
+		Write:         time.Duration(c.Timeouts.Write),
+	}))
+	if c.RF != nil {
+		rf, err := c.RF.RFConfig()
+		if err != nil {
+			return nil, nil, ConfigError(fmt.Sprintf("config: rf: %v", err))
+		}
+		opts = append(opts, WithRFConfig(rf))
+	}
+	if c.AntennaOn {
+		opts = append(opts, WithAntennaOn())
+	}
+	if c.HardwareCRC {
+		opts = append(opts, WithHardwareCRC())
+	}
+
+	// The log file is opened last, no error path follows
+	var closer multiCloser
+	if c.Log.Level != "" {
+		level, err := c.Log.level()
+		if err != nil {
+			return nil, nil, ConfigError(fmt.Sprintf("config: log.level: %v", err))
+		}
+		var out io.Writer
+		switch c.Log.Output {
+		case "", "stderr":
+			out = os.Stderr
+		case "stdout":
+			out = os.Stdout
+		default:
+			f, err := os.OpenFile(c.Log.Output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				return nil, nil, ConfigError(fmt.Sprintf("config: log.output: %v", err))
+			}
+			out, closer = f, append(closer, f)
+		}
+		opts = append(opts, WithLogger(NewStdLogger(log.New(out, "", log.LstdFlags), level)))
+	}
+	return opts, closer, nil
+}
+
+/**
+ * Opens the transport, creates the reader and runs PCD_Init. The periph host
+ * drivers must be loaded (host.Init). The closer releases the port or bus
+ * and closes the log file.
+ */
+func (c *Config) Open() (*MFRC522, io.Closer, error) {
+	var transport Transport
+	var closer io.Closer
+	switch c.Transport.Type {
+	case TRANSPORT_SPI:
+		port, err := spireg.Open(c.Transport.Port)
+		if err != nil {
+			return nil, nil, ConfigError(fmt.Sprintf("config: transport.port: %v", err))
+		}
+		transport, closer = SPIPort(port), port
+	case TRANSPORT_I2C:
+		bus, err := i2creg.Open(c.Transport.Port)
+		if err != nil {
+			return nil, nil, ConfigError(fmt.Sprintf("config: transport.port: %v", err))
+		}
+		transport, closer = NewI2CTransport(bus, c.Transport.Address), bus
+	case TRANSPORT_UART:
+		// The baud rate of the line is set outside, for example with stty
+		f, err := os.OpenFile(c.Transport.Port, os.O_RDWR, 0)
+		if err != nil {
+			return nil, nil, ConfigError(fmt.Sprintf("config: transport.port: %v", err))
+		}
+		transport, closer = NewUARTTransport(f), f
+	default:
+		return nil, nil, ConfigError(fmt.Sprintf("config: transport.type: %q is not one of spi, i2c or uart", c.Transport.Type))
+	}
+
+	reader, logCloser, err := c.NewReader(transport)
+	if err != nil {
+		closer.Close()
+		return nil, nil, err
+	}
+	return reader, multiCloser{closer, logCloser}, nil
+}
+
+/**
+ * Creates the reader on transport and runs PCD_Init.
+ * The closer closes the log file, the transport is left open.
+ */
+func (c *Config) NewReader(transport Transport) (*MFRC522, io.Closer, error) {
+	opts, closer, err := c.Options()
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := New(transport, opts...)
+	if err != nil {
+		closer.Close()
+		return nil, nil, err
+	}
+	if err := reader.PCD_Init(); err != nil {
+		closer.Close()
+		return nil, nil, err
+	}
+	return reader, closer, nil
+}
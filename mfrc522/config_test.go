@@ -0,0 +1,138 @@
+package mfrc522
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestParseConfig(t *testing.T) {
+	is := is.New(t)
+	config, err := ParseConfig(strings.NewReader(`{
+		"transport": {"speed": "1MHz", "mode": 0},
+		"timeouts": {"default": "40ms"},
+		"rf": {"rx_gain_db": 48, "cw_gsp": 63},
+		"antenna_on": true,
+		"keys": [{"name": "default", "hex": "ff ff ff ff ff ff"}],
+		"log": {"level": "debug"}
+	}`))
+	is.NoErr(err)
+	is.Equal(config.Transport.Type, TRANSPORT_SPI)
+	is.Equal(time.Duration(config.Timeouts.Default), 40*time.Millisecond)
+	is.Equal(time.Duration(config.Timeouts.Write), DefaultTimeouts.Write)
+
+	rf, err := config.RF.RFConfig()
+	is.NoErr(err)
+	expected := DefaultRFConfig
+	expected.RxGain, expected.CWGsP = RX_GAIN_48DB, 0x3F
+	is.Equal(rf, expected)
+
+	keys, err := config.LoadKeys()
+	is.NoErr(err)
+	is.True(bytes.Compare(keys["default"], defaultKey) == 0)
+
+	// Built on the simulator: the options are applied by PCD_Init
+	port := &recordingPort{MFRC522Simulator: NewMFRC522Simulator()}
+	reader, closer, err := config.NewReader(SPIPort(port))
+	is.NoErr(err)
+	defer closer.Close()
+	is.Equal(port.speed, physic.MegaHertz)
+	is.Equal(reader.timeouts.Default, 40*time.Millisecond)
+	read, err := reader.PCD_GetRFConfig()
+	is.NoErr(err)
+	is.Equal(read, expected)
+	tx, err := reader.readRegister(TxControlReg)
+	is.NoErr(err)
+	is.Equal(tx&0x03, byte(0x03))
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	is := is.New(t)
+	for _, test := range []struct{ json, field string }{
+		{`{"transport": {"type": "usb"}}`, "transport.type"},
+		{`{"transport": {"speed": "20MHz"}}`, "transport.speed"},
+		{`{"transport": {"type": "i2c", "mode": 1}}`, "transport"},
+		{`{"transport": {"type": "uart"}}`, "transport.port"},
+		{`{"timeouts": {"write": "-1s"}}`, "timeouts.write"},
+		{`{"timeouts": {"write": 100}}`, "duration"},
+		{`{"timeouts": {"default": "1h"}}`, "timeouts.default: Timer period too long"},
+		{`{"timeouts": {"anticollision": "10ns"}}`, "timeouts.anticollision: Timer period too short"},
+		{`{"rf": {"rx_gain_db": 40}}`, "rf"},
+		{`{"rf": {"min_level": 16}}`, "MinLevel"},
+		{`{"keys": [{"name": "a", "hex": "ffff", "env": "A"}]}`, "keys[0]"},
+		{`{"keys": [{"name": "a", "hex": "ffff"}]}`, "6 or 16 bytes"},
+		{`{"log": {"level": "verbose"}}`, "log.level"},
+		{`{"pin": {}}`, "unknown field"},
+	} {
+		_, err := ParseConfig(strings.NewReader(test.json))
+		is.True(errors.Is(err, ErrConfig))
+		is.True(strings.Contains(err.Error(), test.field)) // the field is named
+	}
+}
+
+func TestConfigKeySources(t *testing.T) {
+	is := is.New(t)
+	f, err := ioutil.TempFile("", "key")
+	is.NoErr(err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("a0a1a2a3a4a5\n")
+	is.NoErr(err)
+	f.Close()
+	os.Setenv("MFRC522_TEST_KEY", "d3f7d3f7d3f7")
+	defer os.Unsetenv("MFRC522_TEST_KEY")
+
+	config := &Config{Keys: []KeyConfig{
+		{Name: "mad", File: f.Name()},
+		{Name: "ndef", Env: "MFRC522_TEST_KEY"},
+	}}
+	is.NoErr(config.Validate())
+	keys, err := config.LoadKeys()
+	is.NoErr(err)
+	is.True(bytes.Compare(keys["mad"], []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}) == 0)
+	is.True(bytes.Compare(keys["ndef"], []byte{0xD3, 0xF7, 0xD3, 0xF7, 0xD3, 0xF7}) == 0)
+
+	config.Keys[1].Env = "MFRC522_TEST_MISSING"
+	_, err = config.LoadKeys()
+	is.True(errors.Is(err, ErrConfig))
+}
+
+func TestConfigLogFile(t *testing.T) {
+	is := is.New(t)
+	dir, err := ioutil.TempDir("", "mfrc522")
+	is.NoErr(err)
+	defer os.RemoveAll(dir)
+	config, err := ParseConfig(strings.NewReader(`{"log": {"level": "trace"}}`))
+	is.NoErr(err)
+	config.Log.Output = filepath.Join(dir, "reader.log")
+
+	_, closer, err := config.NewReader(SPIPort(NewMFRC522Simulator()))
+	is.NoErr(err)
+	is.NoErr(closer.Close())
+	is.True(errors.Is(closer.Close(), os.ErrClosed)) // the closer owns the log file
+	info, err := os.Stat(config.Log.Output)
+	is.NoErr(err)
+	is.True(info.Size() > 0)
+}
+
+func TestLoadConfigError(t *testing.T) {
+	is := is.New(t)
+	f, err := ioutil.TempFile("", "config")
+	is.NoErr(err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"timeouts": {"default": "1h"}}`)
+	is.NoErr(err)
+	f.Close()
+
+	_, err = LoadConfig(f.Name())
+	is.True(errors.Is(err, ErrConfig))
+	is.True(strings.HasPrefix(err.Error(), "config "+f.Name()+": timeouts.default: "))
+	is.Equal(strings.Count(err.Error(), "config"), 1+strings.Count(f.Name(), "config"))
+}
@@ -0,0 +1,59 @@
+package mfrc522
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestContextCanceled(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t, NewVirtualNTAG213([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := reader.PICC_RequestAContext(ctx)
+	is.True(errors.Is(err, context.Canceled))
+	is.True(!reader.PICC_IsNewCardPresentContext(ctx))
+	_, err = reader.PICC_SelectContext(ctx)
+	is.True(errors.Is(err, context.Canceled))
+	is.True(errors.Is(reader.PCD_ResetContext(ctx), context.Canceled))
+}
+
+func TestContextDeadline(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t) // no PICC, the timer stops the wait after 25ms
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	validBits := byte(7)
+	_, err := reader.PCD_CommunicateWithPICCContext(ctx, PCD_Transceive, []byte{PICC_CMD_REQA}, &validBits, time.Second)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+
+	// The command is stopped
+	command, err := reader.PCD_ReadRegister(CommandReg)
+	is.NoErr(err)
+	is.Equal(command&0x0F, byte(PCD_Idle))
+}
+
+func TestMifareReadWrite(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t, NewVirtualNTAG213([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}))
+	ctx := context.Background()
+
+	is.True(reader.PICC_IsNewCardPresentContext(ctx))
+	_, err := reader.PICC_SelectContext(ctx)
+	is.NoErr(err)
+
+	data := []byte{0xde, 0xad, 0xbe, 0xef, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	is.NoErr(reader.MIFARE_WriteContext(ctx, 0x06, data))
+	read, err := reader.MIFARE_ReadContext(ctx, 0x06)
+	is.NoErr(err)
+	is.True(bytes.Compare(read[:4], data[:4]) == 0)
+
+	is.True(errors.Is(reader.MIFARE_Write(0x06, data[:4]), ErrUsage))
+}
@@ -0,0 +1,148 @@
+// MIFARE Classic Crypto1 stream cipher.
+// Dismantling_mifare_classic.pdf, the layout of the state follows crapto1:
+// the 48 bit LFSR is kept as odd and even bits.
+
+package mfrc522
+
+const (
+	crypto1PolyOdd  = 0x29CE5C
+	crypto1PolyEven = 0x870804
+)
+
+// Crypto1 cipher state, used by both the PCD and the PICC side
+type Crypto1 struct {
+	odd, even uint32
+}
+
+/**
+ * Loads the 6 byte key into the LFSR.
+ */
+func NewCrypto1(key [6]byte) *Crypto1 {
+	var k uint64
+	for _, b := range key {
+		k = k<<8 | uint64(b)
+	}
+	c := &Crypto1{}
+	for i := 47; i > 0; i -= 2 {
+		c.odd = c.odd<<1 | uint32(k>>uint((i-1)^7)&1)
+		c.even = c.even<<1 | uint32(k>>uint(i^7)&1)
+	}
+	return c
+}
+
+// Nonlinear filter function, input is the odd half of the state
+func crypto1Filter(x uint32) uint32 {
+	f := uint32(0xf22c0) >> (x & 0xf) & 16
+	f |= uint32(0x6c9c0) >> (x >> 4 & 0xf) & 8
+	f |= uint32(0x3c8b0) >> (x >> 8 & 0xf) & 4
+	f |= uint32(0x1e458) >> (x >> 12 & 0xf) & 2
+	f |= uint32(0x0d938) >> (x >> 16 & 0xf) & 1
+	return uint32(0xEC57E80A) >> f & 1
+}
+
+func parity32(x uint32) uint32 {
+	x ^= x >> 16
+	x ^= x >> 8
+	x ^= x >> 4
+	return uint32(0x6996) >> (x & 0xf) & 1
+}
+
+/**
+ * Shifts one bit into the LFSR and returns the keystream bit.
+ * If encrypted is set, in is ciphertext and the keystream bit is removed before feeding.
+ */
+func (c *Crypto1) Bit(in byte, encrypted bool) byte {
+	ks := crypto1Filter(c.odd)
+	feedin := uint32(in & 1)
+	if encrypted {
+		feedin ^= ks
+	}
+	feedin ^= parity32(crypto1PolyOdd&c.odd ^ crypto1PolyEven&c.even)
+	c.even = c.even<<1 | feedin
+	c.odd, c.even = c.even, c.odd
+	return byte(ks)
+}
+
+/**
+ * Keystream byte, bits are fed LSB first.
+ */
+func (c *Crypto1) Byte(in byte, encrypted bool) byte {
+	var ks byte
+	for i := uint(0); i < 8; i++ {
+		ks |= c.Bit(in>>i, encrypted) << i
+	}
+	return ks
+}
+
+/**
+ * Keystream word. Words are big endian: the first byte on the air is the MSB.
+ */
+func (c *Crypto1) Word(in uint32, encrypted bool) uint32 {
+	var ks uint32
+	for i := uint(0); i < 32; i++ {
+		ks |= uint32(c.Bit(byte(in>>(i^24)), encrypted)) << (i ^ 24)
+	}
+	return ks
+}
+
+/**
+ * The keystream bit used to encrypt the parity bit of the byte just processed.
+ * It doesn't advance the cipher.
+ */
+func (c *Crypto1) ParityBit() byte {
+	return byte(crypto1Filter(c.odd))
+}
+
+/**
+ * Encrypts (or decrypts) data in place, returns encrypted parity bits.
+ * Parity is computed on the plain text, see ISO 14443-3 odd parity.
+ * After the authentication nothing is fed into the LFSR.
+ */
+func (c *Crypto1) Crypt(data []byte, encrypted bool) (parity []byte) {
+	parity = make([]byte, len(data))
+	for i, b := range data {
+		plain := b
+		ks := c.Byte(0, false)
+		if encrypted {
+			plain ^= ks
+		}
+		parity[i] = OddParity(plain) ^ c.ParityBit()
+		data[i] ^= ks
+	}
+	return
+}
+
+/**
+ * Keystream of a frame shorter than a byte, e.g. the 4 bit ACK.
+ */
+func (c *Crypto1) Bits(n int) byte {
+	var ks byte
+	for i := uint(0); i < uint(n); i++ {
+		ks |= c.Bit(0, false) << i
+	}
+	return ks
+}
+
+/**
+ * MIFARE PRNG successor function suc^n(x), x is big endian as it is on the air.
+ */
+func PRNGSuccessor(x uint32, n uint) uint32 {
+	x = x>>24 | x>>8&0xff00 | x<<8&0xff0000 | x<<24
+	for ; n > 0; n-- {
+		x = x>>1 | (x>>16^x>>18^x>>19^x>>21)<<31
+	}
+	return x>>24 | x>>8&0xff00 | x<<8&0xff0000 | x<<24
+}
+
+// ISO 14443-3 odd parity bit of one byte
+func OddParity(b byte) byte {
+	return byte(parity32(uint32(b))) ^ 1
+}
+
+func bytesToUint32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func uint32ToBytes(x uint32) []byte {
+	return []byte{byte(x >> 24), byte(x >> 16), byte(x >> 8), byte(x)}
+}
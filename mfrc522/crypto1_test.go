@@ -0,0 +1,60 @@
+package mfrc522
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// Authentication trace from the mfkey64 example (proxmark3 tools/mfkey)
+const (
+	traceUid   = 0x9c599b32
+	traceNt    = 0x82a4166c
+	traceNr    = 0xefea1cda
+	traceNrEnc = 0xa1e458ce
+	traceArEnc = 0x6eea41e0
+	traceAtEnc = 0x5cadf439
+)
+
+var traceKey = [6]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+func TestPRNGSuccessor(t *testing.T) {
+	is := is.New(t)
+	// LFSR16 test data (mifare_test.go), the second half of the nonce follows from the first one
+	is.Equal(PRNGSuccessor(0x00004297, 16), uint32(0x4297c0a4))
+	is.Equal(PRNGSuccessor(traceNt, 0), uint32(traceNt))
+	is.Equal(PRNGSuccessor(PRNGSuccessor(traceNt, 32), 32), PRNGSuccessor(traceNt, 64))
+}
+
+func TestCrypto1Trace(t *testing.T) {
+	is := is.New(t)
+
+	card := NewCrypto1(traceKey)
+	card.Word(traceUid^traceNt, false)
+	nr := card.Word(traceNrEnc, true) ^ traceNrEnc
+	is.Equal(nr, uint32(traceNr))
+	ar := card.Word(0, false) ^ traceArEnc
+	is.Equal(ar, PRNGSuccessor(traceNt, 64))
+	at := card.Word(0, false) ^ PRNGSuccessor(traceNt, 96)
+	is.Equal(at, uint32(traceAtEnc))
+
+	reader := NewCrypto1(traceKey)
+	reader.Word(traceUid^traceNt, false)
+	is.Equal(reader.Word(traceNr, false)^traceNr, uint32(traceNrEnc))
+	is.Equal(reader.Word(0, false)^PRNGSuccessor(traceNt, 64), uint32(traceArEnc))
+	is.Equal(reader.Word(0, false)^PRNGSuccessor(traceNt, 96), uint32(traceAtEnc))
+}
+
+func TestCrypto1Crypt(t *testing.T) {
+	is := is.New(t)
+
+	reader := NewCrypto1(traceKey)
+	card := NewCrypto1(traceKey)
+	data := []byte{PICC_CMD_MF_READ, 0x04, 0x26, 0xee}
+	plain := append([]byte{}, data...)
+
+	parity := reader.Crypt(data, false)
+	cardParity := card.Crypt(data, true)
+	is.Equal(string(data), string(plain))
+	is.Equal(string(parity), string(cardParity))
+}
@@ -0,0 +1,431 @@
+// Hardware diagnostics: one report to decide whether a reader module is broken.
+
+package mfrc522
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+type DiagnosticStatus string
+
+const (
+	DIAG_PASS DiagnosticStatus = "PASS"
+	DIAG_WARN DiagnosticStatus = "WARN" // works, but out of the specification
+	DIAG_FAIL DiagnosticStatus = "FAIL" // replace the hardware
+	DIAG_SKIP DiagnosticStatus = "SKIP" // not testable with this setup
+)
+
+const (
+	DIAG_SPI_ROUNDS      = 16                    // write/read rounds of the SPI integrity check
+	DIAG_TIMER_PERIOD    = 20 * time.Millisecond // period measured by the timer check
+	DIAG_TIMER_TOLERANCE = 0.25                  // relative error of the timer before a warning
+)
+
+type DiagnosticCheck struct {
+	Name   string           `json:"name"`
+	Status DiagnosticStatus `json:"status"`
+	Detail string           `json:"detail"`
+}
+
+type DiagnosticsReport struct {
+	Time    time.Time `json:"time"`
+	Version byte      `json:"version"`
+	Chip    string    `json:"chip"`
+	// Compatibility and Quirks of the chip, see ChipInfo
+	Compatibility string            `json:"compatibility"`
+	Quirks        string            `json:"quirks"`
+	Checks        []DiagnosticCheck `json:"checks"`
+	Replace       bool              `json:"replace"` // one of the checks failed
+}
+
+func (d *DiagnosticsReport) add(name string, status DiagnosticStatus, format string, args ...interface{}) {
+	d.Checks = append(d.Checks, DiagnosticCheck{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+	if status == DIAG_FAIL {
+		d.Replace = true
+	}
+}
+
+/**
+ * Result of the check name, nil if it did not run.
+ */
+func (d *DiagnosticsReport) Check(name string) *DiagnosticCheck {
+	for i := range d.Checks {
+		if d.Checks[i].Name == name {
+			return &d.Checks[i]
+		}
+	}
+	return nil
+}
+
+/**
+ * Human-readable report, one line per check and the verdict.
+ */
+func (d *DiagnosticsReport) WriteText(w io.Writer) error {
+	var buff bytes.Buffer
+	fmt.Fprintf(&buff, "MFRC522 diagnostics %s\n", d.Time.Format(time.RFC3339))
+	fmt.Fprintf(&buff, "chip: %s (VersionReg %02x, compatibility %s, quirks: %s)\n", d.Chip, d.Version, d.Compatibility, d.Quirks)
+	for _, c := range d.Checks {
+		fmt.Fprintf(&buff, "%-4s  %-10s %s\n", c.Status, c.Name, c.Detail)
+	}
+	if d.Replace {
+		buff.WriteString("verdict: REPLACE HARDWARE\n")
+	} else {
+		buff.WriteString("verdict: OK\n")
+	}
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+func (d *DiagnosticsReport) String() string {
+	var buff bytes.Buffer
+	d.WriteText(&buff)
+	return buff.String()
+}
+
+/**
+ * Indented JSON report.
+ */
+func (d *DiagnosticsReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
+
+/**
+ * Runs all checks: chip version, digital self-test, register reset values,
+ * SPI integrity, IRQ line, timer accuracy and the antenna drivers.
+ * A failed check does not stop the others, the error is only returned if
+ * ctx is done. The chip is reset and initialized like PCD_Init afterwards,
+ * the field is off during the checks.
+ */
+func (r *MFRC522) PCD_Diagnostics(ctx context.Context) (*DiagnosticsReport, error) {
+	if err := r.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+
+	d := &DiagnosticsReport{Time: time.Now()}
+	r.diagnoseVersion(d)
+	if err := r.reset(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		d.add("reset", DIAG_FAIL, "%v", err)
+	} else {
+		r.diagnoseResetValues(d)
+	}
+	r.diagnoseSelfTest(d)
+	r.diagnoseSPI(d)
+	r.diagnoseIRQ(d)
+	if err := r.diagnoseTimer(ctx, d); err != nil {
+		return nil, err
+	}
+	r.diagnoseAntenna(d)
+
+	if err := r.reset(ctx); err != nil {
+		return d, err
+	}
+	if err := r.initChip(); err != nil {
+		return d, err
+	}
+	r.logger.Log(LOG_INFO, "diagnostics", "chip", d.Chip, "replace", d.Replace)
+	return d, nil
+}
+
+func (r *MFRC522) diagnoseVersion(d *DiagnosticsReport) {
+	d.Chip = "unknown"
+	version, err := r.readRegister(VersionReg)
+	if err != nil {
+		d.add("version", DIAG_FAIL, "%v", err)
+		return
+	}
+	d.Version = version
+	chip, err := IdentifyChip(version)
+	if err != nil {
+		d.Chip = "none"
+		d.add("version", DIAG_FAIL, "%v: check the wiring and the power supply", err)
+		return
+	}
+	d.Chip, d.Compatibility, d.Quirks = chip.Name, chip.Compatibility.String(), chip.Quirks.String()
+	if chip.Compatibility == COMPAT_UNKNOWN {
+		d.add("version", DIAG_WARN, "%02x, unknown chip", version)
+		return
+	}
+	d.add("version", DIAG_PASS, "%02x %s, compatibility %s", version, chip.Name, chip.Compatibility)
+}
+
+// Registers with the reset values of the datasheet (9.2), the chip and the readback work
+var diagnosticResetValues = []struct {
+	name                 string
+	address, mask, value byte
+}{
+	{"ModeReg", ModeReg, 0xFF, 0x3F},
+	{"TxControlReg", TxControlReg, 0xFF, 0x80},
+	{"RxThresholdReg", RxThresholdReg, 0xFF, 0x84},
+	{"DemodReg", DemodReg, 0xFF, 0x4D},
+	{"ModWidthReg", ModWidthReg, 0xFF, 0x26},
+	{"RFCfgReg", RFCfgReg, 0x7F, 0x48},
+	{"GsNReg", GsNReg, 0xFF, 0x88},
+	{"CWGsPReg", CWGsPReg, 0x3F, 0x20},
+	{"ModGsPReg", ModGsPReg, 0x3F, 0x20},
+}
+
+func (r *MFRC522) diagnoseResetValues(d *DiagnosticsReport) {
+	var wrong []string
+	for _, reg := range diagnosticResetValues {
+		value, err := r.readRegister(reg.address)
+		if err != nil {
+			d.add("registers", DIAG_FAIL, "%v", err)
+			return
+		}
+		if value&reg.mask != reg.value {
+			wrong = append(wrong, fmt.Sprintf("%s %02x (reset value %02x)", reg.name, value, reg.value))
+		}
+	}
+	if len(wrong) > 0 {
+		d.add("registers", DIAG_FAIL, "after reset: %v", wrong)
+		return
+	}
+	d.add("registers", DIAG_PASS, "%d registers at their reset values", len(diagnosticResetValues))
+}
+
+/**
+ * The digital self-test, the core features of chips without a known result.
+ */
+func (r *MFRC522) diagnoseSelfTest(d *DiagnosticsReport) {
+	chip, err := IdentifyChip(d.Version)
+	if err != nil {
+		d.add("selftest", DIAG_SKIP, "no chip")
+		return
+	}
+	if chip.Quirks&QUIRK_NO_SELF_TEST == 0 {
+		result, err := r.selfTestResult()
+		switch {
+		case err != nil:
+			d.add("selftest", DIAG_FAIL, "%v", err)
+			return
+		case bytes.Compare(result, chip.SelfTest) == 0:
+			d.add("selftest", DIAG_PASS, "result matches %s", chip.Name)
+			return
+		case chip.Compatibility == COMPAT_FULL:
+			d.add("selftest", DIAG_FAIL, "result differs from %s: [% x]", chip.Name, result)
+			return
+		}
+		if err := r.checkCoreFeatures(); err != nil {
+			d.add("selftest", DIAG_FAIL, "result differs from %s and %v", chip.Name, err)
+			return
+		}
+		d.add("selftest", DIAG_WARN, "result differs from %s, the FIFO and the CRC coprocessor work", chip.Name)
+		return
+	}
+	if err := r.checkCoreFeatures(); err != nil {
+		d.add("selftest", DIAG_FAIL, "%v", err)
+		return
+	}
+	d.add("selftest", DIAG_PASS, "no reference result, the FIFO and the CRC coprocessor work")
+}
+
+/**
+ * Patterns on a scratch register (TReloadRegL) and bursts through the FIFO,
+ * flipped bits point at the wiring or the SPI clock.
+ */
+func (r *MFRC522) diagnoseSPI(d *DiagnosticsReport) {
+	patterns := []byte{0x00, 0xFF, 0x55, 0xAA}
+	for bit := uint(0); bit < 8; bit++ {
+		patterns = append(patterns, 1<<bit, ^byte(1<<bit))
+	}
+	corrupted, transfers := 0, 0
+	var first string
+	mismatch := func(format string, args ...interface{}) {
+		if corrupted == 0 {
+			first = fmt.Sprintf(format, args...)
+		}
+		corrupted++
+	}
+	for round := 0; round < DIAG_SPI_ROUNDS; round++ {
+		for _, pattern := range patterns {
+			transfers++
+			if err := r.writeRegister(TReloadRegL, pattern); err != nil {
+				d.add("spi", DIAG_FAIL, "%v", err)
+				return
+			}
+			value, err := r.readRegister(TReloadRegL)
+			if err != nil {
+				d.add("spi", DIAG_FAIL, "%v", err)
+				return
+			}
+			if value != pattern {
+				mismatch("wrote %02x, read %02x", pattern, value)
+			}
+		}
+
+		burst := make([]byte, FIFO_SIZE)
+		for i := range burst {
+			burst[i] = byte(i*37+round) ^ patterns[i%len(patterns)]
+		}
+		transfers++
+		if err := r.writeReg(FIFOLevel{FlushBuffer: true}); err != nil {
+			d.add("spi", DIAG_FAIL, "%v", err)
+			return
+		}
+		if err := r.writeFIFOBuffer(burst); err != nil {
+			d.add("spi", DIAG_FAIL, "%v", err)
+			return
+		}
+		read, err := r.readFIFOBuffer(len(burst))
+		if err != nil {
+			d.add("spi", DIAG_FAIL, "%v", err)
+			return
+		}
+		if bytes.Compare(read, burst) != 0 {
+			mismatch("FIFO burst wrote [% x], read [% x]", burst, read)
+		}
+	}
+	if corrupted > 0 {
+		d.add("spi", DIAG_FAIL, "%d of %d transfers corrupted, first: %s", corrupted, transfers, first)
+		return
+	}
+	d.add("spi", DIAG_PASS, "%d transfers, %d byte FIFO bursts", transfers, FIFO_SIZE)
+}
+
+/**
+ * Sets and clears IdleIRq with IdleIEn enabled: the IRQ pin (inverted,
+ * open drain after reset) goes low and back high.
+ */
+func (r *MFRC522) diagnoseIRQ(d *DiagnosticsReport) {
+	if r.irqPin == nil {
+		d.add("irq", DIAG_SKIP, "no IRQ pin configured")
+		return
+	}
+	level := func() gpio.Level {
+		time.Sleep(IRQ_POLL_INTERVAL)
+		return r.irqPin.Read()
+	}
+	var idle, active, released gpio.Level
+	err := func() error {
+		for _, w := range []RegisterValue{
+			ComIEn{IRqInv: true, IdleIEn: true},
+			ComIrqAll,
+		} {
+			if err := r.writeReg(w); err != nil {
+				return err
+			}
+		}
+		idle = level()
+		if err := r.writeReg(ComIrq{Set1: true, IdleIRq: true}); err != nil {
+			return err
+		}
+		active = level()
+		if err := r.writeReg(ComIrq{IdleIRq: true}); err != nil {
+			return err
+		}
+		released = level()
+		return r.writeReg(ComIEn{IRqInv: true})
+	}()
+	switch {
+	case err != nil:
+		d.add("irq", DIAG_FAIL, "%v", err)
+	case idle == gpio.High && active == gpio.Low && released == gpio.High:
+		d.add("irq", DIAG_PASS, "%s follows IdleIRq", r.irqPin)
+	case idle == active && active == released:
+		d.add("irq", DIAG_FAIL, "%s stuck %s: check the wiring and the pull-up", r.irqPin, idle)
+	default:
+		d.add("irq", DIAG_FAIL, "%s idle %s, active %s, released %s", r.irqPin, idle, active, released)
+	}
+}
+
+/**
+ * Measures DIAG_TIMER_PERIOD started with TStartNow against the host clock.
+ * The SPI polling adds up to IRQ_POLL_INTERVAL and the register access.
+ */
+func (r *MFRC522) diagnoseTimer(ctx context.Context, d *DiagnosticsReport) error {
+	fail := func(err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		d.add("timer", DIAG_FAIL, "%v", err)
+		return nil
+	}
+	// The crystal is measured: the registers are written without the
+	// quirks of setTimer and the host clock is compared to their period.
+	prescaler, reload, err := TimerSettings(DIAG_TIMER_PERIOD)
+	if err != nil {
+		return fail(err)
+	}
+	if err := r.writeTimer(prescaler, reload); err != nil {
+		return fail(err)
+	}
+	period := TimerPeriod(prescaler, reload)
+	value, err := r.readRegister(TModeReg)
+	if err != nil {
+		return fail(err)
+	}
+	mode := DecodeTMode(value)
+	mode.TAutoRestart = false
+	for _, w := range []RegisterValue{
+		mode,
+		ComIrq{TimerIRq: true}, // clear TimerIRq
+		Control{TStartNow: true},
+	} {
+		if err := r.writeReg(w); err != nil {
+			return fail(err)
+		}
+	}
+	start := time.Now()
+	if err := r.waitIRq(ctx, ComIrqReg, ComIrq{TimerIRq: true}.Encode(), 4*period); err != nil {
+		return fail(err)
+	}
+	elapsed := time.Since(start)
+	irq, err := r.readRegister(ComIrqReg)
+	if err != nil {
+		return fail(err)
+	}
+	if !DecodeComIrq(irq).TimerIRq {
+		d.add("timer", DIAG_FAIL, "no TimerIRq within %s", 4*period)
+		return nil
+	}
+	deviation := float64(elapsed-period) / float64(period)
+	if deviation < -DIAG_TIMER_TOLERANCE || deviation > DIAG_TIMER_TOLERANCE {
+		d.add("timer", DIAG_WARN, "%s measured for %s (%+.0f%%): check the crystal", elapsed.Round(time.Microsecond), period.Round(time.Microsecond), deviation*100)
+		return nil
+	}
+	d.add("timer", DIAG_PASS, "%s measured for %s (%+.0f%%)", elapsed.Round(time.Microsecond), period.Round(time.Microsecond), deviation*100)
+	return nil
+}
+
+/**
+ * Switches the antenna drivers on and checks Tx1RFEn, Tx2RFEn and the
+ * temperature sensor. The drivers are switched off again.
+ */
+func (r *MFRC522) diagnoseAntenna(d *DiagnosticsReport) {
+	if err := r.antennaOn(); err != nil {
+		d.add("antenna", DIAG_FAIL, "%v", err)
+		return
+	}
+	defer r.antennaOff()
+	control, err := r.readRegister(TxControlReg)
+	if err != nil {
+		d.add("antenna", DIAG_FAIL, "%v", err)
+		return
+	}
+	errorReg, err := r.readRegister(ErrorReg)
+	if err != nil {
+		d.add("antenna", DIAG_FAIL, "%v", err)
+		return
+	}
+	switch tx := DecodeTxControl(control); {
+	case DecodeErrorFlags(errorReg).TempErr:
+		d.add("antenna", DIAG_FAIL, "TempErr: the drivers overheat, check the antenna for a short circuit")
+	case !tx.Tx1RFEn || !tx.Tx2RFEn:
+		d.add("antenna", DIAG_FAIL, "TxControlReg %02x: Tx1RFEn and Tx2RFEn do not stay set", control)
+	default:
+		d.add("antenna", DIAG_PASS, "TX1 and TX2 driven, no TempErr")
+	}
+}
@@ -0,0 +1,96 @@
+package mfrc522
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+
+	"github.com/matryer/is"
+)
+
+func TestDiagnostics(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	reader := newSimulatedMFRC522(t, sim)
+	is.NoErr(reader.PCD_Init())
+
+	report, err := reader.PCD_Diagnostics(context.Background())
+	is.NoErr(err)
+	is.Equal(report.Chip, "MFRC522 v2.0")
+	for _, name := range []string{"version", "registers", "selftest", "spi", "irq", "timer", "antenna"} {
+		check := report.Check(name)
+		is.True(check != nil)
+		is.True(check.Status == DIAG_PASS || name == "timer" && check.Status == DIAG_WARN) // a loaded host delays the timer poll
+	}
+	is.True(!report.Replace)
+	is.True(strings.Contains(report.String(), "verdict: OK"))
+
+	var buff bytes.Buffer
+	is.NoErr(report.WriteJSON(&buff))
+	var decoded DiagnosticsReport
+	is.NoErr(json.Unmarshal(buff.Bytes(), &decoded))
+	is.Equal(decoded.Checks, report.Checks)
+	is.Equal(decoded.Version, byte(VER_2_0))
+
+	// The chip is initialized again, the antenna is off
+	tx, err := reader.PCD_ReadRegister(TxControlReg)
+	is.NoErr(err)
+	is.Equal(tx&0x03, byte(0))
+	is.True(!reader.PICC_IsNewCardPresent())
+}
+
+func TestDiagnosticsClones(t *testing.T) {
+	is := is.New(t)
+
+	sim := NewMFRC522Simulator()
+	sim.Version = VER_FM17522
+	report, err := newSimulatedMFRC522(t, sim).PCD_Diagnostics(context.Background())
+	is.NoErr(err)
+	is.Equal(report.Chip, "FM17522 clone")
+	is.Equal(report.Check("selftest").Status, DIAG_PASS)
+
+	sim = NewMFRC522Simulator()
+	sim.Version = VER_COUNTERFEIT
+	report, err = newSimulatedMFRC522(t, sim).PCD_Diagnostics(context.Background())
+	is.NoErr(err)
+	is.Equal(report.Compatibility, "limited")
+	is.Equal(report.Check("selftest").Status, DIAG_PASS) // the core features work
+	is.True(!report.Replace)
+}
+
+func TestDiagnosticsTimerOfClones(t *testing.T) {
+	is := is.New(t)
+	for _, version := range []byte{VER_COUNTERFEIT, VER_CLONE_B2} {
+		sim := NewMFRC522Simulator()
+		sim.Version = version
+		reader := newSimulatedMFRC522(t, sim)
+		is.NoErr(reader.PCD_Init()) // the quirks of the chip are known
+
+		report, err := reader.PCD_Diagnostics(context.Background())
+		is.NoErr(err)
+		timer := report.Check("timer")
+		is.Equal(timer.Status, DIAG_PASS) // the crystal is measured, not the timer quirk
+		is.True(strings.Contains(timer.Detail, " for 20ms "))
+	}
+}
+
+func TestDiagnosticsStuckIRQ(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	transport, err := NewSPITransport(sim)
+	is.NoErr(err)
+	reader, err := New(transport, WithIRQPin(&gpiotest.Pin{N: "IRQ", L: gpio.High}))
+	is.NoErr(err)
+
+	report, err := reader.PCD_Diagnostics(context.Background())
+	is.NoErr(err)
+	is.Equal(report.Check("irq").Status, DIAG_FAIL)
+	is.True(strings.Contains(report.Check("irq").Detail, "stuck High"))
+	is.True(report.Replace)
+	is.True(strings.Contains(report.String(), "REPLACE HARDWARE"))
+}
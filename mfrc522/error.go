@@ -2,52 +2,193 @@ package mfrc522
 
 import (
 	"errors"
+	"fmt"
 )
 
-type mfrc522Error struct{ error }
+// Sentinel errors, test with errors.Is
+var (
+	ErrTimeout            = errors.New("mfrc522: timeout")
+	ErrCRC                = errors.New("mfrc522: CRC error")
+	ErrCollision          = errors.New("mfrc522: collision")
+	ErrParity             = errors.New("mfrc522: parity error")
+	ErrProtocol           = errors.New("mfrc522: protocol error")
+	ErrBufferOverflow     = errors.New("mfrc522: FIFO buffer overflow")
+	ErrFIFOWrite          = errors.New("mfrc522: FIFO written at the wrong time")
+	ErrTemperature        = errors.New("mfrc522: overheating, antenna drivers switched off")
+	ErrUnexpectedIRq      = errors.New("mfrc522: unexpected interrupt")
+	ErrUnexpectedResponse = errors.New("mfrc522: unexpected response")
+	ErrSelection          = errors.New("mfrc522: selection failed")
+	ErrAuthentication     = errors.New("mfrc522: authentication failed")
+	ErrUsage              = errors.New("mfrc522: usage error")
+	ErrSUNVerification    = errors.New("mfrc522: SUN verification failed")
+	ErrConfig             = errors.New("mfrc522: invalid configuration")
+)
+
+/**
+ * Error of a PCD command with the register snapshot taken when it failed.
+ * Err is one of the sentinel errors.
+ */
+type CommunicationError struct {
+	Command      byte // PCD_Transceive, PCD_CalcCRC, ...
+	ComIrqReg    byte
+	ErrorReg     byte
+	CascadeLevel int // 1..3 during PICC_Select, 0 otherwise
+	Err          error
+}
+
+func (e *CommunicationError) Error() string {
+	s := fmt.Sprintf("%v (command %02x, ComIrqReg %08b, ErrorReg %08b", e.Err, e.Command, e.ComIrqReg, e.ErrorReg)
+	if e.CascadeLevel > 0 {
+		s += fmt.Sprintf(", cascade level %d", e.CascadeLevel)
+	}
+	return s + ")"
+}
+
+func (e *CommunicationError) Unwrap() error {
+	return e.Err
+}
+
+/**
+ * Maps the ErrorReg bits to a sentinel error, nil if no error bit is set.
+ * ErrorReg: WrErr TempErr - BufferOvfl CollErr CRCErr ParityErr ProtocolErr
+ */
+func errorRegError(errorReg byte) error {
+	errs := DecodeErrorFlags(errorReg)
+	switch {
+	case errs.TempErr:
+		return ErrTemperature
+	case errs.WrErr:
+		return ErrFIFOWrite
+	case errs.BufferOvfl:
+		return ErrBufferOverflow
+	case errs.CollErr:
+		return ErrCollision
+	case errs.CRCErr:
+		return ErrCRC
+	case errs.ParityErr:
+		return ErrParity
+	case errs.ProtocolErr:
+		return ErrProtocol
+	}
+	return nil
+}
+
+/**
+ * Reports whether repeating the operation may succeed: timeouts and
+ * transmission errors are retryable, a wrong key or a misuse are not.
+ */
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrAuthentication) || errors.Is(err, ErrUsage) || errors.Is(err, ErrTemperature) {
+		return false
+	}
+	for _, retryable := range []error{ErrTimeout, ErrCRC, ErrCollision, ErrParity, ErrProtocol, ErrBufferOverflow} {
+		if errors.Is(err, retryable) {
+			return true
+		}
+	}
+	return false
+}
+
+type mfrc522Error struct {
+	error
+	kind error
+}
+
+func (e mfrc522Error) Unwrap() error {
+	return e.kind
+}
 
 func UnexpectedIRqError(desc string) error {
-	return mfrc522Error{errors.New(desc)}
+	return mfrc522Error{errors.New(desc), ErrUnexpectedIRq}
 }
 
 func TimeoutIRqError(desc string) error {
-	return mfrc522Error{errors.New(desc)}
+	return mfrc522Error{errors.New(desc), ErrTimeout}
 }
 
-func CRCIRqError(desc string) error {
-	return mfrc522Error{errors.New(desc)}
+// The CRC coprocessor did not raise CRCIRq in time
+func CRCTimeoutError(desc string) error {
+	return mfrc522Error{errors.New(desc), ErrTimeout}
 }
 
 func ErrIRqError(desc string) error {
-	return mfrc522Error{errors.New(desc)}
+	return mfrc522Error{errors.New(desc), ErrProtocol}
+}
+
+type iso14443Error struct {
+	error
+	kind error
 }
 
-type iso14443Error struct{ error }
+func (e iso14443Error) Unwrap() error {
+	return e.kind
+}
 
 func SelectionError(desc string) error {
-	return iso14443Error{errors.New(desc)}
+	return iso14443Error{errors.New(desc), ErrSelection}
 }
 
 func CollErrError(desc string) error {
-	return iso14443Error{errors.New(desc)}
+	return iso14443Error{errors.New(desc), ErrCollision}
 }
 
 func UnexpectedResponse(desc string) error {
-	return iso14443Error{errors.New(desc)}
+	return iso14443Error{errors.New(desc), ErrUnexpectedResponse}
 }
 
 func CommonError(desc string) error {
-	return iso14443Error{errors.New(desc)}
+	return iso14443Error{errors.New(desc), nil}
 }
 
 func CRCCheckError(desc string) error {
-	return iso14443Error{errors.New(desc)}
+	return iso14443Error{errors.New(desc), ErrCRC}
+}
+
+func ParityCheckError(desc string) error {
+	return iso14443Error{errors.New(desc), ErrParity}
 }
 
 func UsageError(desc string) error {
-	return mfrc522Error{errors.New(desc)}
+	return mfrc522Error{errors.New(desc), ErrUsage}
 }
 
 func AuthentificationError(desc string) error {
-	return mfrc522Error{errors.New(desc)}
+	return mfrc522Error{errors.New(desc), ErrAuthentication}
+}
+
+/**
+ * Authentication failure caused by err, matches both ErrAuthentication and err.
+ */
+type authError struct{ err error }
+
+func (e authError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrAuthentication, e.err)
+}
+
+func (e authError) Is(target error) bool {
+	return target == ErrAuthentication
+}
+
+func (e authError) Unwrap() error {
+	return e.err
+}
+
+type ntag424Error struct {
+	error
+	kind error
+}
+
+func (e ntag424Error) Unwrap() error {
+	return e.kind
+}
+
+func SUNVerificationError(desc string) error {
+	return ntag424Error{errors.New(desc), ErrSUNVerification}
+}
+
+func ConfigError(desc string) error {
+	return mfrc522Error{errors.New(desc), ErrConfig}
 }
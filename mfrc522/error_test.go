@@ -0,0 +1,77 @@
+package mfrc522
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestErrorTimeout(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t)
+
+	_, err := reader.PICC_RequestA()
+	is.True(errors.Is(err, ErrTimeout))
+	is.True(!errors.Is(err, ErrCRC))
+	is.True(IsRetryable(err))
+
+	var commErr *CommunicationError
+	is.True(errors.As(err, &commErr))
+	is.Equal(commErr.Command, byte(PCD_Transceive))
+	is.Equal(commErr.ComIrqReg&0x20, byte(0)) // no RxIRq
+}
+
+func TestErrorAuthentication(t *testing.T) {
+	is := is.New(t)
+	card := NewVirtualMifareClassic1K([]byte{0x9c, 0x59, 0x9b, 0x32})
+	reader, _ := newVirtualReader(t, card)
+
+	is.True(reader.PICC_IsNewCardPresent())
+	uid, err := reader.PICC_Select()
+	is.NoErr(err)
+
+	card.Blocks[7][0] = 0x00
+	err = reader.PICC_AuthentificateKeyA(*uid, defaultKey, 4)
+	is.True(errors.Is(err, ErrAuthentication))
+	is.True(errors.Is(err, ErrTimeout))
+	is.True(!IsRetryable(err))
+}
+
+func TestErrorSelectCollision(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t,
+		NewVirtualMifareClassic1K([]byte{0x11, 0x22, 0x33, 0x44}),
+		NewVirtualMifareClassic1K([]byte{0x11, 0x22, 0x37, 0x44}))
+
+	// A collision is no error of the frame, the anticollision loop resolves it
+	is.True(reader.PICC_IsNewCardPresent())
+	resp, err := reader.PCD_TransceiveFrame(context.Background(), Frame{Data: []byte{PICC_CMD_SEL_CL1, 0x20}}, 0, INTERUPT_TIMEOUT)
+	is.NoErr(err)
+	is.Equal(resp.BitLen(), 40)
+	is.Equal(resp.Data[2], byte(0x03)) // ValuesAfterColl is 0: the bits from the collision on are cleared
+	pos, err := reader.PCD_CollisionPosition()
+	is.NoErr(err)
+	is.Equal(pos, 19)
+
+	is.True(IsRetryable(CollErrError("collision")))
+	is.True(errors.Is(CollErrError("collision"), ErrCollision))
+}
+
+func TestErrorRegError(t *testing.T) {
+	is := is.New(t)
+	is.Equal(errorRegError(0x00), nil)
+	is.Equal(errorRegError(0x01), ErrProtocol)
+	is.Equal(errorRegError(0x02), ErrParity)
+	is.Equal(errorRegError(0x04), ErrCRC)
+	is.Equal(errorRegError(0x08), ErrCollision)
+	is.Equal(errorRegError(0x10), ErrBufferOverflow)
+	is.Equal(errorRegError(0x40), ErrTemperature)
+	is.Equal(errorRegError(0x80), ErrFIFOWrite)
+	is.True(!IsRetryable(ErrFIFOWrite))
+	is.True(errors.Is(CRCTimeoutError("x"), ErrTimeout))
+	is.True(errors.Is(UsageError("x"), ErrUsage))
+	is.True(errors.Is(SUNVerificationError("x"), ErrSUNVerification))
+	is.True(errors.Is(CommonError("x"), ErrUsage) == false)
+}
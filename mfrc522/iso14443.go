@@ -28,10 +28,16 @@ const (
 	PICC_CMD_SEL_CL2 = 0x95 // Anti collision/Select, Cascade Level 2
 	PICC_CMD_SEL_CL3 = 0x97 // Anti collision/Select, Cascade Level 3
 
+	// ISO14443-4 Commands
+	PICC_CMD_RATS = 0xE0 // Request for Answer To Select
+	PICC_CMD_PPS  = 0xD0 // Protocol and Parameter Selection, low nibble is CID
+
 	ISO_14443_CRC_RESET = 0x6363
 
 	// interupt timeout
 	INTERUPT_TIMEOUT = 5 * time.Millisecond
+
+	IRQ_POLL_INTERVAL = 250 * time.Microsecond
 )
 
 type PICC_TYPE = int
@@ -42,6 +48,88 @@ type UID struct {
 	PicType PICC_TYPE
 }
 
+// Bit oriented frame of ISO/IEC 14443-3. Bits are sent LSB first.
+// LastBits is the number of valid bits in the last byte, 0 means the whole byte is valid.
+// Parity holds the parity bit of each whole byte, nil means odd parity.
+type Frame struct {
+	Data     []byte
+	LastBits byte
+	Parity   []byte
+}
+
+/**
+ * Number of bits in the frame.
+ */
+func (f Frame) BitLen() int {
+	if len(f.Data) == 0 {
+		return 0
+	}
+	if f.LastBits == 0 {
+		return len(f.Data) * 8
+	}
+	return (len(f.Data)-1)*8 + int(f.LastBits)
+}
+
+/**
+ * Value of bit i, i < BitLen().
+ */
+func (f Frame) Bit(i int) byte {
+	return (f.Data[i/8] >> uint(i%8)) & 1
+}
+
+/**
+ * Builds a frame from single bits.
+ */
+func FrameFromBits(bits []byte) Frame {
+	data := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		data[i/8] |= (bit & 1) << uint(i%8)
+	}
+	return Frame{Data: data, LastBits: byte(len(bits) % 8)}
+}
+
+/**
+ * Parity bit of byte i, the odd parity if Parity is not set.
+ */
+func (f Frame) ParityBit(i int) byte {
+	if i < len(f.Parity) {
+		return f.Parity[i] & 1
+	}
+	return OddParity(f.Data[i])
+}
+
+/**
+ * The bit stream on the air: every whole byte is followed by its parity bit,
+ * a partial last byte has no parity. Sent with ParityDisable, see PCD_TransceiveRaw.
+ */
+func PackParity(f Frame) Frame {
+	bits := make([]byte, 0, f.BitLen()+len(f.Data))
+	for i := 0; i < f.BitLen(); i++ {
+		bits = append(bits, f.Bit(i))
+		if i%8 == 7 {
+			bits = append(bits, f.ParityBit(i/8))
+		}
+	}
+	return FrameFromBits(bits)
+}
+
+/**
+ * Splits a bit stream received with ParityDisable into data and parity bits.
+ */
+func UnpackParity(raw Frame) Frame {
+	var bits, parity []byte
+	for i := 0; i < raw.BitLen(); i++ {
+		if i%9 == 8 {
+			parity = append(parity, raw.Bit(i))
+		} else {
+			bits = append(bits, raw.Bit(i))
+		}
+	}
+	f := FrameFromBits(bits)
+	f.Parity = parity
+	return f
+}
+
 // Calculate an ISO 14443a CRC. Code translated from the code in
 // iso14443a_crc().
 func ISO14443aCRC(data []byte) []byte {
@@ -0,0 +1,374 @@
+// ISO/IEC 14443-4 activation: RATS, ATS and PPS with the bit rates of the MFRC522.
+
+package mfrc522
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+type BitRate byte
+
+// TxSpeed and RxSpeed values of TxModeReg and RxModeReg
+const (
+	BIT_RATE_106 BitRate = iota
+	BIT_RATE_212
+	BIT_RATE_424
+	BIT_RATE_848
+
+	bitRateUnknown BitRate = 0xFF // cached rate when the registers failed to be written
+)
+
+func (b BitRate) String() string {
+	switch b {
+	case BIT_RATE_106:
+		return "106 kbit/s"
+	case BIT_RATE_212:
+		return "212 kbit/s"
+	case BIT_RATE_424:
+		return "424 kbit/s"
+	case BIT_RATE_848:
+		return "848 kbit/s"
+	}
+	return fmt.Sprintf("BitRate(%d)", byte(b))
+}
+
+// ModWidthReg of the bit rates, the pause is shorter at higher rates.
+// The pause follows the bit duration, the other RF registers don't: the receiver
+// gain, the thresholds and the conductances depend on the antenna and are
+// kept as set by RFConfig or the calibration at all rates.
+var modWidth = [...]byte{
+	BIT_RATE_106: 0x26,
+	BIT_RATE_212: 0x15,
+	BIT_RATE_424: 0x0A,
+	BIT_RATE_848: 0x05,
+}
+
+const (
+	// RATS parameter: FSDI 5 (64 bytes, the size of the FIFO), CID 0
+	RATS_PARAM = 0x50
+
+	// Frame waiting time of RATS and of the first block after ATS: 65536/fc
+	ACTIVATION_FWT = 65536 * time.Second / PCD_CLOCK
+
+	PPS_PPS0_PPS1 = 0x11 // PPS0: PPS1 is transmitted
+
+	PCB_R_NAK    = 0xB2 // R(NAK) with block number 0
+	PCB_DESELECT = 0xC2 // S(DESELECT) without CID
+
+	// The field is off that long to reset the PICCs, ISO/IEC 14443-3 asks for at least 5ms
+	FIELD_RESET_TIME = 5 * time.Millisecond
+)
+
+// Frame sizes of FSCI (FSDI)
+var frameSizes = [...]int{16, 24, 32, 40, 48, 64, 96, 128, 256}
+
+// Answer To Select of an ISO/IEC 14443-4 PICC
+type ATS struct {
+	FSCI       byte // maximum frame size of the PICC, see FSC
+	DS         byte // PICC to PCD bit rates: bit 0 212, bit 1 424, bit 2 848 kbit/s
+	DR         byte // PCD to PICC bit rates, like DS
+	SameD      bool // both directions use the same bit rate
+	FWI        byte // frame waiting time integer, see FWT
+	SFGI       byte // start-up frame guard time integer
+	CID        bool // CID is supported
+	NAD        bool // NAD is supported
+	Historical []byte
+}
+
+/**
+ * Parses the ATS without CRC_A. Missing interface bytes have the default values.
+ */
+func ParseATS(data []byte) (*ATS, error) {
+	if len(data) < 1 || int(data[0]) != len(data) {
+		return nil, UnexpectedResponse(fmt.Sprintf("Unexpected ATS: [% x]", data))
+	}
+	ats := &ATS{FSCI: 2, FWI: 4, CID: true}
+	if len(data) == 1 {
+		return ats, nil
+	}
+	t0 := data[1]
+	ats.FSCI = t0 & 0x0F
+	// Y1: TA, TB and TC announced by T0 must be present
+	if len(data) < 2+bits.OnesCount8(t0&0x70) {
+		return nil, UnexpectedResponse(fmt.Sprintf("ATS too short: [% x]", data))
+	}
+	i := 2
+	next := func(present byte) (byte, bool) {
+		if t0&present == 0 {
+			return 0, false
+		}
+		i++
+		return data[i-1], true
+	}
+	if ta, ok := next(0x10); ok {
+		ats.SameD = ta&0x80 != 0
+		ats.DS = ta >> 4 & 0x07
+		ats.DR = ta & 0x07
+	}
+	if tb, ok := next(0x20); ok {
+		ats.FWI = tb >> 4
+		ats.SFGI = tb & 0x0F
+	}
+	if tc, ok := next(0x40); ok {
+		ats.CID = tc&0x02 != 0
+		ats.NAD = tc&0x01 != 0
+	}
+	ats.Historical = append([]byte{}, data[i:]...)
+	return ats, nil
+}
+
+/**
+ * Maximum frame size of the PICC in bytes.
+ */
+func (a *ATS) FSC() int {
+	if int(a.FSCI) >= len(frameSizes) {
+		return frameSizes[len(frameSizes)-1]
+	}
+	return frameSizes[a.FSCI]
+}
+
+/**
+ * Frame waiting time of the blocks.
+ */
+func (a *ATS) FWT() time.Duration {
+	return FrameWaitingTime(a.FWI)
+}
+
+/**
+ * Start-up frame guard time, the PCD waits it after ATS.
+ */
+func (a *ATS) SFGT() time.Duration {
+	if a.SFGI == 0 || a.SFGI == 15 {
+		return 0
+	}
+	return FrameWaitingTime(a.SFGI)
+}
+
+func supportsBitRate(mask byte, rate BitRate) bool {
+	return rate == BIT_RATE_106 || mask&(1<<(rate-1)) != 0
+}
+
+/**
+ * The highest bit rates up to max supported by the PICC:
+ * dr from PCD to PICC and ds from PICC to PCD.
+ */
+func (a *ATS) BitRates(max BitRate) (dr, ds BitRate) {
+	if max > BIT_RATE_848 {
+		max = BIT_RATE_848
+	}
+	for dr = max; !supportsBitRate(a.DR, dr); dr-- {
+	}
+	for ds = max; !supportsBitRate(a.DS, ds); ds-- {
+	}
+	if a.SameD {
+		for dr = max; !supportsBitRate(a.DR, dr) || !supportsBitRate(a.DS, dr); dr-- {
+		}
+		ds = dr
+	}
+	return
+}
+
+/**
+ * Sets TxSpeed, RxSpeed and the modulation width of tx, see modWidth.
+ */
+func (r *MFRC522) PCD_SetBitRate(tx, rx BitRate) error {
+	r.lock()
+	defer r.unlock()
+	return r.setBitRate(tx, rx)
+}
+
+/**
+ * Current TxSpeed and RxSpeed, unknown (255) after a failed change.
+ */
+func (r *MFRC522) PCD_BitRate() (tx, rx BitRate) {
+	r.lock()
+	defer r.unlock()
+	return r.txRate, r.rxRate
+}
+
+func (r *MFRC522) setBitRate(tx, rx BitRate) error {
+	if tx > BIT_RATE_848 || rx > BIT_RATE_848 {
+		return UsageError(fmt.Sprintf("Unexpected bit rate: %s, %s", tx, rx))
+	}
+	if tx == r.txRate && rx == r.rxRate {
+		return nil
+	}
+	r.logger.Log(LOG_DEBUG, "bit rate", "tx", tx, "rx", rx)
+	r.txRate, r.rxRate = bitRateUnknown, bitRateUnknown // until all registers are written
+	value, err := r.readRegister(TxModeReg)
+	if err != nil {
+		return err
+	}
+	txMode := DecodeTxMode(value)
+	txMode.TxSpeed = tx
+	if err := r.writeReg(txMode); err != nil {
+		return err
+	}
+	if value, err = r.readRegister(RxModeReg); err != nil {
+		return err
+	}
+	rxMode := DecodeRxMode(value)
+	rxMode.RxSpeed = rx
+	if err := r.writeReg(rxMode); err != nil {
+		return err
+	}
+	if err := r.writeRegister(ModWidthReg, modWidth[tx]); err != nil {
+		return err
+	}
+	r.txRate, r.rxRate = tx, rx
+	return nil
+}
+
+/**
+ * Request for Answer To Select, the selected PICC enters the ISO/IEC 14443-4 protocol.
+ */
+func (r *MFRC522) rats(ctx context.Context) (*ATS, error) {
+	result, err := r.transceivePICC(ctx, []byte{PICC_CMD_RATS, RATS_PARAM}, true, ACTIVATION_FWT)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Log(LOG_DEBUG, "ATS", "data", result.Data)
+	ats, err := ParseATS(result.Data)
+	if err != nil {
+		return nil, err
+	}
+	if sfgt := ats.SFGT(); sfgt > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(sfgt):
+		}
+	}
+	return ats, nil
+}
+
+/**
+ * Protocol and Parameter Selection of the bit rates, the PICC answers at the old rate.
+ */
+func (r *MFRC522) pps(ctx context.Context, ats *ATS, dr, ds BitRate) error {
+	result, err := r.transceivePICC(ctx, []byte{PICC_CMD_PPS, PPS_PPS0_PPS1, byte(ds)<<2 | byte(dr)}, true, ats.FWT())
+	if err != nil {
+		return err
+	}
+	if len(result.Data) != 1 || result.Data[0] != PICC_CMD_PPS {
+		return UnexpectedResponse(fmt.Sprintf("Unexpected PPS response: [% x]", result.Data))
+	}
+	return nil
+}
+
+/**
+ * A R(NAK) before the first I-block is answered by R(ACK), it checks the new bit rate.
+ */
+func (r *MFRC522) checkBitRate(ctx context.Context, ats *ATS) error {
+	result, err := r.transceivePICC(ctx, []byte{PCB_R_NAK}, true, ats.FWT())
+	if err != nil {
+		return err
+	}
+	if len(result.Data) != 1 || result.Data[0]&0xE6 != 0xA2 {
+		return UnexpectedResponse(fmt.Sprintf("Unexpected R-block: [% x]", result.Data))
+	}
+	return nil
+}
+
+/**
+ * Activates the ISO/IEC 14443-4 protocol of the card (RATS) and switches to the
+ * highest bit rates up to max supported by the card (PPS). The exchange is
+ * checked at the new rates. If the card fails, the field is reset and the card
+ * is activated again at 106 kbit/s. Blocks use the timeout ATS.FWT().
+ */
+func (s *Session) ActivateISO14443_4(ctx context.Context, max BitRate) (*ATS, error) {
+	r, err := s.active()
+	if err != nil {
+		return nil, err
+	}
+	ats, err := r.rats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.ATS = ats
+
+	dr, ds := ats.BitRates(max)
+	if dr == BIT_RATE_106 && ds == BIT_RATE_106 {
+		return ats, nil
+	}
+	if err = r.pps(ctx, ats, dr, ds); err != nil {
+		// The card keeps 106 kbit/s without an answer to PPS
+		r.logger.Log(LOG_WARN, "PPS failed", "dr", dr, "ds", ds, "err", err)
+		return ats, nil
+	}
+	if err = r.setBitRate(dr, ds); err == nil {
+		if err = r.checkBitRate(ctx, ats); err == nil {
+			return ats, nil
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// The card keeps the new rates until it is deactivated
+	r.logger.Log(LOG_WARN, "bit rate failed, back to 106 kbit/s", "dr", dr, "ds", ds, "err", err)
+	if err = r.reactivate(ctx); err != nil {
+		return nil, err
+	}
+	if s.ATS, err = r.rats(ctx); err != nil {
+		return nil, err
+	}
+	return s.ATS, nil
+}
+
+/**
+ * Field reset, then WUPA and select of the card at 106 kbit/s.
+ */
+func (r *MFRC522) reactivate(ctx context.Context) error {
+	if err := r.setBitRate(BIT_RATE_106, BIT_RATE_106); err != nil {
+		return err
+	}
+	if err := r.antennaOff(); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(FIELD_RESET_TIME):
+	}
+	if err := r.antennaOn(); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(FIELD_RESET_TIME):
+	}
+	if _, err := r.requestWUPA(ctx); err != nil {
+		return err
+	}
+	_, err := r.selectPICC(ctx)
+	return err
+}
+
+/**
+ * Bit rates of the session, see ActivateISO14443_4.
+ */
+func (s *Session) BitRate() (dr, ds BitRate) {
+	if s.reader == nil {
+		return BIT_RATE_106, BIT_RATE_106
+	}
+	return s.reader.txRate, s.reader.rxRate
+}
+
+/**
+ * S(DESELECT), the card enters the HALT state.
+ */
+func (r *MFRC522) deselect(ctx context.Context, ats *ATS) error {
+	result, err := r.transceivePICC(ctx, []byte{PCB_DESELECT}, true, ats.FWT())
+	if err != nil {
+		return err
+	}
+	if len(result.Data) != 1 || result.Data[0] != PCB_DESELECT {
+		return UnexpectedResponse(fmt.Sprintf("Unexpected DESELECT response: [% x]", result.Data))
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package mfrc522
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestParseATS(t *testing.T) {
+	is := is.New(t)
+
+	// DESFire EV1: FSCI 5, TA 0x77, FWI 8 SFGI 1, CID, historical byte 0x80
+	ats, err := ParseATS([]byte{0x06, 0x75, 0x77, 0x81, 0x02, 0x80})
+	is.NoErr(err)
+	is.Equal(ats.FSC(), 64)
+	is.Equal(ats.DS, byte(0x07))
+	is.Equal(ats.DR, byte(0x07))
+	is.True(!ats.SameD)
+	is.Equal(ats.FWI, byte(8))
+	is.Equal(ats.SFGI, byte(1))
+	is.True(ats.CID && !ats.NAD)
+	is.True(bytes.Compare(ats.Historical, []byte{0x80}) == 0)
+	is.Equal(ats.FWT(), FrameWaitingTime(8))
+
+	// Only TL: the default values
+	ats, err = ParseATS([]byte{0x01})
+	is.NoErr(err)
+	is.Equal(ats.FSC(), 32)
+	is.Equal(ats.FWI, byte(4))
+	is.Equal(ats.SFGT(), time.Duration(0))
+
+	_, err = ParseATS([]byte{0x05, 0x78})
+	is.True(err != nil)
+	_, err = ParseATS([]byte{0x02, 0x70}) // TA, TB and TC announced, none present
+	is.True(errors.Is(err, ErrUnexpectedResponse))
+	_, err = ParseATS([]byte{0x03, 0x30, 0x77}) // TB missing
+	is.True(errors.Is(err, ErrUnexpectedResponse))
+}
+
+func TestATSBitRates(t *testing.T) {
+	is := is.New(t)
+	ats := &ATS{DS: 0x03, DR: 0x01}
+	dr, ds := ats.BitRates(BIT_RATE_848)
+	is.Equal(dr, BIT_RATE_212)
+	is.Equal(ds, BIT_RATE_424)
+	dr, ds = ats.BitRates(BIT_RATE_212)
+	is.Equal(dr, BIT_RATE_212)
+	is.Equal(ds, BIT_RATE_212)
+
+	ats.SameD = true
+	ats.DS = 0x02
+	dr, ds = ats.BitRates(BIT_RATE_848)
+	is.Equal(dr, BIT_RATE_106)
+	is.Equal(ds, BIT_RATE_106)
+}
+
+// Drops the frames at failRate, like a card with a bad antenna
+type lossyField struct {
+	*VirtualField
+	failRate BitRate
+	tx       BitRate
+}
+
+func (f *lossyField) SetBitRate(tx, rx BitRate) {
+	f.tx = tx
+	f.VirtualField.SetBitRate(tx, rx)
+}
+
+func (f *lossyField) Transceive(frame Frame) (Frame, int, bool) {
+	if f.tx == f.failRate {
+		return Frame{}, -1, false
+	}
+	return f.VirtualField.Transceive(frame)
+}
+
+func newISO14443_4Card() *VirtualISO14443_4 {
+	card := NewVirtualISO14443_4([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, func(apdu []byte) []byte {
+		return append(apdu, 0x91, 0x00)
+	})
+	card.ATS = []byte{0x06, 0x75, 0x77, 0x81, 0x02, 0x80}
+	return card
+}
+
+func TestActivateISO14443_4(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t, newISO14443_4Card())
+	ctx := context.Background()
+
+	session, err := reader.AcquireSession(ctx)
+	is.NoErr(err)
+	ats, err := session.ActivateISO14443_4(ctx, BIT_RATE_424)
+	is.NoErr(err)
+	is.Equal(ats.FWI, byte(8))
+	dr, ds := session.BitRate()
+	is.Equal(dr, BIT_RATE_424)
+	is.Equal(ds, BIT_RATE_424)
+	modWidth, err := reader.readRegister(ModWidthReg)
+	is.NoErr(err)
+	is.Equal(modWidth, byte(0x0A))
+
+	// I-block at 424 kbit/s
+	block := []byte{0x02, 0x90, 0x60, 0x00, 0x00, 0x00}
+	resp, err := session.TransceiveTimeout(ctx, append(block, ISO14443aCRC(block)...), ats.FWT())
+	is.NoErr(err)
+	is.True(bytes.Compare(resp[:len(resp)-2], append(block, 0x91, 0x00)) == 0)
+
+	// DESELECT, the reader is back at 106 kbit/s
+	is.NoErr(session.Release())
+	tx, rx := reader.PCD_BitRate()
+	is.Equal(tx, BIT_RATE_106)
+	is.Equal(rx, BIT_RATE_106)
+	is.True(!reader.PICC_IsNewCardPresent())
+	_, err = reader.PICC_RequestWUPA()
+	is.NoErr(err)
+}
+
+func TestActivateISO14443_4Fallback(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	field := &lossyField{VirtualField: NewVirtualField(newISO14443_4Card()), failRate: BIT_RATE_848}
+	sim.Field = field
+	reader := newSimulatedMFRC522(t, sim)
+	is.NoErr(reader.PCD_Init())
+	is.NoErr(reader.PCD_AntennaOn())
+	ctx := context.Background()
+
+	session, err := reader.AcquireSession(ctx)
+	is.NoErr(err)
+	defer session.Release()
+	_, err = session.ActivateISO14443_4(ctx, BIT_RATE_848)
+	is.NoErr(err)
+	dr, ds := session.BitRate()
+	is.Equal(dr, BIT_RATE_106)
+	is.Equal(ds, BIT_RATE_106)
+
+	block := []byte{0x02, 0x90, 0x60, 0x00, 0x00, 0x00}
+	resp, err := session.Transceive(ctx, append(block, ISO14443aCRC(block)...))
+	is.NoErr(err)
+	is.True(bytes.Compare(resp[:len(resp)-2], append(block, 0x91, 0x00)) == 0)
+}
+
+// Fails the writes to address while fail is set
+type failingRegister struct {
+	Transport
+	address byte
+	fail    bool
+}
+
+func (t *failingRegister) WriteRegister(address byte, values ...byte) error {
+	if t.fail && address == t.address {
+		return errors.New("SPI transfer failed")
+	}
+	return t.Transport.WriteRegister(address, values...)
+}
+
+func TestSetBitRateFailure(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	spi, err := NewSPITransport(sim)
+	is.NoErr(err)
+	transport := &failingRegister{Transport: spi, address: ModWidthReg}
+	reader, err := New(transport)
+	is.NoErr(err)
+	is.NoErr(reader.PCD_Init())
+
+	// TxModeReg is written, ModWidthReg isn't: the cached rate is unknown
+	transport.fail = true
+	is.True(reader.PCD_SetBitRate(BIT_RATE_424, BIT_RATE_424) != nil)
+	tx, rx := reader.PCD_BitRate()
+	is.Equal(tx, bitRateUnknown)
+	is.Equal(rx, bitRateUnknown)
+	is.True(reader.PCD_Init() != nil)
+
+	transport.fail = false
+	is.NoErr(reader.PCD_SetBitRate(BIT_RATE_424, BIT_RATE_424))
+	modWidth, err := reader.PCD_ReadRegister(ModWidthReg)
+	is.NoErr(err)
+	is.Equal(modWidth, byte(0x0A))
+}
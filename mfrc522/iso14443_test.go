@@ -2,6 +2,8 @@ package mfrc522
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/matryer/is"
@@ -11,3 +13,146 @@ func TestISO14443aCRC(t *testing.T) {
 	is := is.New(t)
 	is.True(bytes.Compare(ISO14443aCRC([]byte{0x60, 0x30}), []byte{0x76, 0x4a}) == 0)
 }
+
+func TestBitFraming(t *testing.T) {
+	is := is.New(t)
+	uid := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	reader, _ := newVirtualReader(t, NewVirtualNTAG213(uid))
+	ctx := context.Background()
+
+	// REQA is a short frame of 7 bits, ATQA has whole bytes
+	atqa, err := reader.PCD_TransceiveFrame(ctx, Frame{Data: []byte{PICC_CMD_REQA}, LastBits: 7}, 0, INTERUPT_TIMEOUT)
+	is.NoErr(err)
+	is.Equal(atqa.LastBits, byte(0))
+	is.Equal(len(atqa.Data), 2)
+
+	// Known bits of the UID: the answer starts at RxAlign, the bits below are zero
+	resp, err := reader.PCD_TransceiveFrame(ctx, Frame{Data: []byte{PICC_CMD_SEL_CL1, 0x23, 0x88}, LastBits: 3}, 3, INTERUPT_TIMEOUT)
+	is.NoErr(err)
+	is.Equal(resp.BitLen(), 40)
+	is.Equal(resp.Data[0], byte(0x88)&^0x07)
+	is.True(bytes.Compare(resp.Data[1:4], uid[:3]) == 0)
+
+	// The answer to WRITE is a 4 bit ACK
+	_, err = reader.PICC_Select()
+	is.NoErr(err)
+	cmd := []byte{PICC_CMD_UL_WRITE, 0x05, 0xde, 0xad, 0xbe, 0xef}
+	ack, err := reader.PCD_TransceiveFrame(ctx, Frame{Data: append(cmd, ISO14443aCRC(cmd)...)}, 0, INTERUPT_TIMEOUT)
+	is.NoErr(err)
+	is.Equal(ack.LastBits, byte(4))
+	is.Equal(ack.Data[0], byte(MF_ACK))
+}
+
+func TestPackParity(t *testing.T) {
+	is := is.New(t)
+	f := Frame{Data: []byte{0x93, 0x20, 0x05}, LastBits: 4}
+	raw := PackParity(f)
+	is.Equal(raw.BitLen(), 2*9+4)
+	is.Equal(raw.Bit(8), OddParity(0x93))
+	is.Equal(raw.Bit(17), OddParity(0x20))
+
+	unpacked := UnpackParity(raw)
+	is.True(bytes.Compare(unpacked.Data, f.Data) == 0)
+	is.Equal(unpacked.LastBits, byte(4))
+	is.True(bytes.Compare(unpacked.Parity, []byte{OddParity(0x93), OddParity(0x20)}) == 0)
+
+	// Explicit parity bits are kept
+	f = Frame{Data: []byte{0x30, 0x04}, Parity: []byte{0, 1}}
+	unpacked = UnpackParity(PackParity(f))
+	is.True(bytes.Compare(unpacked.Parity, f.Parity) == 0)
+}
+
+func TestTransceiveRaw(t *testing.T) {
+	is := is.New(t)
+	uid := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	reader, _ := newVirtualReader(t, NewVirtualNTAG213(uid))
+	ctx := context.Background()
+	is.True(reader.PICC_IsNewCardPresent())
+	_, err := reader.PICC_Select()
+	is.NoErr(err)
+
+	// The chip appends and checks CRC_A
+	read := Frame{Data: []byte{PICC_CMD_MF_READ, 0x00}}
+	resp, err := reader.PCD_TransceiveRaw(ctx, read, RawConfig{TxCRC: true, RxCRC: true}, INTERUPT_TIMEOUT)
+	is.NoErr(err)
+	is.Equal(len(resp.Data), 16)
+	is.True(bytes.Compare(resp.Data[:3], uid[:3]) == 0)
+
+	// Parity bits are sent and received as data bits
+	read.Data = append(read.Data, ISO14443aCRC(read.Data)...)
+	resp, err = reader.PCD_TransceiveRaw(ctx, read, RawConfig{ParityDisable: true}, INTERUPT_TIMEOUT)
+	is.NoErr(err)
+	is.Equal(len(resp.Data), 18)
+	is.Equal(len(resp.Parity), 18)
+	is.Equal(resp.Parity[0], OddParity(resp.Data[0]))
+
+	// The framing stays until an exchange needs another one
+	value, err := reader.PCD_ReadRegister(MfRxReg)
+	is.NoErr(err)
+	is.Equal(value&0x10, byte(0x10))
+	data, err := reader.MIFARE_Read(0)
+	is.NoErr(err)
+	is.True(bytes.Compare(data[:3], uid[:3]) == 0)
+	value, err = reader.PCD_ReadRegister(MfRxReg)
+	is.NoErr(err)
+	is.Equal(value&0x10, byte(0))
+
+	// A wrong parity bit: the NTAG doesn't answer
+	read.Parity = []byte{OddParity(read.Data[0]) ^ 1}
+	_, err = reader.PCD_TransceiveRaw(ctx, read, RawConfig{ParityDisable: true}, INTERUPT_TIMEOUT)
+	is.True(errors.Is(err, ErrTimeout))
+
+	_, err = reader.PCD_TransceiveRaw(ctx, read, RawConfig{ParityDisable: true, TxCRC: true}, INTERUPT_TIMEOUT)
+	is.True(errors.Is(err, ErrUsage))
+}
+
+func TestHardwareCRC(t *testing.T) {
+	is := is.New(t)
+	uid := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	card := NewVirtualNTAG213(uid)
+	sim := NewMFRC522Simulator()
+	field := NewVirtualField(card)
+	sim.Field = field
+	reader, err := New(SPIPort(sim), WithHardwareCRC())
+	is.NoErr(err)
+	is.NoErr(reader.PCD_Init())
+	is.NoErr(reader.PCD_AntennaOn())
+
+	is.True(reader.PICC_IsNewCardPresent())
+	selected, err := reader.PICC_Select()
+	is.NoErr(err)
+	is.True(bytes.Compare(selected.Uid, uid) == 0)
+	is.NoErr(reader.MIFARE_Write(5, []byte{0xde, 0xad, 0xbe, 0xef, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}))
+	data, err := reader.MIFARE_Read(5)
+	is.NoErr(err)
+	is.True(bytes.Compare(data[:4], []byte{0xde, 0xad, 0xbe, 0xef}) == 0)
+	for _, reg := range []byte{TxModeReg, RxModeReg} {
+		mode, err := reader.PCD_ReadRegister(reg)
+		is.NoErr(err)
+		is.Equal(mode&0x80, byte(0x80)) // CRCEn
+	}
+
+	// The software CRC is the fallback
+	softwareSim := NewMFRC522Simulator()
+	softwareSim.Field = field
+	software := newSimulatedMFRC522(t, softwareSim)
+	is.NoErr(software.PCD_Init())
+	is.NoErr(software.PCD_AntennaOn())
+	field.FieldOff()
+	is.True(software.PICC_IsNewCardPresent())
+	_, err = software.PICC_Select()
+	is.NoErr(err)
+
+	// A wrong CRC_A is reported by the chip
+	sim.Field = SimFieldFunc(func(frame Frame) (Frame, int, bool) {
+		return Frame{Data: []byte{0x01, 0x02, 0x03, 0x04}}, -1, true
+	})
+	softwareSim.Field = sim.Field
+	_, err = reader.MIFARE_Read(5)
+	is.True(errors.Is(err, ErrCRC))
+	var commErr *CommunicationError
+	is.True(errors.As(err, &commErr))
+	is.Equal(commErr.ErrorReg&0x04, byte(0x04))
+	_, err = software.MIFARE_Read(5)
+	is.True(errors.Is(err, ErrCRC))
+}
@@ -0,0 +1,87 @@
+// Pluggable logging. The driver is silent unless a Logger is set.
+
+package mfrc522
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+)
+
+type LogLevel int
+
+const (
+	LOG_TRACE LogLevel = iota // every frame and register value, including nonces and keystream
+	LOG_DEBUG                 // protocol steps
+	LOG_INFO
+	LOG_WARN
+	LOG_ERROR
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LOG_TRACE:
+		return "TRACE"
+	case LOG_DEBUG:
+		return "DEBUG"
+	case LOG_INFO:
+		return "INFO"
+	case LOG_WARN:
+		return "WARN"
+	case LOG_ERROR:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// Logger receives driver events. keyvals are alternating keys and values.
+// Secret material (nonces, keystream, keys) is only logged with LOG_TRACE.
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {}
+
+// StdLogger writes events of Level and above to a standard library logger
+type StdLogger struct {
+	Logger *log.Logger
+	Level  LogLevel
+}
+
+func NewStdLogger(logger *log.Logger, level LogLevel) *StdLogger {
+	return &StdLogger{Logger: logger, Level: level}
+}
+
+func (l *StdLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	if level < l.Level {
+		return
+	}
+	var buff bytes.Buffer
+	fmt.Fprintf(&buff, "%-5s %s", level, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		var val interface{} = "<missing>"
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+		if b, ok := val.([]byte); ok {
+			fmt.Fprintf(&buff, " %v=[% x]", keyvals[i], b)
+		} else {
+			fmt.Fprintf(&buff, " %v=%v", keyvals[i], val)
+		}
+	}
+	l.Logger.Output(2, buff.String())
+}
+
+/**
+ * Sets the logger of the driver, nil disables logging.
+ */
+func (r *MFRC522) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+	r.lock()
+	defer r.unlock()
+	r.logger = logger
+}
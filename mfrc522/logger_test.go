@@ -0,0 +1,63 @@
+package mfrc522
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+type logEntry struct {
+	level   LogLevel
+	msg     string
+	keyvals []interface{}
+}
+
+type recordingLogger struct {
+	entries []logEntry
+}
+
+func (l *recordingLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	l.entries = append(l.entries, logEntry{level, msg, keyvals})
+}
+
+func TestLoggerSecrets(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t, NewVirtualMifareClassic1K([]byte{0x9c, 0x59, 0x9b, 0x32}))
+	logger := &recordingLogger{}
+	reader.SetLogger(logger)
+
+	is.True(reader.PICC_IsNewCardPresent())
+	uid, err := reader.PICC_Select()
+	is.NoErr(err)
+	is.NoErr(reader.PICC_AuthentificateKeyA(*uid, defaultKey, 4))
+
+	traced := false
+	for _, entry := range logger.entries {
+		if strings.HasPrefix(entry.msg, "auth:") {
+			is.Equal(entry.level, LOG_TRACE) // nonces and keystream
+			traced = true
+		}
+	}
+	is.True(traced)
+
+	// Silent again
+	reader.SetLogger(nil)
+	count := len(logger.entries)
+	is.True(reader.PICC_IsNewCardPresent() == false)
+	is.Equal(len(logger.entries), count)
+}
+
+func TestStdLogger(t *testing.T) {
+	is := is.New(t)
+	var buff bytes.Buffer
+	logger := NewStdLogger(log.New(&buff, "", 0), LOG_DEBUG)
+
+	logger.Log(LOG_TRACE, "hidden", "nt", []byte{1, 2})
+	is.Equal(buff.String(), "")
+
+	logger.Log(LOG_DEBUG, "selectLevel", "level", 1, "data", []byte{0x93, 0x20})
+	is.Equal(buff.String(), "DEBUG selectLevel level=1 data=[93 20]\n")
+}
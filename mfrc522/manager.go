@@ -0,0 +1,217 @@
+// Several readers, for example on different chip selects of one SPI bus.
+
+package mfrc522
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const MANAGER_POLL_INTERVAL = 100 * time.Millisecond
+
+/**
+ * A card selected by one of the readers, or the failure of a reader.
+ * A failed reader is isolated: it isn't polled anymore.
+ */
+type CardEvent struct {
+	ReaderID string
+	UID      *UID  // nil if Err is set
+	Err      error // the reader failed
+	Time     time.Time
+}
+
+type ReaderStatus struct {
+	ReaderID string
+	Err      error // nil if the reader is polled
+}
+
+type managedReader struct {
+	id      string
+	reader  *MFRC522
+	err     error
+	lastUid []byte // reported card, nil if the field was empty
+}
+
+/**
+ * Manager polls its readers round robin, only one reader has the antenna on
+ * at a time. Readers may share the RST and IRQ lines: Init performs all
+ * self tests (they reset the chips) before the first PCD_Init.
+ */
+type Manager struct {
+	PollInterval time.Duration // pause after every round
+
+	mu      sync.Mutex
+	readers []*managedReader
+}
+
+func NewManager() *Manager {
+	return &Manager{PollInterval: MANAGER_POLL_INTERVAL}
+}
+
+func (m *Manager) Add(id string, reader *MFRC522) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, mr := range m.readers {
+		if mr.id == id {
+			return UsageError(fmt.Sprintf("Reader %q is already added", id))
+		}
+	}
+	m.readers = append(m.readers, &managedReader{id: id, reader: reader})
+	return nil
+}
+
+/**
+ * Returns the reader with id, nil if there is no such reader.
+ */
+func (m *Manager) Reader(id string) *MFRC522 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, mr := range m.readers {
+		if mr.id == id {
+			return mr.reader
+		}
+	}
+	return nil
+}
+
+func (m *Manager) Status() []ReaderStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := make([]ReaderStatus, len(m.readers))
+	for i, mr := range m.readers {
+		status[i] = ReaderStatus{ReaderID: mr.id, Err: mr.err}
+	}
+	return status
+}
+
+/**
+ * Self tests and initializes all readers with the antenna off.
+ * Failed readers are isolated, see Status. An error is returned only if no reader is left.
+ */
+func (m *Manager) Init() error {
+	readers := m.healthy()
+	for _, mr := range readers {
+		if err := mr.reader.PCD_PerformSelfTest(); err != nil {
+			m.fail(mr, err)
+		}
+	}
+	for _, mr := range m.healthy() {
+		if err := mr.reader.PCD_Init(); err != nil {
+			m.fail(mr, err)
+		} else if err := mr.reader.PCD_AntennaOff(); err != nil {
+			m.fail(mr, err)
+		}
+	}
+	if len(m.healthy()) == 0 {
+		return CommonError("No reader available")
+	}
+	return nil
+}
+
+/**
+ * Polls the readers until ctx is done. A card is reported when it is
+ * selected by a reader which had no card or another card in the previous round.
+ */
+func (m *Manager) Run(ctx context.Context, events chan<- CardEvent) error {
+	for {
+		readers := m.healthy()
+		if len(readers) == 0 {
+			return CommonError("No reader available")
+		}
+		for _, mr := range readers {
+			if err := m.poll(ctx, mr, events); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.PollInterval):
+		}
+	}
+}
+
+/**
+ * One reader: antenna on, WUPA and select, antenna off.
+ * A failure of the reader isolates it, a card error doesn't.
+ * Returns an error only if ctx is done.
+ */
+func (m *Manager) poll(ctx context.Context, mr *managedReader, events chan<- CardEvent) error {
+	if err := mr.reader.PCD_AntennaOn(); err != nil {
+		return m.report(ctx, events, CardEvent{ReaderID: mr.id, Err: m.fail(mr, err), Time: time.Now()})
+	}
+
+	var event *CardEvent
+	session, err := mr.reader.AcquireSession(ctx)
+	if err == nil {
+		if bytes.Compare(session.UID.Uid, mr.lastUid) != 0 {
+			event = &CardEvent{ReaderID: mr.id, UID: session.UID, Time: time.Now()}
+		}
+		mr.lastUid = session.UID.Uid
+		session.Release()
+	} else if ctx.Err() == nil {
+		mr.lastUid = nil
+		if !isCardError(err) {
+			event = &CardEvent{ReaderID: mr.id, Err: m.fail(mr, err), Time: time.Now()}
+		}
+	}
+
+	if err := mr.reader.PCD_AntennaOff(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if event == nil || event.Err == nil {
+			return m.report(ctx, events, CardEvent{ReaderID: mr.id, Err: m.fail(mr, err), Time: time.Now()})
+		}
+		// The field may still be on, the failure event tells it
+		event.Err = m.fail(mr, fmt.Errorf("%w, antenna off failed: %v", event.Err, err))
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if event != nil {
+		return m.report(ctx, events, *event)
+	}
+	return nil
+}
+
+/**
+ * Reports whether err is caused by the card or the RF field: no card,
+ * a transmission error or an unexpected answer. Other errors are failures
+ * of the reader or of its transport.
+ */
+func isCardError(err error) bool {
+	return IsRetryable(err) || errors.Is(err, ErrSelection) || errors.Is(err, ErrUnexpectedResponse)
+}
+
+func (m *Manager) report(ctx context.Context, events chan<- CardEvent, event CardEvent) error {
+	select {
+	case events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) fail(mr *managedReader, err error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mr.err = err
+	return err
+}
+
+func (m *Manager) healthy() []*managedReader {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var readers []*managedReader
+	for _, mr := range m.readers {
+		if mr.err == nil {
+			readers = append(readers, mr)
+		}
+	}
+	return readers
+}
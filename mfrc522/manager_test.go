@@ -0,0 +1,121 @@
+package mfrc522
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestManager(t *testing.T) {
+	is := is.New(t)
+	uid := []byte{0x11, 0x22, 0x33, 0x44}
+
+	// "door1" has a card, "door2" is empty, "broken" fails the self test
+	sims := map[string]*MFRC522Simulator{}
+	for _, id := range []string{"door1", "door2", "broken"} {
+		sims[id] = NewMFRC522Simulator()
+	}
+	sims["broken"].Version = 0x00 // no chip answers
+	field := NewVirtualField(NewVirtualMifareClassic1K(uid))
+	interference := 0
+	sims["door1"].Field = SimFieldFunc(func(frame Frame) (Frame, int, bool) {
+		// The antenna of the other readers is off
+		out := make([]byte, 2)
+		sims["door2"].Tx([]byte{TxControlReg<<1 | 0x80, 0}, out)
+		if out[1]&0x03 != 0 {
+			interference++
+		}
+		return field.Transceive(frame)
+	})
+
+	manager := NewManager()
+	manager.PollInterval = time.Millisecond
+	for _, id := range []string{"door1", "door2", "broken"} {
+		is.NoErr(manager.Add(id, newSimulatedMFRC522(t, sims[id])))
+	}
+	is.True(manager.Add("door1", manager.Reader("door1")) != nil)
+
+	is.NoErr(manager.Init())
+	for _, status := range manager.Status() {
+		is.Equal(status.Err != nil, status.ReaderID == "broken")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	events := make(chan CardEvent, 16)
+	is.Equal(manager.Run(ctx, events), context.DeadlineExceeded)
+	close(events)
+
+	var received []CardEvent
+	for event := range events {
+		received = append(received, event)
+	}
+	is.Equal(len(received), 1) // the card is reported once
+	is.Equal(received[0].ReaderID, "door1")
+	is.NoErr(received[0].Err)
+	is.True(bytes.Compare(received[0].UID.Uid, uid) == 0)
+	is.Equal(interference, 0)
+}
+
+// Fails the writes to the FIFO once broken is set, then the writes to TxControlReg
+type breakingTransport struct {
+	Transport
+	broken     bool
+	fifoFailed bool
+}
+
+func (t *breakingTransport) WriteRegister(address byte, values ...byte) error {
+	if t.broken && address == FIFODataReg {
+		t.fifoFailed = true
+		return errors.New("SPI transfer failed")
+	}
+	if t.fifoFailed && address == TxControlReg {
+		return errors.New("SPI transfer failed")
+	}
+	return t.Transport.WriteRegister(address, values...)
+}
+
+func TestManagerTransportError(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	sim.Field = NewVirtualField(NewVirtualMifareClassic1K([]byte{0x11, 0x22, 0x33, 0x44}))
+	spi, err := NewSPITransport(sim)
+	is.NoErr(err)
+	transport := &breakingTransport{Transport: spi}
+	reader, err := NewMFRC522(transport, sim.ResetPin(), sim.IRQPin())
+	is.NoErr(err)
+
+	manager := NewManager()
+	manager.PollInterval = time.Millisecond
+	is.NoErr(manager.Add("door", reader))
+	is.NoErr(manager.Init())
+	transport.broken = true
+
+	events := make(chan CardEvent, 16)
+	err = manager.Run(context.Background(), events)
+	is.True(err != nil) // no reader left
+	close(events)
+	var received []CardEvent
+	for event := range events {
+		received = append(received, event)
+	}
+	is.Equal(len(received), 1)
+	is.True(received[0].Err != nil)
+	is.True(strings.Contains(received[0].Err.Error(), "antenna off failed")) // the field may be on
+	is.True(received[0].UID == nil)
+	is.True(manager.Status()[0].Err != nil)
+}
+
+func TestIsCardError(t *testing.T) {
+	is := is.New(t)
+	is.True(isCardError(&CommunicationError{Err: ErrTimeout}))
+	is.True(isCardError(SelectionError("SAK")))
+	is.True(isCardError(UnexpectedResponse("HLTA answered")))
+	is.True(!isCardError(CommonError("No chip answers")))
+	is.True(!isCardError(errors.New("SPI transfer failed")))
+}
@@ -9,16 +9,14 @@ package mfrc522
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	_ "math/rand"
 	"time"
 
 	"periph.io/x/periph/conn/gpio"
 	_ "periph.io/x/periph/conn/gpio/gpioreg"
-	"periph.io/x/periph/conn/physic"
-	"periph.io/x/periph/conn/spi"
 )
 
 var MFRC522_VER_1_0 = []byte{0x00, 0xC6, 0x37, 0xD5, 0x32, 0xB7, 0x57, 0x5C,
@@ -39,11 +37,41 @@ var MFRC522_VER_2_0 = []byte{0x00, 0xEB, 0x66, 0xBA, 0x57, 0xBF, 0x23, 0x95,
 	0x86, 0x96, 0x83, 0x38, 0xCF, 0x9D, 0x5B, 0x6D,
 	0xDC, 0x15, 0xBA, 0x3E, 0x7D, 0x95, 0x3B, 0x2F}
 
+// Self-test result of MFRC522 v0.0, Philips preliminary specification 2.0, 16.1
+var MFRC522_VER_0_0 = []byte{0x00, 0x87, 0x98, 0x0F, 0x49, 0xFF, 0x07, 0x19,
+	0xBF, 0x22, 0x30, 0x49, 0x59, 0x63, 0xAD, 0xCA,
+	0x7F, 0xE3, 0x4E, 0x03, 0x5C, 0x4E, 0x49, 0x50,
+	0x47, 0x9A, 0x37, 0x61, 0xE7, 0xE2, 0xC6, 0x2E,
+	0x75, 0x5A, 0xED, 0x04, 0x3D, 0x02, 0x4B, 0x78,
+	0x32, 0xFF, 0x58, 0x3B, 0x7C, 0xE9, 0x00, 0x94,
+	0xB4, 0x4A, 0x59, 0x5B, 0xFD, 0xC9, 0x29, 0xDF,
+	0x35, 0x96, 0x98, 0x9E, 0x4F, 0x30, 0x32, 0x8D}
+
+// Self-test result of the Fudan FM17522 clone
+var FM17522_SELF_TEST = []byte{0x00, 0xD6, 0x78, 0x8C, 0xE2, 0xAA, 0x0C, 0x18,
+	0x2A, 0xB8, 0x7A, 0x7F, 0xD3, 0x6A, 0xCF, 0x0B,
+	0xB1, 0x37, 0x63, 0x4B, 0x69, 0xAE, 0x91, 0xC7,
+	0xC3, 0x97, 0xAE, 0x77, 0xF4, 0x37, 0xD7, 0x9B,
+	0x7C, 0xF5, 0x3C, 0x11, 0x8F, 0x15, 0xC3, 0xD7,
+	0xC1, 0x5B, 0x00, 0x2A, 0xD0, 0x75, 0xDE, 0x9E,
+	0x51, 0x64, 0xAB, 0x3E, 0xE9, 0x15, 0xB5, 0xAB,
+	0x56, 0x9A, 0x98, 0x82, 0x26, 0xEA, 0x2A, 0x62}
+
 const (
+	// MFRC522 v0.0
+	VER_0_0 = 0x90
 	// MFRC522 v1
 	VER_1_0 = 0x91
 	// MFRC522 v2
 	VER_2_0 = 0x92
+	// Fudan FM17522
+	VER_FM17522 = 0x88
+	// Counterfeit MFRC522
+	VER_COUNTERFEIT = 0x12
+	// Clone of unknown origin found on RC522 modules
+	VER_CLONE_B2 = 0xB2
+
+	FIFO_SIZE = 64 // bytes
 
 	// MFRC522 commands
 	PCD_Idle             = 0x00 // no action, cancels current command execution
@@ -67,7 +95,7 @@ const (
 	ErrorReg      = 0x06 // error bits showing the error status of the last command executed
 	Status1Reg    = 0x07 // communication status bits
 	Status2Reg    = 0x08 // receiver and transmitter status bits
-	FIFODataReg   = 0x09 // input and output of 64 byte FIFO buffer
+	FIFODataReg   = 0x09 // input and output of 64 byte FIFO buffer, see FIFO_SIZE
 	FIFOLevelReg  = 0x0A // number of bytes stored in the FIFO buffer
 	WaterLevelReg = 0x0B // level for FIFO underflow and overflow warning
 	ControlReg    = 0x0C // miscellaneous control registers
@@ -145,18 +173,38 @@ const (
 )
 
 type MFRC522 struct {
-	spiDev spi.Conn
-	//operationTimeout time.Duration
+	transport   Transport
+	timeouts    Timeouts      // timer period of the PICC commands
+	timerPeriod time.Duration // last period set by setTimer, 0 if unknown
 	//	beforeCall       func()
 	//afterCall        func()
-	resetPin gpio.PinOut
-	irqPin   gpio.PinIn
-	//antennaGain int
+	resetPin        gpio.PinOut // nil: soft reset
+	irqPin          gpio.PinIn  // nil: no IRQ line
+	antennaGain     int         // RxGain set by PCD_Init, -1 keeps the chip default
+	rfConfig        *RFConfig   // RF front end set by PCD_Init, nil keeps the chip defaults
+	antennaOnAtInit bool        // PCD_Init turns the antenna on
+	logger          Logger
+	sem             chan struct{} // serializes the access to the chip, see lock
+	crypto1         *Crypto1      // cipher of the authenticated PICC, nil if there is none
+	hardwareCRC     bool          // the chip appends and checks CRC_A, see WithHardwareCRC
+	framing         RawConfig     // ParityDisable, TxCRCEn and RxCRCEn set in the chip
+	txRate, rxRate  BitRate       // TxSpeed and RxSpeed set in the chip
+	chip            ChipInfo      // identified by PCD_Init, its quirks apply
+	timerDrift      float64       // see WithTimerDrift, 0 if the timer is exact
+	tracer          Tracer        // frames of the transceive path, nil if there is none
+	traceDecoder    traceDecoder  // names the traced frames
 }
 
 type IRQCallbackFn func()
 
-func NewMFRC522(spiPort spi.Port, resetPin gpio.PinOut, irqPin gpio.PinIn) (*MFRC522, error) {
+/**
+ * The transport is one of SPITransport, I2CTransport or UARTTransport.
+ */
+func NewMFRC522(transport Transport, resetPin gpio.PinOut, irqPin gpio.PinIn) (*MFRC522, error) {
+
+	if transport == nil {
+		return nil, CommonError("Transport is not set")
+	}
 
 	if resetPin == nil {
 		return nil, CommonError("Reset pin is not set")
@@ -166,27 +214,7 @@ func NewMFRC522(spiPort spi.Port, resetPin gpio.PinOut, irqPin gpio.PinIn) (*MFR
 		return nil, CommonError("IRQ pin is not set")
 	}
 
-	spiDev, err := spiPort.Connect(10*physic.MegaHertz, spi.Mode0, 8)
-	if err != nil {
-		return nil, err
-	}
-	if err := resetPin.Out(gpio.High); err != nil {
-		return nil, err
-	}
-
-	if err := irqPin.In(gpio.PullUp, gpio.NoEdge); err != nil {
-		return nil, err
-	}
-
-	reader := &MFRC522{
-		spiDev:   spiDev,
-		resetPin: resetPin,
-		irqPin:   irqPin,
-	}
-
-	reader.PCD_Reset()
-
-	return reader, nil
+	return New(transport, WithResetPin(resetPin), WithIRQPin(irqPin))
 }
 
 /////////////////////////////////////////////////////////////////////////////////////
@@ -195,53 +223,65 @@ func NewMFRC522(spiPort spi.Port, resetPin gpio.PinOut, irqPin gpio.PinIn) (*MFR
 
 /**
  * Writes a byte to the specified register in the MFRC522 chip.
- * The interface is described in the datasheet section 8.1.2.
+ * The interface is described in the datasheet section 8.1.
  */
 func (r *MFRC522) PCD_WriteRegister(address, value byte) error {
-	newData := []byte{(byte(address) << 1) & 0x7E, value}
-	err := r.spiDev.Tx(newData, nil)
-	return err
+	r.lock()
+	defer r.unlock()
+	return r.writeRegister(address, value)
+}
+
+func (r *MFRC522) writeRegister(address, value byte) error {
+	return r.transport.WriteRegister(address, value)
 }
 
 /**
- * Writes a number of bytes to the specified register in the MFRC522 chip.
- * The interface is described in the datasheet section 8.1.2.
+ * Writes a number of bytes to the FIFO in one transfer.
  */
 func (r *MFRC522) PCD_WriteFIFOBuffer(value []byte) error {
-	for _, val := range value {
-		if err := r.PCD_WriteRegister(FIFODataReg, val); err != nil {
-			return err
-		}
-	}
-	return nil
+	r.lock()
+	defer r.unlock()
+	return r.writeFIFOBuffer(value)
+}
+
+func (r *MFRC522) writeFIFOBuffer(value []byte) error {
+	return r.transport.WriteRegister(FIFODataReg, value...)
 }
 
 /**
- * Reads a number of bytes from the specified register in the MFRC522 chip.
- * The interface is described in the datasheet section 8.1.2.
+ * Reads a byte from the specified register in the MFRC522 chip.
+ * The interface is described in the datasheet section 8.1.
  */
 func (r *MFRC522) PCD_ReadRegister(address byte) (byte, error) {
-	data := []byte{((byte(address) << 1) & 0x7E) | 0x80, 0}
-	out := make([]byte, len(data))
-	if err := r.spiDev.Tx(data, out); err != nil {
+	r.lock()
+	defer r.unlock()
+	return r.readRegister(address)
+}
+
+func (r *MFRC522) readRegister(address byte) (byte, error) {
+	out := make([]byte, 1)
+	if err := r.transport.ReadRegister(address, out); err != nil {
 		return 0, err
 	}
-	return out[1], nil
+	return out[0], nil
 }
 
 /**
- * Reads a number of bytes from the specified register in the MFRC522 chip.
- * The interface is described in the datasheet section 8.1.2.
+ * Reads a number of bytes from the FIFO in one transfer.
  */
-
 func (r *MFRC522) PCD_ReadFIFOBuffer(count int) ([]byte, error) {
+	r.lock()
+	defer r.unlock()
+	return r.readFIFOBuffer(count)
+}
+
+func (r *MFRC522) readFIFOBuffer(count int) ([]byte, error) {
+	if count <= 0 {
+		return []byte{}, nil
+	}
 	result := make([]byte, count)
-	for ind := range result {
-		if val, err := r.PCD_ReadRegister(FIFODataReg); err != nil {
-			return nil, err
-		} else {
-			result[ind] = val
-		}
+	if err := r.transport.ReadRegister(FIFODataReg, result); err != nil {
+		return nil, err
 	}
 	return result, nil
 }
@@ -250,10 +290,16 @@ func (r *MFRC522) PCD_ReadFIFOBuffer(count int) ([]byte, error) {
  * Clears the bits given in mask from register reg.
  */
 func (r *MFRC522) PCD_ClearRegisterBitMask(reg, mask byte) error {
-	if current, err := r.PCD_ReadRegister(reg); err != nil {
+	r.lock()
+	defer r.unlock()
+	return r.clearRegisterBitMask(reg, mask)
+}
+
+func (r *MFRC522) clearRegisterBitMask(reg, mask byte) error {
+	if current, err := r.readRegister(reg); err != nil {
 		return err
 	} else {
-		return r.PCD_WriteRegister(reg, current&^mask) // clear bit mask
+		return r.writeRegister(reg, current&^mask) // clear bit mask
 	}
 } // End PCD_ClearRegisterBitMask()
 
@@ -261,10 +307,16 @@ func (r *MFRC522) PCD_ClearRegisterBitMask(reg, mask byte) error {
  * Sets the bits given in mask in register reg.
  */
 func (r *MFRC522) PCD_SetRegisterBitMask(reg, mask byte) error {
-	if tmp, err := r.PCD_ReadRegister(reg); err != nil {
+	r.lock()
+	defer r.unlock()
+	return r.setRegisterBitMask(reg, mask)
+}
+
+func (r *MFRC522) setRegisterBitMask(reg, mask byte) error {
+	if tmp, err := r.readRegister(reg); err != nil {
 		return err
 	} else {
-		return r.PCD_WriteRegister(reg, tmp|mask) // set bit mask
+		return r.writeRegister(reg, tmp|mask) // set bit mask
 	}
 
 } // End PCD_SetRegisterBitMask()
@@ -272,10 +324,16 @@ func (r *MFRC522) PCD_SetRegisterBitMask(reg, mask byte) error {
 /**
  */
 func (r *MFRC522) PCD_IsCollisionOccure() (bool, error) {
-	if anyByte, err := r.PCD_ReadRegister(ErrorReg); err != nil {
+	r.lock()
+	defer r.unlock()
+	return r.isCollisionOccure()
+}
+
+func (r *MFRC522) isCollisionOccure() (bool, error) {
+	if anyByte, err := r.readRegister(ErrorReg); err != nil {
 		return false, err
 	} else {
-		if anyByte&0x08 == 0 { // CollErr is 0!!
+		if !DecodeErrorFlags(anyByte).CollErr {
 			return false, nil
 		}
 	}
@@ -290,12 +348,266 @@ func (r *MFRC522) PCD_CommunicateWithPICC(command byte, dataToSend []byte,
 	duration time.Duration) (
 	result []byte,
 	err error) {
+	return r.PCD_CommunicateWithPICCContext(context.Background(), command, dataToSend, validBits, duration)
+}
+
+/**
+ * Polls an interrupt request register until one of the mask bits is set or
+ * the duration is elapsed. Returns ctx.Err() if the context is done first.
+ */
+func (r *MFRC522) waitIRq(ctx context.Context, reg, mask byte, duration time.Duration) error {
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+	ticker := time.NewTicker(IRQ_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return nil
+		case <-ticker.C:
+			if irq, err := r.readRegister(reg); err != nil {
+				return err
+			} else if irq&mask != 0 {
+				return nil
+			}
+		}
+	}
+}
+
+/**
+ * Communicate with PICC, the wait for the answer ends with ctx.
+ * On cancellation the command is stopped and ctx.Err() is returned.
+ * duration is the timer period, the TimerIRq of the chip ends the wait.
+ */
+func (r *MFRC522) PCD_CommunicateWithPICCContext(ctx context.Context, command byte, dataToSend []byte,
+	validBits *byte,
+	duration time.Duration) (
+	result []byte,
+	err error) {
+	if err := r.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+	return r.communicateWithPICC(ctx, command, dataToSend, validBits, duration)
+}
+
+/**
+ * validBits is TxLastBits of the sent data before and RxLastBits of the result after the call.
+ */
+func (r *MFRC522) communicateWithPICC(ctx context.Context, command byte, dataToSend []byte,
+	validBits *byte,
+	duration time.Duration) (
+	result []byte,
+	err error) {
+	var frame Frame
+	if frame, err = r.communicate(ctx, command, Frame{Data: dataToSend, LastBits: *validBits & 0x07}, RawConfig{}, duration); err != nil {
+		return
+	}
+	*validBits = frame.LastBits
+	return frame.Data, nil
+}
+
+/**
+ * Sends a frame and receives the answer of the PICC (PCD_Transceive).
+ * rxAlign is the position of the first received bit in the first byte of the
+ * result, the bits below it are zero: the caller completes this byte with the
+ * bits it knows (anticollision). A collision is no error, see PCD_CollisionPosition.
+ */
+func (r *MFRC522) PCD_TransceiveFrame(ctx context.Context, frame Frame, rxAlign byte, timeout time.Duration) (Frame, error) {
+	if err := r.lockContext(ctx); err != nil {
+		return Frame{}, err
+	}
+	defer r.unlock()
+	return r.communicate(ctx, PCD_Transceive, frame, RawConfig{RxAlign: rxAlign}, timeout)
+}
+
+/**
+ * Position of the first collision of the last received frame, 1 for the
+ * first bit of the FIFO (the bits below RxAlign count). 0 if there was no
+ * collision, -1 if the position is out of the 32 bits of CollReg.
+ */
+func (r *MFRC522) PCD_CollisionPosition() (int, error) {
+	r.lock()
+	defer r.unlock()
+	return r.collisionPosition()
+}
+
+func (r *MFRC522) collisionPosition() (int, error) {
+	if collErr, err := r.isCollisionOccure(); err != nil || !collErr {
+		return 0, err
+	}
+	value, err := r.readRegister(CollReg)
+	if err != nil {
+		return 0, err
+	}
+	coll := DecodeColl(value)
+	if coll.CollPosNotValid {
+		return -1, nil
+	}
+	if pos := int(coll.CollPos); pos != 0 {
+		return pos, nil
+	}
+	return 32, nil
+}
+
+// Framing of PCD_TransceiveRaw. The zero value is the ISO 14443-3 framing
+// with parity bits generated by the chip and without CRC.
+type RawConfig struct {
+	ParityDisable bool // MfRxReg ParityDisable: Frame.Parity is sent and the received parity is returned
+	TxCRC         bool // TxModeReg TxCRCEn: the chip appends CRC_A
+	RxCRC         bool // RxModeReg RxCRCEn: the chip checks CRC_A, ErrCRC on mismatch
+	RxAlign       byte // see PCD_TransceiveFrame
+
+	cipher traceCipher // set by the driver for its Crypto1 frames, see trace
+}
+
+/**
+ * Sends a frame with the framing of config. With ParityDisable the parity bits
+ * are the ones of the frame (odd parity if Parity is nil), the result holds the
+ * received parity bits and a parity error is not detected by the chip.
+ * The framing registers are only written when the next exchange needs other values.
+ */
+func (r *MFRC522) PCD_TransceiveRaw(ctx context.Context, frame Frame, config RawConfig, timeout time.Duration) (Frame, error) {
+	if err := r.lockContext(ctx); err != nil {
+		return Frame{}, err
+	}
+	defer r.unlock()
+	return r.transceiveRaw(ctx, frame, config, timeout)
+}
+
+func (r *MFRC522) transceiveRaw(ctx context.Context, frame Frame, config RawConfig, timeout time.Duration) (result Frame, err error) {
+	if config.ParityDisable && (config.TxCRC || config.RxCRC || config.RxAlign != 0) {
+		return Frame{}, UsageError("ParityDisable can't be combined with CRC or RxAlign")
+	}
+
+	if config.ParityDisable {
+		frame = PackParity(frame)
+	}
+	if result, err = r.communicate(ctx, PCD_Transceive, frame, config, timeout); err != nil {
+		return
+	}
+	if config.ParityDisable {
+		result = UnpackParity(result)
+	}
+	return
+}
+
+/**
+ * Exchange with the PICC authenticated by PICC_AuthentificateKeyA: data and
+ * parity bits are encrypted on the host, the 4 bit ACK as well.
+ */
+func (r *MFRC522) transceiveCrypto1(ctx context.Context, data []byte, timeout time.Duration) (result Frame, err error) {
+	frame := Frame{Data: append([]byte{}, data...)}
+	frame.Parity = r.crypto1.Crypt(frame.Data, false)
+	if result, err = r.transceiveRaw(ctx, frame, RawConfig{ParityDisable: true, cipher: cipherData}, timeout); err != nil {
+		return
+	}
+	if len(result.Data) == 1 && result.LastBits == 4 {
+		result.Data[0] ^= r.crypto1.Bits(4)
+		return
+	}
+	if result.LastBits != 0 {
+		return Frame{}, UnexpectedResponse(fmt.Sprintf("Unexpected encrypted frame: %d bits", result.BitLen()))
+	}
+	parity := r.crypto1.Crypt(result.Data, true)
+	if !bytes.Equal(parity, result.Parity) {
+		return Frame{}, ParityCheckError(fmt.Sprintf("Encrypted parity: expected %v, received %v", parity, result.Parity))
+	}
+	result.Parity = nil
+	return
+}
+
+/**
+ * Sends data with CRC_A to the active PICC, encrypted after an authentication.
+ * If rxCRC is set the CRC_A of a response of whole bytes is checked and removed.
+ * The CRC is calculated by the chip with WithHardwareCRC, in Go otherwise.
+ */
+func (r *MFRC522) transceivePICC(ctx context.Context, data []byte, rxCRC bool, timeout time.Duration) (result Frame, err error) {
+	if r.hardwareCRC && r.crypto1 == nil {
+		return r.communicate(ctx, PCD_Transceive, Frame{Data: data}, RawConfig{TxCRC: true, RxCRC: rxCRC}, timeout)
+	}
+
+	buffer := append(append([]byte{}, data...), ISO14443aCRC(data)...)
+	if r.crypto1 != nil {
+		result, err = r.transceiveCrypto1(ctx, buffer, timeout)
+	} else {
+		result, err = r.communicate(ctx, PCD_Transceive, Frame{Data: buffer}, RawConfig{}, timeout)
+	}
+	if err != nil || !rxCRC || result.LastBits != 0 {
+		return
+	}
+	if len(result.Data) < 3 {
+		return Frame{}, UnexpectedResponse(fmt.Sprintf("Response without CRC_A: [% x]", result.Data))
+	}
+	n := len(result.Data) - 2
+	if crc := ISO14443aCRC(result.Data[:n]); bytes.Compare(crc, result.Data[n:]) != 0 {
+		return Frame{}, CRCCheckError(fmt.Sprintf("CRC_A: calculated [% x] received [% x]", crc, result.Data[n:]))
+	}
+	result.Data = result.Data[:n]
+	return
+}
+
+/**
+ * Writes the framing registers which differ from the values set before.
+ */
+func (r *MFRC522) setFraming(config RawConfig) (err error) {
+	settings := []struct {
+		bit RegisterValue
+		on  bool
+		set *bool
+	}{
+		{MfRx{ParityDisable: true}, config.ParityDisable, &r.framing.ParityDisable},
+		{TxMode{TxCRCEn: true}, config.TxCRC, &r.framing.TxCRC},
+		{RxMode{RxCRCEn: true}, config.RxCRC, &r.framing.RxCRC},
+	}
+	for _, setting := range settings {
+		if setting.on == *setting.set {
+			continue
+		}
+		if setting.on {
+			err = r.setBits(setting.bit)
+		} else {
+			err = r.clearBits(setting.bit)
+		}
+		if err != nil {
+			return
+		}
+		*setting.set = setting.on
+	}
+	return
+}
+
+/**
+ * The exchange of all PICC commands, config is the framing of the frame.
+ */
+func (r *MFRC522) communicate(ctx context.Context, command byte, frame Frame, config RawConfig, duration time.Duration) (
+	result Frame,
+	err error) {
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
 
 	// Clear collision registr
-	r.PCD_ClearRegisterBitMask(CollReg, 0x80)
+	r.clearBits(Coll{ValuesAfterColl: true})
 
 	// Stop all operations
-	if err = r.PCD_WriteRegister(CommandReg, PCD_Idle); err != nil {
+	if err = r.writeRegister(CommandReg, PCD_Idle); err != nil {
+		return
+	}
+
+	if err = r.setTimer(duration); err != nil {
+		return
+	}
+
+	if err = r.setFraming(config); err != nil {
+		return
+	}
+
+	// Clear all seven interrupt request bits, otherwise RxIRq of the previous frame is seen
+	if err = r.writeReg(ComIrqAll); err != nil {
 		return
 	}
 
@@ -303,88 +615,139 @@ func (r *MFRC522) PCD_CommunicateWithPICC(command byte, dataToSend []byte,
 	//// Write data
 	///////////////////////////////////////////////
 	// Clear FIFO biffer
-	if err = r.PCD_SetRegisterBitMask(FIFOLevelReg, 0x80); err != nil {
+	if err = r.writeReg(FIFOLevel{FlushBuffer: true}); err != nil {
 		return
 	}
 
 	// Write data
-	if err = r.PCD_WriteFIFOBuffer(dataToSend); err != nil {
+	if err = r.writeFIFOBuffer(frame.Data); err != nil {
 		return
 	}
 
-	// Prepare values for BitFramingReg
-	bitFraming := *validBits & 0x07
-	r.PCD_WriteRegister(BitFramingReg, bitFraming)
+	// Prepare values for BitFramingReg: RxAlign, TxLastBits
+	if err = r.writeReg(BitFraming{RxAlign: config.RxAlign, TxLastBits: frame.LastBits}); err != nil {
+		return
+	}
 
 	///////////////////////////////////////////////
 	//// Transmite data
 	///////////////////////////////////////////////
 
-	if err = r.PCD_WriteRegister(CommandReg, command); err != nil {
+	sent := time.Now()
+	if err = r.writeRegister(CommandReg, command); err != nil {
 		return
 	}
 	if command == PCD_Transceive {
-		if r.PCD_SetRegisterBitMask(BitFramingReg, 0x80); err != nil { // StartSend=1, transmission of data starts
+		if err = r.setBits(BitFraming{StartSend: true}); err != nil { // transmission of data starts
 			return
 		}
 	}
 
-	// Whait PICC
-	time.Sleep(duration)
+	// Whait PICC: RxIRq, IdleIRq or TimerIRq
+	err = r.waitIRq(ctx, ComIrqReg, ComIrq{RxIRq: true, IdleIRq: true, TimerIRq: true}.Encode(), duration+TIMER_MARGIN)
+	received := time.Now()
+	if command == PCD_Transceive {
+		r.trace(sent, TRACE_PCD_TO_PICC, frame, config, 0, nil)
+	}
+	if err != nil {
+		r.writeRegister(CommandReg, PCD_Idle)
+		return
+	}
 
-	// check Irq flag
-	var irqFlag byte
-	if irqFlag, err = r.PCD_ReadRegister(ComIrqReg); err != nil {
+	// check Irq flag, errorReg is traced with a frame received with a collision
+	var irqFlag, errorReg byte
+	if irqFlag, err = r.readRegister(ComIrqReg); err != nil {
 		return
 	} else {
-		log.Printf("ComIrqReg: %08b\n", irqFlag)
-		if irqFlag&0x30 == 0 {
+		r.logger.Log(LOG_TRACE, "ComIrqReg", "value", fmt.Sprintf("%08b", irqFlag))
+		irq := DecodeComIrq(irqFlag)
+		if !irq.RxIRq && !irq.IdleIRq {
 			switch {
-			case irqFlag&0x01 > 0:
-				err = TimeoutIRqError("Response not completed\n")
+			case irq.TimerIRq:
+				err = r.communicationError(command, irqFlag, ErrTimeout)
 				return
 
-			case irqFlag&0x02 > 0:
+			case irq.ErrIRq:
 				// Ercontactless UART an error is detected
 				var errBit byte
-				if errBit, err = r.PCD_ReadRegister(ErrorReg); err != nil {
+				if errBit, err = r.readRegister(ErrorReg); err != nil {
 					return
 				} else {
-					if errBit&0x13 > 0 { // BufferOvfl ParityErr ProtocolErr
-						err = ErrIRqError(fmt.Sprintf("Contactless UART error is detected. ErrReg: %08b\n", errBit))
+					errs := DecodeErrorFlags(errBit)
+					if errs.WrErr || errs.TempErr || errs.BufferOvfl || errs.ParityErr || errs.ProtocolErr {
+						errs.CollErr = false
+						err = &CommunicationError{Command: command, ComIrqReg: irqFlag, ErrorReg: errBit, Err: errorRegError(errs.Encode())}
+						r.traceReceived(received, command, config, errBit, err)
 						return
 					}
 				}
+			case command == PCD_Transceive && irq.TxIRq:
+				// Sent, but nothing received while waiting and no TimerIRq: is TAuto set?
+				r.logger.Log(LOG_WARN, "TimerIRq missing", "timer", duration)
+				err = r.communicationError(command, irqFlag, ErrTimeout)
+				return
 			default:
-				err = UnexpectedIRqError(fmt.Sprintf("Unexpected Irq Value. ComIrqReg: %x\n", irqFlag))
+				err = r.communicationError(command, irqFlag, ErrUnexpectedIRq)
+				return
+			}
+		} else if irq.ErrIRq {
+			// Received with errors, a collision is handled by the caller
+			if errorReg, err = r.readRegister(ErrorReg); err != nil {
+				return
+			}
+			errs := DecodeErrorFlags(errorReg)
+			if errs.CollErr = false; errs != (ErrorFlags{}) {
+				err = &CommunicationError{Command: command, ComIrqReg: irqFlag, ErrorReg: errorReg, Err: errorRegError(errs.Encode())}
+				r.traceReceived(received, command, config, errorReg, err)
 				return
 			}
 		}
 	}
 
 	// A received data stream ends
-	var count byte
-	if count, err = r.PCD_ReadRegister(FIFOLevelReg); err != nil {
+	if result, err = r.readFrame(); err != nil {
 		return
 	}
+	if command == PCD_Transceive {
+		r.trace(received, TRACE_PICC_TO_PCD, result, config, errorReg, nil)
+	}
+	return
 
-	log.Printf("FIFOLevelReg : %08b\n", count)
+}
 
-	// TODO add rxAlign
-	if result, err = r.PCD_ReadFIFOBuffer(int(count)); err != nil {
+/**
+ * Reads the received frame from the FIFO.
+ */
+func (r *MFRC522) readFrame() (result Frame, err error) {
+	var count byte
+	if count, err = r.readRegister(FIFOLevelReg); err != nil {
 		return
 	}
 
-	if *validBits > 0 {
-		var rValidBits byte
-		if rValidBits, err = r.PCD_ReadRegister(ControlReg); err != nil {
-			return
-		}
-		*validBits = rValidBits & 0x7
+	r.logger.Log(LOG_TRACE, "FIFOLevelReg", "value", fmt.Sprintf("%08b", count))
+
+	if result.Data, err = r.readFIFOBuffer(int(DecodeFIFOLevel(count).FIFOLevel)); err != nil {
+		return
 	}
 
+	// Number of valid bits in the last received byte, 0 for a whole byte
+	var rxLastBits byte
+	if rxLastBits, err = r.readRegister(ControlReg); err != nil {
+		return
+	}
+	result.LastBits = DecodeControl(rxLastBits).RxLastBits
 	return
+}
 
+/**
+ * Builds a CommunicationError with the current ErrorReg value.
+ */
+func (r *MFRC522) communicationError(command, irqFlag byte, kind error) error {
+	errBit, err := r.readRegister(ErrorReg)
+	if err != nil {
+		return err
+	}
+	return &CommunicationError{Command: command, ComIrqReg: irqFlag, ErrorReg: errBit, Err: kind}
 }
 
 /**
@@ -392,32 +755,42 @@ func (r *MFRC522) PCD_CommunicateWithPICC(command byte, dataToSend []byte,
  * @return Result is written to result[0..1], low byte first.
  */
 func (r *MFRC522) PCD_CalculateCRC(crcResetValue int, buffer []byte, duration time.Duration) ([]byte, error) {
+	r.lock()
+	defer r.unlock()
+	return r.calculateCRC(crcResetValue, buffer, duration)
+}
+
+func (r *MFRC522) calculateCRC(crcResetValue int, buffer []byte, duration time.Duration) ([]byte, error) {
 
+	mode := Mode{TxWaitRF: true, PolMFin: true}
 	switch crcResetValue {
 	case CRC_RESET_VALUE_ZERO:
-		r.PCD_WriteRegister(ModeReg, 0x00)
+		mode.CRCPreset = 0
 	case CRC_RESET_VALUE_6363:
-		r.PCD_WriteRegister(ModeReg, 0x3d)
+		mode.CRCPreset = 1
 	case CRC_RESET_VALUE_A671:
-		r.PCD_WriteRegister(ModeReg, 0x3e)
+		mode.CRCPreset = 2
 	case CRC_RESET_VALUE_FFFF:
-		r.PCD_WriteRegister(ModeReg, 0x3f)
+		mode.CRCPreset = 3
 	default:
 		return nil, CommonError(fmt.Sprintf("Unexpected crcResetValue: %x", crcResetValue))
 	}
+	if err := r.writeReg(mode); err != nil {
+		return nil, err
+	}
 
 	// Stop any active command.
-	if err := r.PCD_WriteRegister(CommandReg, PCD_Idle); err != nil {
+	if err := r.writeRegister(CommandReg, PCD_Idle); err != nil {
 		return nil, err
 	}
 
 	// Clear FIFO biffer
-	if err := r.PCD_SetRegisterBitMask(FIFOLevelReg, 0x80); err != nil {
+	if err := r.writeReg(FIFOLevel{FlushBuffer: true}); err != nil {
 		return nil, err
 	}
 
 	// Start the calculation
-	if err := r.PCD_WriteRegister(CommandReg, PCD_CalcCRC); err != nil {
+	if err := r.writeRegister(CommandReg, PCD_CalcCRC); err != nil {
 		return nil, err
 	}
 
@@ -429,33 +802,36 @@ func (r *MFRC522) PCD_CalculateCRC(crcResetValue int, buffer []byte, duration ti
 		arr = buffer[:len(buffer)]
 	}
 
-	if err := r.PCD_WriteFIFOBuffer(arr); err != nil {
+	if err := r.writeFIFOBuffer(arr); err != nil {
 		return nil, err
 	}
 
-	time.Sleep(duration)
+	// Whait CRCIRq
+	if err := r.waitIRq(context.Background(), DivIrqReg, DivIrq{CRCIRq: true}.Encode(), duration); err != nil {
+		return nil, err
+	}
 
-	if bit, err := r.PCD_ReadRegister(DivIrqReg); err != nil {
+	if bit, err := r.readRegister(DivIrqReg); err != nil {
 		return nil, err
 	} else {
-		if bit&0x04>>2 != 1 {
-			return nil, UnexpectedIRqError(fmt.Sprintf(" CalcCRC command notended. DivIrqReg: %x\n", bit))
+		if !DecodeDivIrq(bit).CRCIRq {
+			return nil, &CommunicationError{Command: PCD_CalcCRC, Err: ErrTimeout} // CalcCRC command not ended
 		}
 	}
 
 	// CRC completed
 	// Stop any active command.
-	if err := r.PCD_WriteRegister(CommandReg, PCD_Idle); err != nil {
+	if err := r.writeRegister(CommandReg, PCD_Idle); err != nil {
 		return nil, err
 	}
 	// Transfer the result from the registers to the result buffer
 	result := make([]byte, 2)
 	var err error
-	result[0], err = r.PCD_ReadRegister(CRCResultRegL)
+	result[0], err = r.readRegister(CRCResultRegL)
 	if err != nil {
 		return nil, err
 	}
-	result[1], err = r.PCD_ReadRegister(CRCResultRegH)
+	result[1], err = r.readRegister(CRCResultRegH)
 	if err != nil {
 		return nil, err
 	}
@@ -470,17 +846,44 @@ func (r *MFRC522) PCD_CalculateCRC(crcResetValue int, buffer []byte, duration ti
  * Performs a soft reset on the MFRC522 chip and waits for it to be ready again.
  */
 func (r *MFRC522) PCD_Reset() error {
+	return r.PCD_ResetContext(context.Background())
+}
+
+/**
+ * PCD_Reset, the wait for the oscillator ends with ctx.
+ */
+func (r *MFRC522) PCD_ResetContext(ctx context.Context) error {
+	if err := r.lockContext(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+	return r.reset(ctx)
+}
 
-	r.resetPin.Out(gpio.Low)
-	time.Sleep(50 * time.Microsecond)
-	r.resetPin.Out(gpio.High)
+func (r *MFRC522) reset(ctx context.Context) error {
+	r.timerPeriod = 0
+	r.crypto1 = nil
+	r.framing = RawConfig{}
+	r.txRate, r.rxRate = BIT_RATE_106, BIT_RATE_106
+
+	if r.resetPin != nil {
+		r.resetPin.Out(gpio.Low)
+		time.Sleep(50 * time.Microsecond)
+		r.resetPin.Out(gpio.High)
+	} else if err := r.writeRegister(CommandReg, PCD_SoftReset); err != nil {
+		return err
+	}
 
 	// Wait for the PowerDown bit in CommandReg to be cleared
 	for i := 0; i < 3; i++ {
 		// Section 8.8.2 in the datasheet says the oscillator start-up time is the
 		// start up time of the crystal + 37,74ms. Let us be generous: 50ms.
-		time.Sleep(50 * time.Millisecond)
-		if val, err := r.PCD_ReadRegister(CommandReg); err != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+		if val, err := r.readRegister(CommandReg); err != nil {
 			return err
 		} else {
 			if val&(1<<4) == 0 {
@@ -497,12 +900,18 @@ func (r *MFRC522) PCD_Reset() error {
  * After a reset these pins are disabled.
  */
 func (r *MFRC522) PCD_AntennaOn() error {
-	value, err := r.PCD_ReadRegister(TxControlReg)
+	r.lock()
+	defer r.unlock()
+	return r.antennaOn()
+}
+
+func (r *MFRC522) antennaOn() error {
+	value, err := r.readRegister(TxControlReg)
 	if err != nil {
 		return err
 	}
-	if (value & 0x03) != 0x03 {
-		err = r.PCD_SetRegisterBitMask(TxControlReg, 0x03)
+	if tx := DecodeTxControl(value); !tx.Tx1RFEn || !tx.Tx2RFEn {
+		err = r.setBits(TxControl{Tx1RFEn: true, Tx2RFEn: true})
 		if err != nil {
 			return err
 		}
@@ -515,11 +924,17 @@ func (r *MFRC522) PCD_AntennaOn() error {
  * Turns the antenna off by disabling pins TX1 and TX2.
  */
 func (r *MFRC522) PCD_AntennaOff() error {
-	if value, err := r.PCD_ReadRegister(TxControlReg); err != nil {
+	r.lock()
+	defer r.unlock()
+	return r.antennaOff()
+}
+
+func (r *MFRC522) antennaOff() error {
+	if value, err := r.readRegister(TxControlReg); err != nil {
 		return err
 	} else {
-		if (value & 0x03) == 0x03 {
-			if err := r.PCD_ClearRegisterBitMask(TxControlReg, 0x03); err != nil {
+		if tx := DecodeTxControl(value); tx.Tx1RFEn && tx.Tx2RFEn {
+			if err := r.clearBits(TxControl{Tx1RFEn: true, Tx2RFEn: true}); err != nil {
 				return err
 			}
 			time.Sleep(INTERUPT_TIMEOUT)
@@ -531,61 +946,106 @@ func (r *MFRC522) PCD_AntennaOff() error {
 /**
  * Initializes the MFRC522 chip.
  */
-func (r *MFRC522) PCD_Init() error { // TODO error processing
+func (r *MFRC522) PCD_Init() error {
+	r.lock()
+	defer r.unlock()
+	return r.initChip()
+}
+
+func (r *MFRC522) initChip() error { // TODO error processing
+	r.timerPeriod = 0
 
 	// When communicating with a PICC we need a timeout if something goes wrong.
 	// f_timer = 13.56 MHz / (2*TPreScaler+1) where TPreScaler = [TPrescaler_Hi:TPrescaler_Lo].
 	// TPrescaler_Hi are the four low bits in TModeReg. TPrescaler_Lo is TPrescalerReg.
-	r.PCD_WriteRegister(TModeReg, 0x80)      // TAuto=1; timer starts automatically at the end of the transmission in all communication modes at all speeds
-	r.PCD_WriteRegister(TPrescalerReg, 0xA9) // TPreScaler = TModeReg[3..0]:TPrescalerReg, ie 0x0A9 = 169 => f_timer=40kHz, ie a timer period of 25ms.
-	r.PCD_WriteRegister(TReloadRegH, 0x03)   // Reload timer with 0x3E8 = 1000, ie 25ms before timeout.
-	r.PCD_WriteRegister(TReloadRegL, 0xE8)
-
-	// Reset baud rates
-	r.PCD_WriteRegister(TxModeReg, 0x00)
-	r.PCD_WriteRegister(RxModeReg, 0x00)
+	r.writeReg(TMode{TAuto: true})       // timer starts automatically at the end of the transmission in all communication modes at all speeds
+	r.writeRegister(TPrescalerReg, 0xA9) // TPreScaler = TModeReg[3..0]:TPrescalerReg, ie 0x0A9 = 169 => f_timer=40kHz, ie a timer period of 25ms.
+	r.writeRegister(TReloadRegH, 0x03)   // Reload timer with 0x3E8 = 1000, ie 25ms before timeout.
+	r.writeRegister(TReloadRegL, 0xE8)
+
+	// Reset baud rates and CRC, no parity bits in the FIFO
+	r.txRate, r.rxRate = bitRateUnknown, bitRateUnknown
+	for _, w := range []RegisterValue{TxMode{TxSpeed: BIT_RATE_106}, RxMode{RxSpeed: BIT_RATE_106}} {
+		if err := r.writeReg(w); err != nil {
+			return err
+		}
+	}
+	if err := r.clearBits(MfRx{ParityDisable: true}); err != nil {
+		return err
+	}
+	r.framing = RawConfig{}
 	// Reset ModWidthReg
-	r.PCD_WriteRegister(ModWidthReg, 0x26)
+	if err := r.writeRegister(ModWidthReg, modWidth[BIT_RATE_106]); err != nil {
+		return err
+	}
+	r.txRate, r.rxRate = BIT_RATE_106, BIT_RATE_106
 
-	r.PCD_WriteRegister(TxASKReg, 0x40) // Default 0x00. Force a 100 % ASK modulation independent of the ModGsPReg register setting
+	r.writeReg(TxASK{Force100ASK: true}) // Default 0x00. Force a 100 % ASK modulation independent of the ModGsPReg register setting
 	//r.PCD_AntennaOn()                   // Enable the antenna driver pins TX1 and TX2 (they were disabled by the reset)
 
+	if _, err := r.identify(); err != nil {
+		return err
+	}
+
+	// RF defaults of the options
+	if r.rfConfig != nil {
+		if err := r.setRFConfig(*r.rfConfig); err != nil {
+			return err
+		}
+	}
+	if r.antennaGain >= 0 {
+		if err := r.setAntennaGain(byte(r.antennaGain)); err != nil {
+			return err
+		}
+	}
+	if r.antennaOnAtInit {
+		return r.antennaOn()
+	}
 	return nil
 } // End PCD_Init()
 
 /**
  * Get the current MFRC522 Receiver Gain (RxGain[2:0]) value.
  * See 9.3.3.6 / table 98 in http://www.nxp.com/documents/data_sheet/MFRC522.pdf
- * NOTE: Return value scrubbed with (0x07<<4)=01110000b as RCFfgReg may use reserved bits.
+ * NOTE: Return value scrubbed with the RxGain mask as RCFfgReg may use reserved bits.
  *
- * @return Value of the RxGain, scrubbed to the 3 bits used.
+ * @return RxGain in bits 6..4, not shifted, the mask accepted by PCD_SetAntennaGain.
+ * DecodeRFCfg(val).RxGain is the typed value, see PCD_GetRFConfig.
  */
 func (r *MFRC522) PCD_GetAntennaGain() (byte, error) {
-	val, err := r.PCD_ReadRegister(RFCfgReg)
+	r.lock()
+	defer r.unlock()
+	return r.getAntennaGain()
+}
+
+func (r *MFRC522) getAntennaGain() (byte, error) {
+	val, err := r.readRegister(RFCfgReg)
 	if err != nil {
 		return 0, err
 	}
-	return val & (0x07 << 4), nil
+	return fieldRxGain.Set(byte(DecodeRFCfg(val).RxGain)), nil
 } // End PCD_GetAntennaGain()
 
 /**
  * Set the MFRC522 Receiver Gain (RxGain) to value specified by given mask.
  * See 9.3.3.6 / table 98 in http://www.nxp.com/documents/data_sheet/MFRC522.pdf
- * NOTE: Given mask is scrubbed with (0x07<<4)=01110000b as RCFfgReg may use reserved bits.
+ * NOTE: Given mask is scrubbed with the RxGain mask, the reserved bits of RFCfgReg keep their reset values.
  */
 func (r *MFRC522) PCD_SetAntennaGain(mask byte) error {
-	if val, err := r.PCD_GetAntennaGain(); err != nil {
+	r.lock()
+	defer r.unlock()
+	return r.setAntennaGain(mask)
+}
+
+func (r *MFRC522) setAntennaGain(mask byte) error {
+	if val, err := r.getAntennaGain(); err != nil {
 		return err
 	} else {
 		if val != mask {
 			// only bother if there is a change
-			// clear needed to allow 000 pattern
-			if er := r.PCD_ClearRegisterBitMask(RFCfgReg, (0x07 << 4)); er != nil {
+			if er := r.writeReg(RFCfg{RxGain: RxGain(fieldRxGain.Get(mask))}); er != nil {
 				return er
 			}
-			if er := r.PCD_SetRegisterBitMask(RFCfgReg, mask&(0x07<<4)); er != nil {
-				return er
-			} // only set RxGain[2:0] bits
 		}
 	}
 	return nil
@@ -594,93 +1054,82 @@ func (r *MFRC522) PCD_SetAntennaGain(mask byte) error {
 /**
  * Performs a self-test of the MFRC522
  * See 16.1.1 in http://www.nxp.com/documents/data_sheet/MFRC522.pdf
+ * Chips without a known self-test result are accepted if the core features work, see ChipInfo.
  *
  * @return Whether or not the test passed.
  */
 func (r *MFRC522) PCD_PerformSelfTest() error {
+	r.lock()
+	defer r.unlock()
+	return r.performSelfTest()
+}
+
+func (r *MFRC522) performSelfTest() error {
+	return r.selfTestChip(context.Background())
+}
+
+/**
+ * Digital self-test of 16.1.1 without the comparison, returns the 64 bytes of the FIFO.
+ */
+func (r *MFRC522) selfTestResult() ([]byte, error) {
 	// This follows directly the steps outlined in 16.1.1
 	// 1. Perform a soft reset.
 
-	if err := r.PCD_Reset(); err != nil {
-		return err
+	if err := r.reset(context.Background()); err != nil {
+		return nil, err
 	}
 
 	// 2. Clear the internal buffer by writing 25 bytes of 00h
 	emptyBuf := make([]byte, 25)
-	if err := r.PCD_SetRegisterBitMask(FIFOLevelReg, 0x80); err != nil { // flush the FIFO buffer
-		return err
+	if err := r.writeReg(FIFOLevel{FlushBuffer: true}); err != nil {
+		return nil, err
 	}
-	if err := r.PCD_WriteFIFOBuffer(emptyBuf); err != nil { // write 25 bytes of 00h to FIFO
-		return err
+	if err := r.writeFIFOBuffer(emptyBuf); err != nil { // write 25 bytes of 00h to FIFO
+		return nil, err
 	}
-	if err := r.PCD_WriteRegister(CommandReg, PCD_Mem); err != nil { // transfer to internal buffer
-		return err
+	if err := r.writeRegister(CommandReg, PCD_Mem); err != nil { // transfer to internal buffer
+		return nil, err
 	}
 	// 3. Enable self-test
-	if err := r.PCD_WriteRegister(AutoTestReg, 0x09); err != nil {
-		return err
+	if err := r.writeReg(AutoTest{SelfTest: 9}); err != nil {
+		return nil, err
 	}
 
 	// 4. Write 00h to FIFO buffer
-	if err := r.PCD_WriteRegister(FIFODataReg, 0x00); err != nil {
-		return err
+	if err := r.writeRegister(FIFODataReg, 0x00); err != nil {
+		return nil, err
 	}
 
 	// 5. Start self-test by issuing the CalcCRC command
-	if err := r.PCD_WriteRegister(CommandReg, PCD_CalcCRC); err != nil {
-		return err
+	if err := r.writeRegister(CommandReg, PCD_CalcCRC); err != nil {
+		return nil, err
 	}
 
-	// 6. Wait for self-test to complete
-	i := 0
-	for i := 0; i < 0xFF; i++ {
-		if n, err := r.PCD_ReadRegister(DivIrqReg); err != nil { // DivIrqReg[7..0] bits are: Set2 reserved reserved MfinActIRq reserved CRCIRq reserved reserved
-			return err
-		} else {
-			if n&0x04 > 0 { // CRCIRq bit set - calculation done
-				break
-			}
-		}
+	// 6. Wait for self-test to complete: CRCIRq
+	if err := r.waitIRq(context.Background(), DivIrqReg, DivIrq{CRCIRq: true}.Encode(), INTERUPT_TIMEOUT); err != nil {
+		return nil, err
 	}
-	if i == 0xFF {
-		return errors.New("MFRC522 self test error")
+	if n, err := r.readRegister(DivIrqReg); err != nil {
+		return nil, err
+	} else if !DecodeDivIrq(n).CRCIRq {
+		return nil, errors.New("MFRC522 self test error")
 	}
 
-	if err := r.PCD_WriteRegister(CommandReg, PCD_Idle); err != nil { // Stop calculating CRC for new content in the FIFO.
-		return err
+	if err := r.writeRegister(CommandReg, PCD_Idle); err != nil { // Stop calculating CRC for new content in the FIFO.
+		return nil, err
 	}
 	// 7. Read out resulting 64 bytes from the FIFO buffer.
-	result, err := r.PCD_ReadFIFOBuffer(64)
+	result, err := r.readFIFOBuffer(FIFO_SIZE)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Auto self-test done
 	// Reset AutoTestReg register to be 0 again. Required for normal operation.
-	if err := r.PCD_WriteRegister(AutoTestReg, 0x00); err != nil {
-		return err
-	}
-
-	// Determine firmware version (see section 9.3.4.8 in spec)
-	version, err := r.PCD_ReadRegister(VersionReg)
-	if err != nil {
-		return err
-	}
-	var expected []byte
-	switch version {
-	case VER_1_0:
-		expected = MFRC522_VER_1_0
-	case VER_2_0:
-		expected = MFRC522_VER_2_0
-	default:
-		return CommonError(fmt.Sprintf("Can't read version: %x", version))
-	}
-
-	if bytes.Compare(result, expected) != 0 {
-		return errors.New(fmt.Sprintf("MFRC522 Self test [ERROR]:\nexpected: [% x]\n   "+
-			"actual: [% x]", expected, result))
+	if err := r.writeReg(AutoTest{}); err != nil {
+		return nil, err
 	}
-	return nil
+	return result, nil
 } // End PCD_PerformSelfTest()
 
 /**
@@ -688,22 +1137,40 @@ func (r *MFRC522) PCD_PerformSelfTest() error {
  * Only "new" cards in state IDLE are invited. Sleeping cards in state HALT are ignored.
  */
 func (r *MFRC522) PICC_IsNewCardPresent() bool {
+	return r.PICC_IsNewCardPresentContext(context.Background())
+}
+
+/**
+ * PICC_IsNewCardPresent with a context, false if ctx is done.
+ */
+func (r *MFRC522) PICC_IsNewCardPresentContext(ctx context.Context) bool {
+	if err := r.lockContext(ctx); err != nil {
+		return false
+	}
+	defer r.unlock()
+	return r.isNewCardPresent(ctx)
+}
+
+func (r *MFRC522) isNewCardPresent(ctx context.Context) bool {
 
 	// Reset baud rates
-	res, err := r.PICC_RequestA()
-	log.Printf("PICC_RequestA:  len(%d)\n", len(res))
+	res, err := r.requestA(ctx)
 	if err != nil {
-		log.Printf("PICC_RequestA: %s\n", err.Error())
+		r.logger.Log(LOG_DEBUG, "PICC_RequestA", "len", len(res), "err", err)
+	} else {
+		r.logger.Log(LOG_DEBUG, "PICC_RequestA", "len", len(res))
 	}
 	return len(res) == 2
 }
 
 /**
  * Anticollision cycle ISO/IEC 14443-3:2011
+ * The UID bits known so far are sent with the SEL command, on a collision
+ * the bit at the collision position is set to 1 and the loop continues.
  */
-func (r *MFRC522) selectLevel(clevel int /* Cascade level */, duration time.Duration) (uid []byte, sak byte, err error) {
+func (r *MFRC522) selectLevel(ctx context.Context, clevel int /* Cascade level */, duration time.Duration) (uid []byte, sak byte, err error) {
 
-	log.Printf(" selectLevel %d\n", clevel)
+	r.logger.Log(LOG_DEBUG, "selectLevel", "level", clevel)
 
 	var selByte byte
 
@@ -716,79 +1183,124 @@ func (r *MFRC522) selectLevel(clevel int /* Cascade level */, duration time.Dura
 		selByte = PICC_CMD_SEL_CL3
 	default:
 		err = CommonError(fmt.Sprintf("Wrong cascade level %d\n", clevel))
-	}
-
-	nvb := byte(0x20)
-
-	dataToSend := []byte{selByte, nvb}
-
-	validBits := byte(0)
-
-	var result []byte
-	log.Printf("  send [% x]\n", dataToSend)
-	if result, err = r.PCD_CommunicateWithPICC(PCD_Transceive, dataToSend, &validBits, duration); err != nil {
 		return
 	}
 
-	if len(result) != 5 {
-		// UIDcl + BC
-		err = CommonError(fmt.Sprintf("Unexpected result length: level %d, len(result): %d\n", clevel, len(result)))
-		return
-	}
+	var uidBits [5]byte // UIDcl + BCC
+	knownBits := 0
 
-	// Check collision
-	var collOccr bool
-	if collOccr, err = r.PCD_IsCollisionOccure(); err != nil {
-		return
-	} else {
-		if !collOccr { // CollErr is 0!!
-			log.Printf(" CollErr is 0\n")
-			log.Printf("   validBits: %d\n", validBits)
-			var crc_a []byte
-			nvb = byte(0x70)
-			// Calculate CRC
-			dataToSend = append([]byte{selByte, nvb}, result...)
-			if crc_a, err = r.PCD_CalculateCRC(ISO_14443_CRC_RESET, dataToSend, INTERUPT_TIMEOUT); err != nil {
-				return
-			}
+	for {
+		// NVB: number of bytes in the upper nibble, number of bits in the lower one
+		nvb := byte(2+knownBits/8)<<4 | byte(knownBits%8)
+		txLastBits := byte(knownBits % 8)
+		frame := Frame{Data: append([]byte{selByte, nvb}, uidBits[:(knownBits+7)/8]...), LastBits: txLastBits}
+
+		var result Frame
+		if result, err = r.communicate(ctx, PCD_Transceive, frame, RawConfig{RxAlign: txLastBits}, duration); err != nil {
+			err = withCascadeLevel(err, clevel)
+			return
+		}
 
-			uid = result[:4]
-			dataToSend = append(dataToSend, crc_a...)
-			log.Printf("  send [% x]\n", dataToSend)
-			if result, err = r.PCD_CommunicateWithPICC(PCD_Transceive, dataToSend, &validBits, duration); err != nil {
-				return
-			}
-			if len(result) != 3 { // SAK must be exactly 24 bits (1 byte + CRC_A)
-				err = CommonError(fmt.Sprintf("SAK must be exactly 24 bits (1 byte + CRC_A). Received %d\n", len(result)))
-				return
-			}
+		var pos int
+		if pos, err = r.collisionPosition(); err != nil {
+			return
+		}
 
-			var crcRes []byte
-			if crcRes, err = r.PCD_CalculateCRC(ISO_14443_CRC_RESET, result[:1], duration); err != nil {
-				return
-			}
-			if bytes.Compare(crcRes, result[1:]) != 0 {
-				err = CommonError(fmt.Sprintf("CRC check SAK CRC_A error: \n"+
-					"calucated: [% x]\n received [% x]\n", crcRes, result[:2]))
+		// The first received byte completes the last known byte
+		index := knownBits / 8
+		if len(result.Data) == 0 || index+len(result.Data) > len(uidBits) {
+			err = UnexpectedResponse(fmt.Sprintf("Unexpected result length: level %d, len(result): %d\n", clevel, len(result.Data)))
+			return
+		}
+		mask := byte(1)<<txLastBits - 1
+		uidBits[index] = uidBits[index]&mask | result.Data[0]&^mask
+		copy(uidBits[index+1:], result.Data[1:])
+
+		if pos == 0 {
+			if index+len(result.Data) != len(uidBits) || result.LastBits != 0 {
+				// UIDcl + BCC
+				err = UnexpectedResponse(fmt.Sprintf("Unexpected result length: level %d, bits: %d\n", clevel, result.BitLen()))
 				return
 			}
+			break
+		}
+		if pos < 0 {
+			err = CollErrError(fmt.Sprintf("Collision position out of range, level %d\n", clevel))
+			return
+		}
 
-			sak = result[0]
+		// pos counts the FIFO bits from 1, the FIFO starts with the byte of the last known bit
+		collision := index*8 + pos - 1
+		if collision < knownBits || collision >= 32 {
+			err = CollErrError(fmt.Sprintf("Unexpected collision position %d, level %d, known bits %d\n", collision, clevel, knownBits))
 			return
-		} else {
-			err = CommonError("COLLISION CYCLE NOT SUPPORTED YET\n")
 		}
+		r.logger.Log(LOG_DEBUG, "collision", "level", clevel, "bit", collision)
+		knownBits = collision + 1
+		uidBits[collision/8] |= 1 << uint(collision%8)
+		if knownBits%8 != 0 {
+			uidBits[collision/8] &= byte(1)<<uint(knownBits%8) - 1
+		}
+		for i := (knownBits + 7) / 8; i < len(uidBits); i++ {
+			uidBits[i] = 0
+		}
+	}
+
+	if uidBits[0]^uidBits[1]^uidBits[2]^uidBits[3] != uidBits[4] {
+		err = UnexpectedResponse(fmt.Sprintf("BCC check error: level %d, [% x]\n", clevel, uidBits))
+		return
+	}
+
+	r.logger.Log(LOG_TRACE, "CollErr is 0", "level", clevel)
+	uid = append([]byte{}, uidBits[:4]...)
+	dataToSend := append([]byte{selByte, 0x70}, uidBits[:]...)
+	var result Frame
+	if result, err = r.transceivePICC(ctx, dataToSend, true, duration); err != nil {
+		err = withCascadeLevel(err, clevel)
+		return
+	}
+	if len(result.Data) != 1 || result.LastBits != 0 { // SAK must be exactly 24 bits (1 byte + CRC_A)
+		err = UnexpectedResponse(fmt.Sprintf("SAK must be exactly 24 bits (1 byte + CRC_A). Received [% x]\n", result.Data))
+		return
 	}
 
+	sak = result.Data[0]
 	return
 }
 
+/**
+ * Records the cascade level in a CommunicationError.
+ */
+func withCascadeLevel(err error, clevel int) error {
+	var commErr *CommunicationError
+	if errors.As(err, &commErr) {
+		commErr.CascadeLevel = clevel
+	}
+	return err
+}
+
 /**
  * Initialization and anticollision cycle ISO/IEC 14443-3:2011
  * BIG ENDIAN !!!
  */
 func (r *MFRC522) PICC_Select() (uid *UID, err error) {
+	return r.PICC_SelectContext(context.Background())
+}
+
+/**
+ * PICC_Select with a context, returns ctx.Err() if ctx is done.
+ */
+func (r *MFRC522) PICC_SelectContext(ctx context.Context) (uid *UID, err error) {
+	if err := r.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+	return r.selectPICC(ctx)
+}
+
+func (r *MFRC522) selectPICC(ctx context.Context) (uid *UID, err error) {
 	// Expected that RequestA sended by method PICC_IsNewCardPresent
+	r.crypto1 = nil
 
 	level := 1
 	var sak byte
@@ -796,7 +1308,7 @@ func (r *MFRC522) PICC_Select() (uid *UID, err error) {
 	uidVal := []byte{}
 
 	for {
-		if buffer, sak, err = r.selectLevel(level, INTERUPT_TIMEOUT); err != nil {
+		if buffer, sak, err = r.selectLevel(ctx, level, r.timeouts.Anticollision); err != nil {
 			return nil, err
 		}
 
@@ -835,7 +1347,7 @@ func (r *MFRC522) PICC_Select() (uid *UID, err error) {
 
 			break
 		}
-		log.Printf(" ------ Level: %d. UID is not complete. SAK: %08b\n", level, sak)
+		r.logger.Log(LOG_DEBUG, "UID is not complete", "level", level, "sak", fmt.Sprintf("%08b", sak))
 
 		level++
 	}
@@ -845,85 +1357,218 @@ func (r *MFRC522) PICC_Select() (uid *UID, err error) {
 /**
  */
 func (r *MFRC522) PICC_RequestA() ([]byte, error) {
+	return r.PICC_RequestAContext(context.Background())
+}
+
+/**
+ */
+func (r *MFRC522) PICC_RequestAContext(ctx context.Context) ([]byte, error) {
+	if err := r.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+	return r.requestA(ctx)
+}
+
+func (r *MFRC522) requestA(ctx context.Context) ([]byte, error) {
+	// Cards are woken up at 106 kbit/s
+	if err := r.setBitRate(BIT_RATE_106, BIT_RATE_106); err != nil {
+		return nil, err
+	}
 	validBits := byte(7)
-	return r.PCD_CommunicateWithPICC(PCD_Transceive, []byte{PICC_CMD_REQA}, &validBits, INTERUPT_TIMEOUT)
+	return r.communicateWithPICC(ctx, PCD_Transceive, []byte{PICC_CMD_REQA}, &validBits, r.timeouts.Anticollision)
 }
 
 /**
  */
 func (r *MFRC522) PICC_RequestWUPA() ([]byte, error) {
+	return r.PICC_RequestWUPAContext(context.Background())
+}
+
+/**
+ */
+func (r *MFRC522) PICC_RequestWUPAContext(ctx context.Context) ([]byte, error) {
+	if err := r.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+	return r.requestWUPA(ctx)
+}
+
+func (r *MFRC522) requestWUPA(ctx context.Context) ([]byte, error) {
+	if err := r.setBitRate(BIT_RATE_106, BIT_RATE_106); err != nil {
+		return nil, err
+	}
 	validBits := byte(7)
-	return r.PCD_CommunicateWithPICC(PCD_Transceive, []byte{PICC_CMD_WUPA}, &validBits, INTERUPT_TIMEOUT)
+	return r.communicateWithPICC(ctx, PCD_Transceive, []byte{PICC_CMD_WUPA}, &validBits, r.timeouts.Anticollision)
 }
 
+/**
+ * MIFARE Classic authentication with key A, the Crypto1 cipher runs on the host.
+ * See Dismantling_mifare_classic.pdf, the 32 bit UID is the last 4 bytes of uid.
+ */
 func (r *MFRC522) PICC_AuthentificateKeyA(uid UID, key []byte, sector byte) (err error) {
+	return r.PICC_AuthentificateKeyAContext(context.Background(), uid, key, sector)
+}
+
+/**
+ * PICC_AuthentificateKeyA with a context, returns ctx.Err() if ctx is done.
+ */
+func (r *MFRC522) PICC_AuthentificateKeyAContext(ctx context.Context, uid UID, key []byte, sector byte) (err error) {
+	if err := r.lockContext(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+	return r.authentificateKeyA(ctx, uid, key, sector)
+}
+
+func (r *MFRC522) authentificateKeyA(ctx context.Context, uid UID, key []byte, sector byte) (err error) {
+	if len(uid.Uid) < 4 {
+		return UsageError(fmt.Sprintf("Unexpected uid: [% x]", uid.Uid))
+	}
+	if len(key) != 6 {
+		return UsageError(fmt.Sprintf("MIFARE Classic key must be 6 bytes, got %d", len(key)))
+	}
+	var cryptoKey [6]byte
+	copy(cryptoKey[:], key)
 	buffer := []byte{PICC_CMD_MF_AUTH_KEY_A, sector}
-	crc := ISO14443aCRC(buffer)
-	buffer = append(buffer, crc...)
-	validBits := byte(0)
-	var nt []byte
-	if nt, err = r.PCD_CommunicateWithPICC(PCD_Transceive, buffer, &validBits, INTERUPT_TIMEOUT); err != nil {
-		return
+
+	// Nested authentication: the command and the tag nonce are encrypted with the current cipher
+	nested := r.crypto1 != nil
+	var ntFrame Frame
+	if nested {
+		buffer = append(buffer, ISO14443aCRC(buffer)...)
+		frame := Frame{Data: buffer, Parity: r.crypto1.Crypt(buffer, false)}
+		ntFrame, err = r.transceiveRaw(ctx, frame, RawConfig{ParityDisable: true, cipher: cipherNestedAuth}, r.timeouts.Default)
+	} else {
+		ntFrame, err = r.transceivePICC(ctx, buffer, false, r.timeouts.Default)
 	}
-	log.Printf("n_t: [% x]\n", nt)
-
-	init := make([]byte, 4)
-	init[0] = uid.Uid[0] ^ nt[0]
-	init[1] = uid.Uid[1] ^ nt[1]
-	init[2] = uid.Uid[2] ^ nt[2]
-	init[3] = uid.Uid[3] ^ nt[3]
-
-	// Инициализируем регистр линейного сдвига
-	lfsr32 := InitLfsr32FN(key)
-
-	// Генерируем ключ ks1
-	ks1, _ := lfsr32(init)
-
-	// Генерируем nr
-	nr := []byte{0x01, 0x20, 0x01, 0x45} //GenerateNR()
-
-	// Формируем nr^ks1
-	buffer = make([]byte, 8)
-	buffer[0] = ks1[0] ^ nr[0]
-	buffer[1] = ks1[1] ^ nr[1]
-	buffer[2] = ks1[2] ^ nr[2]
-	buffer[3] = ks1[3] ^ nr[3]
-
-	// Формируем вторую часть
-	ks2, _ := lfsr32(nr)
-
-	suc := InitSuc(nt)
-	// нужен suc2()
-	suc()
-	suc2, _ := suc()
-
-	// Формируем suc^ks2
-	buffer[4] = ks2[0] ^ suc2[0]
-	buffer[5] = ks2[1] ^ suc2[1]
-	buffer[6] = ks2[2] ^ suc2[2]
-	buffer[7] = ks2[3] ^ suc2[3]
-	log.Printf("nr^ks1, suc2(nt)^ks2: [% x]\n", buffer)
-
-	// Генерируем suc3^ks3
-	suc3, _ := suc()
-
-	ks3, _ := lfsr32(nil)
-	expeted := make([]byte, 4)
-	expeted[0] = suc3[0] ^ ks3[0]
-	expeted[1] = suc3[1] ^ ks3[1]
-	expeted[2] = suc3[2] ^ ks3[2]
-	expeted[3] = suc3[3] ^ ks3[3]
-	log.Printf("expected suc3^ks3: [% x]\n", expeted)
-
-	// Отправляем
-	var actual []byte
-	if actual, err = r.PCD_CommunicateWithPICC(PCD_Transceive, buffer, &validBits, INTERUPT_TIMEOUT); err != nil {
+	r.crypto1 = nil
+	if err != nil {
 		return
 	}
-	log.Printf("actual  suc3^ks3: [% x]\n", actual)
-	if bytes.Compare(actual, expeted) != 0 {
+	nt := ntFrame.Data
+	r.logger.Log(LOG_TRACE, "auth: tag nonce", "nt", nt, "nested", nested)
+	if len(nt) != 4 || ntFrame.LastBits != 0 {
+		return AuthentificationError(fmt.Sprintf("Unexpected tag nonce: [% x]", nt))
+	}
+	ntVal := bytesToUint32(nt)
+
+	// Feed uid^nt, the keystream of this phase only decrypts a nested nonce
+	crypto := NewCrypto1(cryptoKey)
+	uidVal := bytesToUint32(uid.Uid[len(uid.Uid)-4:])
+	if nested {
+		ntVal ^= crypto.Word(uidVal^ntVal, true)
+	} else {
+		crypto.Word(uidVal^ntVal, false)
+	}
+
+	// {nr}{ar}: nr^ks1, suc64(nt)^ks2 with encrypted parity, nr is fed into the LFSR
+	nr := GenerateNR()
+	reader := Frame{Data: make([]byte, 8), Parity: make([]byte, 8)}
+	for i, b := range nr {
+		reader.Data[i] = b ^ crypto.Byte(b, false)
+		reader.Parity[i] = OddParity(b) ^ crypto.ParityBit()
+	}
+	copy(reader.Data[4:], uint32ToBytes(PRNGSuccessor(ntVal, 64)))
+	copy(reader.Parity[4:], crypto.Crypt(reader.Data[4:], false))
+	r.logger.Log(LOG_TRACE, "auth: reader response", "nr^ks1,suc2(nt)^ks2", reader.Data)
+
+	// {at}: suc96(nt)^ks3
+	var at Frame
+	if at, err = r.transceiveRaw(ctx, reader, RawConfig{ParityDisable: true, cipher: cipherAuthReader}, r.timeouts.Default); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		return authError{err} // the card doesn't answer to a wrong key
+	}
+	if len(at.Data) != 4 || at.LastBits != 0 {
+		return AuthentificationError(fmt.Sprintf("Unexpected card result: [% x]", at.Data))
+	}
+	parity := crypto.Crypt(at.Data, true)
+	expected := uint32ToBytes(PRNGSuccessor(ntVal, 96))
+	r.logger.Log(LOG_TRACE, "auth: tag response", "expected", expected, "actual", at.Data)
+	if bytes.Compare(at.Data, expected) != 0 || !bytes.Equal(parity, at.Parity) {
+		r.logger.Log(LOG_DEBUG, "auth: unexpected tag response", "block", sector)
 		return AuthentificationError("Unexpected card result")
 	}
 
+	r.crypto1 = crypto
+	return nil
+}
+
+/**
+ * Reads 16 bytes from the active PICC, MIFARE_Read sends PICC_CMD_MF_READ.
+ * For MIFARE Ultralight 4 pages starting at blockAddr are returned.
+ */
+func (r *MFRC522) MIFARE_Read(blockAddr byte) ([]byte, error) {
+	return r.MIFARE_ReadContext(context.Background(), blockAddr)
+}
+
+/**
+ * MIFARE_Read with a context, returns ctx.Err() if ctx is done.
+ */
+func (r *MFRC522) MIFARE_ReadContext(ctx context.Context, blockAddr byte) ([]byte, error) {
+	if err := r.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+	return r.mifareRead(ctx, blockAddr)
+}
+
+func (r *MFRC522) mifareRead(ctx context.Context, blockAddr byte) ([]byte, error) {
+	result, err := r.transceivePICC(ctx, []byte{PICC_CMD_MF_READ, blockAddr}, true, r.timeouts.Default)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Data) != 16 || result.LastBits != 0 { // 16 bytes, CRC_A is checked
+		return nil, UnexpectedResponse(fmt.Sprintf("MIFARE_Read: unexpected response [% x], %d bits", result.Data, result.BitLen()))
+	}
+	return result.Data, nil
+}
+
+/**
+ * Writes 16 bytes to the active PICC, MIFARE_Write sends PICC_CMD_MF_WRITE.
+ * For MIFARE Ultralight only the first 4 bytes are written (compatibility write).
+ */
+func (r *MFRC522) MIFARE_Write(blockAddr byte, data []byte) error {
+	return r.MIFARE_WriteContext(context.Background(), blockAddr, data)
+}
+
+/**
+ * MIFARE_Write with a context, returns ctx.Err() if ctx is done.
+ */
+func (r *MFRC522) MIFARE_WriteContext(ctx context.Context, blockAddr byte, data []byte) error {
+	if err := r.lockContext(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+	return r.mifareWrite(ctx, blockAddr, data)
+}
+
+func (r *MFRC522) mifareWrite(ctx context.Context, blockAddr byte, data []byte) error {
+	if len(data) != 16 {
+		return UsageError(fmt.Sprintf("MIFARE_Write: 16 bytes expected, got %d", len(data)))
+	}
+	// Step 1: the command and the block address
+	if err := r.mifareTransceiveAck(ctx, []byte{PICC_CMD_MF_WRITE, blockAddr}); err != nil {
+		return err
+	}
+	// Step 2: the data
+	return r.mifareTransceiveAck(ctx, data)
+}
+
+/**
+ * Sends data with CRC_A and expects the 4 bit MF_ACK.
+ */
+func (r *MFRC522) mifareTransceiveAck(ctx context.Context, data []byte) error {
+	result, err := r.transceivePICC(ctx, data, false, r.timeouts.Write)
+	if err != nil {
+		return err
+	}
+	// The answer is a 4 bit frame
+	if len(result.Data) != 1 || result.LastBits != 4 || result.Data[0]&0x0F != MF_ACK {
+		return UnexpectedResponse(fmt.Sprintf("MIFARE NAK: [% x], %d bits", result.Data, result.BitLen()))
+	}
 	return nil
 }
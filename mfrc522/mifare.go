@@ -9,6 +9,15 @@ import (
 	"time"
 )
 
+const (
+	PICC_CMD_UL_WRITE    = 0xA2 // Writes one 4 byte page to the PICC
+	PICC_CMD_GET_VERSION = 0x60 // NTAG GET_VERSION, same code as MIFARE Classic AUTH KEY A
+
+	MF_ACK  = 0x0A // MIFARE Acknowledge, 4 bit frame
+	MF_NAK  = 0x04 // MIFARE Not Acknowledge: invalid operation
+	MF_NAK1 = 0x05 // MIFARE Not Acknowledge: CRC or parity error
+)
+
 type MFRC522Device interface {
 	PCD_CalculateCRC(crcResetValue int, buffer []byte, duration time.Duration) ([]byte, error)
 }
@@ -244,7 +253,7 @@ func InitLfsr32FN(key []byte) Lfsr32FN {
 			}
 
 			init := uint32(input[0]) | uint32(input[1])<<8 |
-				uint32(input[2])<<16 | uint32(input[3]<<24)
+				uint32(input[2])<<16 | uint32(input[3])<<24
 
 			for i := 0; i < rounds; i++ {
 				fn := f()
@@ -0,0 +1,283 @@
+// Offline verification of NTAG 424 DNA Secure Unique NFC (SUN) messages.
+// See NXP AN12196 "NTAG 424 DNA and NTAG 424 DNA TagTamper features and hints"
+// and NT4H2421Gx datasheet section 9.3 (Secure Dynamic Messaging).
+
+package mfrc522
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"fmt"
+)
+
+const (
+	NTAG424_KEY_SIZE = 16
+
+	// PICCDataTag bits (AN12196 table "PICCDataTag")
+	NTAG424_PICCDATA_UID_MIRROR    = 0x80 // UID is mirrored into PICCData
+	NTAG424_PICCDATA_CTR_MIRROR    = 0x40 // SDMReadCtr is mirrored into PICCData
+	NTAG424_PICCDATA_UID_LEN_MASK  = 0x0F // UID length, only 7 is supported by the tag
+	NTAG424_UID_SIZE               = 7
+	NTAG424_SDM_READ_CTR_SIZE      = 3
+	NTAG424_SDM_MAC_SIZE           = 8
+	NTAG424_SESSION_VECTOR_ENC_TAG = 0xC33C // SV1 prefix for KSesSDMFileReadENC
+	NTAG424_SESSION_VECTOR_MAC_TAG = 0x3CC3 // SV2 prefix for KSesSDMFileReadMAC
+)
+
+// PICC data mirrored by a NTAG 424 DNA into a SUN message
+type SDMPICCData struct {
+	Uid        []byte // 7 byte UID, nil if UID mirroring is disabled
+	ReadCtr    uint32 // SDMReadCtr, valid if HasReadCtr is set
+	HasReadCtr bool
+}
+
+// Result of a successfully verified SUN message
+type SUNMessage struct {
+	PICCData SDMPICCData
+	FileData []byte // decrypted ENCFileData, nil if the message doesn't carry one
+}
+
+// SUNVerifier checks SUN messages without the tag or any backend service.
+type SUNVerifier struct {
+	MetaReadKey []byte // SDMMetaReadKey, used to decrypt PICCData
+	FileReadKey []byte // SDMFileReadKey, used to derive SDMMAC and ENCFileData session keys
+}
+
+/**
+ * Decrypts the PICCData (PICCENCData) of a SUN message.
+ * See AN12196 section 3.3 "SUN messages with PICCData encrypted".
+ */
+func (v *SUNVerifier) DecryptPICCData(encPICCData []byte) (*SDMPICCData, error) {
+	if len(encPICCData) != aes.BlockSize {
+		return nil, SUNVerificationError(fmt.Sprintf("Unexpected PICCData length: %d", len(encPICCData)))
+	}
+	block, err := newNTAG424Cipher(v.MetaReadKey)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, aes.BlockSize)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(plain, encPICCData)
+
+	tag := plain[0]
+	data := plain[1:]
+	picc := &SDMPICCData{}
+	if tag&NTAG424_PICCDATA_UID_MIRROR != 0 {
+		if int(tag&NTAG424_PICCDATA_UID_LEN_MASK) != NTAG424_UID_SIZE {
+			return nil, SUNVerificationError(fmt.Sprintf("Unexpected PICCDataTag: %02x", tag))
+		}
+		picc.Uid = append([]byte{}, data[:NTAG424_UID_SIZE]...)
+		data = data[NTAG424_UID_SIZE:]
+	}
+	if tag&NTAG424_PICCDATA_CTR_MIRROR != 0 {
+		picc.ReadCtr = uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+		picc.HasReadCtr = true
+	}
+	if picc.Uid == nil && !picc.HasReadCtr {
+		return nil, SUNVerificationError(fmt.Sprintf("PICCData doesn't contain UID or SDMReadCtr. PICCDataTag: %02x", tag))
+	}
+	return picc, nil
+}
+
+/**
+ * Derives the SDM session keys KSesSDMFileReadENC and KSesSDMFileReadMAC.
+ * See NT4H2421Gx datasheet 9.3.9.1.
+ */
+func (v *SUNVerifier) SessionKeys(picc *SDMPICCData) (encKey, macKey []byte, err error) {
+	if encKey, err = aesCMAC(v.FileReadKey, picc.sessionVector(NTAG424_SESSION_VECTOR_ENC_TAG)); err != nil {
+		return
+	}
+	macKey, err = aesCMAC(v.FileReadKey, picc.sessionVector(NTAG424_SESSION_VECTOR_MAC_TAG))
+	return
+}
+
+/**
+ * Checks SDMMAC. macInput is the part of the NDEF file starting at SDMMACInputOffset
+ * and ending right before the SDMMAC mirror, as ASCII (for example "<ENCFileData>&cmac=").
+ * It is empty if SDMMACInputOffset equals SDMMACOffset.
+ */
+func (v *SUNVerifier) VerifyMAC(picc *SDMPICCData, macInput, sdmMAC []byte) error {
+	if len(sdmMAC) != NTAG424_SDM_MAC_SIZE {
+		return SUNVerificationError(fmt.Sprintf("Unexpected SDMMAC length: %d", len(sdmMAC)))
+	}
+	_, macKey, err := v.SessionKeys(picc)
+	if err != nil {
+		return err
+	}
+	mac, err := aesCMAC(macKey, macInput)
+	if err != nil {
+		return err
+	}
+	expected := truncateMAC(mac)
+	if subtle.ConstantTimeCompare(expected, sdmMAC) != 1 {
+		return SUNVerificationError("SDMMAC mismatch")
+	}
+	return nil
+}
+
+/**
+ * Decrypts ENCFileData. SDMReadCtr must be present in PICCData.
+ * See AN12196 section 3.4 "SUN messages with ENCFileData".
+ */
+func (v *SUNVerifier) DecryptFileData(picc *SDMPICCData, encFileData []byte) ([]byte, error) {
+	if len(encFileData) == 0 || len(encFileData)%aes.BlockSize != 0 {
+		return nil, SUNVerificationError(fmt.Sprintf("Unexpected ENCFileData length: %d", len(encFileData)))
+	}
+	if !picc.HasReadCtr {
+		return nil, SUNVerificationError("SDMReadCtr is required to decrypt ENCFileData")
+	}
+	encKey, _, err := v.SessionKeys(picc)
+	if err != nil {
+		return nil, err
+	}
+	block, err := newNTAG424Cipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	// IV = E(KSesSDMFileReadENC; SDMReadCtr || 0x00..00)
+	iv := make([]byte, aes.BlockSize)
+	iv[0], iv[1], iv[2] = byte(picc.ReadCtr), byte(picc.ReadCtr>>8), byte(picc.ReadCtr>>16)
+	block.Encrypt(iv, iv)
+
+	result := make([]byte, len(encFileData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(result, encFileData)
+	return result, nil
+}
+
+/**
+ * Verifies a SUN message with encrypted PICCData.
+ * encFileData may be nil if ENCFileData mirroring is disabled.
+ */
+func (v *SUNVerifier) Verify(encPICCData, encFileData, macInput, sdmMAC []byte) (*SUNMessage, error) {
+	picc, err := v.DecryptPICCData(encPICCData)
+	if err != nil {
+		return nil, err
+	}
+	return v.verify(picc, encFileData, macInput, sdmMAC)
+}
+
+/**
+ * Verifies a SUN message with plain UID and SDMReadCtr mirroring.
+ * uid or readCtr may be nil if the corresponding mirror is disabled.
+ * readCtr is the 3 byte counter as it is printed in the message (MSB first).
+ */
+func (v *SUNVerifier) VerifyPlain(uid, readCtr, encFileData, macInput, sdmMAC []byte) (*SUNMessage, error) {
+	picc := &SDMPICCData{}
+	if uid != nil {
+		if len(uid) != NTAG424_UID_SIZE {
+			return nil, SUNVerificationError(fmt.Sprintf("Unexpected UID length: %d", len(uid)))
+		}
+		picc.Uid = append([]byte{}, uid...)
+	}
+	if readCtr != nil {
+		if len(readCtr) != NTAG424_SDM_READ_CTR_SIZE {
+			return nil, SUNVerificationError(fmt.Sprintf("Unexpected SDMReadCtr length: %d", len(readCtr)))
+		}
+		picc.ReadCtr = uint32(readCtr[0])<<16 | uint32(readCtr[1])<<8 | uint32(readCtr[2])
+		picc.HasReadCtr = true
+	}
+	return v.verify(picc, encFileData, macInput, sdmMAC)
+}
+
+func (v *SUNVerifier) verify(picc *SDMPICCData, encFileData, macInput, sdmMAC []byte) (*SUNMessage, error) {
+	if err := v.VerifyMAC(picc, macInput, sdmMAC); err != nil {
+		return nil, err
+	}
+	msg := &SUNMessage{PICCData: *picc}
+	if encFileData != nil {
+		fileData, err := v.DecryptFileData(picc, encFileData)
+		if err != nil {
+			return nil, err
+		}
+		msg.FileData = fileData
+	}
+	return msg, nil
+}
+
+// SV1/SV2 = tag || 00 01 00 80 || [UID] || [SDMReadCtr], zero padded to the AES block size
+func (p *SDMPICCData) sessionVector(tag uint16) []byte {
+	var buff bytes.Buffer
+	buff.Write([]byte{byte(tag >> 8), byte(tag), 0x00, 0x01, 0x00, 0x80})
+	buff.Write(p.Uid)
+	if p.HasReadCtr {
+		buff.Write([]byte{byte(p.ReadCtr), byte(p.ReadCtr >> 8), byte(p.ReadCtr >> 16)})
+	}
+	if rem := buff.Len() % aes.BlockSize; rem != 0 {
+		buff.Write(make([]byte, aes.BlockSize-rem))
+	}
+	return buff.Bytes()
+}
+
+func newNTAG424Cipher(key []byte) (cipher.Block, error) {
+	if len(key) != NTAG424_KEY_SIZE {
+		return nil, SUNVerificationError(fmt.Sprintf("Unexpected key length: %d", len(key)))
+	}
+	return aes.NewCipher(key)
+}
+
+// MACt: the even-numbered bytes (1-based) of the CMAC, see NT4H2421Gx datasheet 9.1.3
+func truncateMAC(mac []byte) []byte {
+	result := make([]byte, 0, len(mac)/2)
+	for i := 1; i < len(mac); i += 2 {
+		result = append(result, mac[i])
+	}
+	return result
+}
+
+// AES-CMAC, RFC 4493
+func aesCMAC(key, msg []byte) ([]byte, error) {
+	block, err := newNTAG424Cipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subkey generation
+	k1 := make([]byte, aes.BlockSize)
+	block.Encrypt(k1, k1)
+	cmacShift(k1)
+	k2 := append([]byte{}, k1...)
+	cmacShift(k2)
+
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	last := make([]byte, aes.BlockSize)
+	if n > 0 && len(msg)%aes.BlockSize == 0 {
+		copy(last, msg[(n-1)*aes.BlockSize:])
+		xorBytes(last, k1)
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		tail := msg[(n-1)*aes.BlockSize:]
+		copy(last, tail)
+		last[len(tail)] = 0x80
+		xorBytes(last, k2)
+	}
+
+	mac := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		xorBytes(mac, msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(mac, mac)
+	}
+	xorBytes(mac, last)
+	block.Encrypt(mac, mac)
+	return mac, nil
+}
+
+// Left shift by one bit with conditional xor of Rb = 0x87
+func cmacShift(b []byte) {
+	msb := b[0] & 0x80
+	for i := 0; i < len(b)-1; i++ {
+		b[i] = b[i]<<1 | b[i+1]>>7
+	}
+	b[len(b)-1] <<= 1
+	if msb != 0 {
+		b[len(b)-1] ^= 0x87
+	}
+}
+
+func xorBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
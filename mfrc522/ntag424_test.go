@@ -0,0 +1,93 @@
+package mfrc522
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestAesCMAC(t *testing.T) {
+	is := is.New(t)
+
+	// RFC 4493 section 4
+	key := mustHex("2b7e151628aed2a6abf7158809cf4f3c")
+	msg := mustHex("6bc1bee22e409f96e93d7e117393172a" + "ae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52ef" + "f69f2445df4f9b17ad2b417be66c3710")
+
+	test_data := map[int]string{
+		0:  "bb1d6929e95937287fa37d129b756746",
+		16: "070a16b46b4d4144f79bdd9dd04a287c",
+		40: "dfa66747de9ae63030ca32611497c827",
+		64: "51f0bebf7e3b9d92fc49741779363cfe",
+	}
+
+	for ln, expected := range test_data {
+		mac, err := aesCMAC(key, msg[:ln])
+		is.NoErr(err)
+		is.True(bytes.Compare(mac, mustHex(expected)) == 0)
+	}
+}
+
+func TestSUNVerifierPICCData(t *testing.T) {
+	is := is.New(t)
+
+	// AN12196 section 3.3: https://choose.url.com/ntag424?e=EF963FF7828658A599F3041510671E88&c=94EED9EE65337086
+	verifier := &SUNVerifier{
+		MetaReadKey: make([]byte, NTAG424_KEY_SIZE),
+		FileReadKey: make([]byte, NTAG424_KEY_SIZE),
+	}
+
+	picc, err := verifier.DecryptPICCData(mustHex("EF963FF7828658A599F3041510671E88"))
+	is.NoErr(err)
+	is.True(bytes.Compare(picc.Uid, mustHex("04DE5F1EACC040")) == 0)
+	is.True(picc.HasReadCtr)
+	is.Equal(picc.ReadCtr, uint32(0x3D))
+
+	_, macKey, err := verifier.SessionKeys(picc)
+	is.NoErr(err)
+	is.True(bytes.Compare(macKey, mustHex("3FB5F6E3A807A03D5E3570ACE393776F")) == 0)
+
+	msg, err := verifier.Verify(mustHex("EF963FF7828658A599F3041510671E88"), nil, nil, mustHex("94EED9EE65337086"))
+	is.NoErr(err)
+	is.True(msg.FileData == nil)
+
+	_, err = verifier.Verify(mustHex("EF963FF7828658A599F3041510671E88"), nil, nil, mustHex("94EED9EE65337087"))
+	is.True(err != nil)
+}
+
+func TestSUNVerifierFileData(t *testing.T) {
+	is := is.New(t)
+
+	// AN12196 section 3.4: https://www.my424dna.com/?picc_data=FD91EC264309878BE6345CBE53BADF40&
+	// enc=CEE9A53E3E463EF1F459635736738962&cmac=ECC1E7F6C6C73BF6
+	verifier := &SUNVerifier{
+		MetaReadKey: make([]byte, NTAG424_KEY_SIZE),
+		FileReadKey: make([]byte, NTAG424_KEY_SIZE),
+	}
+
+	msg, err := verifier.Verify(mustHex("FD91EC264309878BE6345CBE53BADF40"),
+		mustHex("CEE9A53E3E463EF1F459635736738962"),
+		[]byte("CEE9A53E3E463EF1F459635736738962&cmac="),
+		mustHex("ECC1E7F6C6C73BF6"))
+	is.NoErr(err)
+	is.True(bytes.Compare(msg.PICCData.Uid, mustHex("04958CAA5C5E80")) == 0)
+	is.Equal(msg.PICCData.ReadCtr, uint32(8))
+	is.Equal(string(msg.FileData), "xxxxxxxxxxxxxxxx")
+
+	// tampered ENCFileData
+	_, err = verifier.Verify(mustHex("FD91EC264309878BE6345CBE53BADF40"),
+		mustHex("CEE9A53E3E463EF1F459635736738963"),
+		[]byte("CEE9A53E3E463EF1F459635736738963&cmac="),
+		mustHex("ECC1E7F6C6C73BF6"))
+	is.True(err != nil)
+}
@@ -0,0 +1,250 @@
+// Functional options of New.
+
+package mfrc522
+
+import (
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+)
+
+type options struct {
+	spiSpeed        physic.Frequency
+	spiMode         spi.Mode
+	spiSet          bool
+	resetPin        gpio.PinOut
+	irqPin          gpio.PinIn
+	timeouts        Timeouts
+	antennaGain     int
+	rfConfig        *RFConfig
+	antennaOnAtInit bool
+	hardwareCRC     bool
+	logger          Logger
+	tracer          Tracer
+	timerDrift      float64
+}
+
+type Option func(o *options) error
+
+/**
+ * SPI clock, 10MHz by default. Needs a transport created by SPIPort.
+ */
+func WithSPISpeed(speed physic.Frequency) Option {
+	return func(o *options) error {
+		if speed <= 0 || speed > SPI_DEFAULT_SPEED {
+			return UsageError(fmt.Sprintf("SPI speed %s out of range (0, %s]", speed, SPI_DEFAULT_SPEED))
+		}
+		o.spiSpeed, o.spiSet = speed, true
+		return nil
+	}
+}
+
+/**
+ * SPI mode, spi.Mode0 by default. Needs a transport created by SPIPort.
+ */
+func WithSPIMode(mode spi.Mode) Option {
+	return func(o *options) error {
+		o.spiMode, o.spiSet = mode, true
+		return nil
+	}
+}
+
+/**
+ * NRSTPD pin. Without it PCD_Reset does a soft reset.
+ */
+func WithResetPin(pin gpio.PinOut) Option {
+	return func(o *options) error {
+		o.resetPin = pin
+		return nil
+	}
+}
+
+/**
+ * IRQ pin, configured as input with pull up.
+ */
+func WithIRQPin(pin gpio.PinIn) Option {
+	return func(o *options) error {
+		o.irqPin = pin
+		return nil
+	}
+}
+
+/**
+ * Time to wait for the answer of a PICC to authentication and read commands,
+ * INTERUPT_TIMEOUT by default.
+ */
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout <= 0 {
+			return UsageError(fmt.Sprintf("Timeout must be positive: %s", timeout))
+		}
+		if _, _, err := TimerSettings(timeout); err != nil {
+			return err
+		}
+		o.timeouts.Default = timeout
+		return nil
+	}
+}
+
+/**
+ * Timeouts of all command classes, DefaultTimeouts by default.
+ */
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(o *options) error {
+		for _, timeout := range []time.Duration{timeouts.Anticollision, timeouts.Default, timeouts.Write} {
+			if timeout <= 0 {
+				return UsageError(fmt.Sprintf("Timeout must be positive: %s", timeout))
+			}
+			if _, _, err := TimerSettings(timeout); err != nil {
+				return err
+			}
+		}
+		o.timeouts = timeouts
+		return nil
+	}
+}
+
+/**
+ * Receiver gain set by PCD_Init, RxGain in bits 6..4 as for PCD_SetAntennaGain.
+ */
+func WithAntennaGain(mask byte) Option {
+	return func(o *options) error {
+		if mask&^fieldRxGain.Mask != 0 {
+			return UsageError(fmt.Sprintf("Unexpected antenna gain: %02x", mask))
+		}
+		o.antennaGain = int(mask)
+		return nil
+	}
+}
+
+/**
+ * RF front end set by PCD_Init, for example CalibrationReport.Recommended.
+ * WithAntennaGain overrides RxGain.
+ */
+func WithRFConfig(config RFConfig) Option {
+	return func(o *options) error {
+		if err := config.validate(); err != nil {
+			return err
+		}
+		o.rfConfig = &config
+		return nil
+	}
+}
+
+/**
+ * PCD_Init turns the antenna on.
+ */
+func WithAntennaOn() Option {
+	return func(o *options) error {
+		o.antennaOnAtInit = true
+		return nil
+	}
+}
+
+/**
+ * The chip appends and checks CRC_A of the PICC commands (TxCRCEn, RxCRCEn)
+ * instead of ISO14443aCRC. A wrong CRC_A is reported as ErrCRC.
+ * Frames encrypted on the host keep the CRC calculated in Go.
+ */
+func WithHardwareCRC() Option {
+	return func(o *options) error {
+		o.hardwareCRC = true
+		return nil
+	}
+}
+
+func WithLogger(logger Logger) Option {
+	return func(o *options) error {
+		o.logger = logger
+		return nil
+	}
+}
+
+/**
+ * Records every frame sent to and received from the PICC, see TraceRecorder.
+ */
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) error {
+		o.tracer = tracer
+		return nil
+	}
+}
+
+/**
+ * Relative error of the timer of the chip, for example +0.1 if the periods
+ * are 10% longer. The timer check of PCD_Diagnostics measures it.
+ * The chip gets QUIRK_TIMER_DRIFT and setTimer corrects the periods.
+ */
+func WithTimerDrift(drift float64) Option {
+	return func(o *options) error {
+		if drift <= -0.5 || drift >= 1 {
+			return UsageError(fmt.Sprintf("Timer drift %+.2f out of range (-0.5, 1)", drift))
+		}
+		o.timerDrift = drift
+		return nil
+	}
+}
+
+/**
+ * Creates the driver and resets the chip. Pins are optional.
+ * The transport is one of SPITransport, I2CTransport or UARTTransport.
+ */
+func New(transport Transport, opts ...Option) (*MFRC522, error) {
+	if transport == nil {
+		return nil, CommonError("Transport is not set")
+	}
+
+	o := options{
+		spiSpeed:    SPI_DEFAULT_SPEED,
+		spiMode:     SPI_DEFAULT_MODE,
+		timeouts:    DefaultTimeouts,
+		antennaGain: -1,
+	}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if t, ok := transport.(*SPITransport); ok && t.Conn == nil {
+		if err := t.connect(o.spiSpeed, o.spiMode); err != nil {
+			return nil, err
+		}
+	} else if o.spiSet {
+		return nil, UsageError("SPI speed and mode need a transport created by SPIPort")
+	}
+
+	if o.resetPin != nil {
+		if err := o.resetPin.Out(gpio.High); err != nil {
+			return nil, err
+		}
+	}
+	if o.irqPin != nil {
+		if err := o.irqPin.In(gpio.PullUp, gpio.NoEdge); err != nil {
+			return nil, err
+		}
+	}
+
+	reader := &MFRC522{
+		transport:       transport,
+		timeouts:        o.timeouts,
+		resetPin:        o.resetPin,
+		irqPin:          o.irqPin,
+		antennaGain:     o.antennaGain,
+		rfConfig:        o.rfConfig,
+		antennaOnAtInit: o.antennaOnAtInit,
+		hardwareCRC:     o.hardwareCRC,
+		tracer:          o.tracer,
+		timerDrift:      o.timerDrift,
+		logger:          nopLogger{},
+		sem:             make(chan struct{}, 1),
+	}
+	reader.SetLogger(o.logger)
+
+	reader.PCD_Reset()
+
+	return reader, nil
+}
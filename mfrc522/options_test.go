@@ -0,0 +1,67 @@
+package mfrc522
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+)
+
+type recordingPort struct {
+	*MFRC522Simulator
+	speed physic.Frequency
+}
+
+func (p *recordingPort) Connect(f physic.Frequency, mode spi.Mode, bits int) (spi.Conn, error) {
+	p.speed = f
+	return p.MFRC522Simulator.Connect(f, mode, bits)
+}
+
+func TestNewOptions(t *testing.T) {
+	is := is.New(t)
+	port := &recordingPort{MFRC522Simulator: NewMFRC522Simulator()}
+
+	// No pins: soft reset, no IRQ
+	reader, err := New(SPIPort(port),
+		WithSPISpeed(physic.MegaHertz),
+		WithTimeout(10*time.Millisecond),
+		WithAntennaGain(0x70),
+		WithAntennaOn())
+	is.NoErr(err)
+	is.Equal(port.speed, physic.MegaHertz)
+	is.Equal(reader.timeouts.Default, 10*time.Millisecond)
+	is.NoErr(reader.PCD_Reset())
+
+	is.NoErr(reader.PCD_Init())
+	gain, err := reader.PCD_GetAntennaGain()
+	is.NoErr(err)
+	is.Equal(gain, byte(0x70))
+	txControl, err := reader.PCD_ReadRegister(TxControlReg)
+	is.NoErr(err)
+	is.Equal(txControl&0x03, byte(0x03))
+}
+
+func TestNewOptionErrors(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+
+	_, err := New(nil)
+	is.True(err != nil)
+	_, err = New(&SPITransport{Conn: sim}, WithSPISpeed(physic.MegaHertz)) // already connected
+	is.True(errors.Is(err, ErrUsage))
+	_, err = New(SPIPort(sim), WithSPISpeed(20*physic.MegaHertz))
+	is.True(errors.Is(err, ErrUsage))
+	_, err = New(SPIPort(sim), WithAntennaGain(0x07))
+	is.True(errors.Is(err, ErrUsage))
+	_, err = New(SPIPort(sim), WithTimeout(0))
+	is.True(errors.Is(err, ErrUsage))
+	_, err = New(SPIPort(sim), WithTimeout(time.Hour)) // the timer can't count it
+	is.True(errors.Is(err, ErrUsage))
+	timeouts := DefaultTimeouts
+	timeouts.Anticollision = 10 * time.Nanosecond
+	_, err = New(SPIPort(sim), WithTimeouts(timeouts))
+	is.True(errors.Is(err, ErrUsage))
+}
@@ -0,0 +1,292 @@
+// Register map with the bitfields of datasheet 9.3, register snapshots, diff and restore.
+
+package mfrc522
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+// Bits of a register, Mask is in register position
+type RegisterField struct {
+	Name string
+	Mask byte
+}
+
+/**
+ * Value of the field in value, shifted down.
+ */
+func (f RegisterField) Get(value byte) byte {
+	return value & f.Mask >> uint(bits.TrailingZeros8(f.Mask))
+}
+
+/**
+ * The field value v in register position, the bits beyond the field are dropped.
+ */
+func (f RegisterField) Set(v byte) byte {
+	return v << uint(bits.TrailingZeros8(f.Mask)) & f.Mask
+}
+
+type Register struct {
+	Address byte
+	Name    string
+	Fields  []RegisterField // from the MSB to the LSB, unused bits are left out
+	Restore byte            // bits written back by PCD_Restore, 0 for status, command and test registers
+}
+
+func regField(name string, mask byte) RegisterField {
+	return RegisterField{Name: name, Mask: mask}
+}
+
+// The registers of the MFRC522, the reserved addresses are left out.
+// The fields of the registers with a typed value are declared in bitfields.go.
+var Registers = []Register{
+	// Page 0: Command and status
+	{CommandReg, "CommandReg", []RegisterField{fieldRcvOff, fieldPowerDown, fieldCommand}, 0},
+	{ComIEnReg, "ComIEnReg", []RegisterField{fieldIRqInv, fieldTxIEn, fieldRxIEn, fieldIdleIEn,
+		fieldHiAlertIEn, fieldLoAlertIEn, fieldErrIEn, fieldTimerIEn}, 0xFF},
+	{DivIEnReg, "DivIEnReg", []RegisterField{regField("IRQPushPull", 0x80), regField("MfinActIEn", 0x10), regField("CRCIEn", 0x04)}, 0x94},
+	{ComIrqReg, "ComIrqReg", []RegisterField{fieldSet1, fieldTxIRq, fieldRxIRq, fieldIdleIRq,
+		fieldHiAlertIRq, fieldLoAlertIRq, fieldErrIRq, fieldTimerIRq}, 0},
+	{DivIrqReg, "DivIrqReg", []RegisterField{fieldSet2, fieldMfinActIRq, fieldCRCIRq}, 0},
+	{ErrorReg, "ErrorReg", []RegisterField{fieldWrErr, fieldTempErr, fieldBufferOvfl, fieldCollErr, fieldCRCErr, fieldParityErr, fieldProtocolErr}, 0},
+	{Status1Reg, "Status1Reg", []RegisterField{fieldCRCOk, fieldCRCReady, fieldIRq, fieldTRunning, fieldHiAlert, fieldLoAlert}, 0},
+	{Status2Reg, "Status2Reg", []RegisterField{fieldTempSensClear, fieldI2CForceHS, fieldMFCrypto1On, fieldModemState}, 0},
+	{FIFODataReg, "FIFODataReg", []RegisterField{regField("FIFOData", 0xFF)}, 0},
+	{FIFOLevelReg, "FIFOLevelReg", []RegisterField{fieldFlushBuffer, fieldFIFOLevel}, 0},
+	{WaterLevelReg, "WaterLevelReg", []RegisterField{regField("WaterLevel", 0x3F)}, 0x3F},
+	{ControlReg, "ControlReg", []RegisterField{fieldTStopNow, fieldTStartNow, fieldRxLastBits}, 0},
+	{BitFramingReg, "BitFramingReg", []RegisterField{fieldStartSend, fieldRxAlign, fieldTxLastBits}, 0x77},
+	{CollReg, "CollReg", []RegisterField{fieldValuesAfterColl, fieldCollPosNotValid, fieldCollPos}, 0x80},
+
+	// Page 1: Command
+	{ModeReg, "ModeReg", []RegisterField{fieldMSBFirst, fieldTxWaitRF, fieldPolMFin, fieldCRCPreset}, 0xFF},
+	{TxModeReg, "TxModeReg", []RegisterField{fieldTxCRCEn, fieldTxSpeed, fieldInvMod}, 0xFF},
+	{RxModeReg, "RxModeReg", []RegisterField{fieldRxCRCEn, fieldRxSpeed, fieldRxNoErr, fieldRxMultiple}, 0xFF},
+	{TxControlReg, "TxControlReg", []RegisterField{fieldInvTx2RFOn, fieldInvTx1RFOn, fieldInvTx2RFOff,
+		fieldInvTx1RFOff, fieldTx2CW, fieldTx2RFEn, fieldTx1RFEn}, 0xFF},
+	{TxASKReg, "TxASKReg", []RegisterField{fieldForce100ASK}, 0xFF},
+	{TxSelReg, "TxSelReg", []RegisterField{regField("DriverSel", 0x30), regField("MFOutSel", 0x0F)}, 0xFF},
+	{RxSelReg, "RxSelReg", []RegisterField{regField("UARTSel", 0xC0), regField("RxWait", 0x3F)}, 0xFF},
+	{RxThresholdReg, "RxThresholdReg", []RegisterField{fieldMinLevel, fieldCollLevel}, 0xFF},
+	{DemodReg, "DemodReg", []RegisterField{fieldAddIQ, fieldFixIQ, fieldTPrescalEven, fieldTauRcv, fieldTauSync}, 0xFF},
+	{MfTxReg, "MfTxReg", []RegisterField{regField("TxWait", 0x03)}, 0xFF},
+	{MfRxReg, "MfRxReg", []RegisterField{fieldParityDisable}, 0xFF},
+	// The speed of the UART is not restored, the host would lose the chip
+	{SerialSpeedReg, "SerialSpeedReg", []RegisterField{regField("BR_T0", 0xE0), regField("BR_T1", 0x1F)}, 0},
+
+	// Page 2: Configuration
+	{CRCResultRegH, "CRCResultRegH", []RegisterField{regField("CRCResultMSB", 0xFF)}, 0},
+	{CRCResultRegL, "CRCResultRegL", []RegisterField{regField("CRCResultLSB", 0xFF)}, 0},
+	{ModWidthReg, "ModWidthReg", []RegisterField{regField("ModWidth", 0xFF)}, 0xFF},
+	{RFCfgReg, "RFCfgReg", []RegisterField{fieldRxGain}, 0xFF},
+	{GsNReg, "GsNReg", []RegisterField{fieldCWGsN, fieldModGsN}, 0xFF},
+	{CWGsPReg, "CWGsPReg", []RegisterField{fieldCWGsP}, 0xFF},
+	{ModGsPReg, "ModGsPReg", []RegisterField{fieldModGsP}, 0xFF},
+	{TModeReg, "TModeReg", []RegisterField{fieldTAuto, fieldTGated, fieldTAutoRestart, fieldTPrescalerHi}, 0xFF},
+	{TPrescalerReg, "TPrescalerReg", []RegisterField{regField("TPrescaler_Lo", 0xFF)}, 0xFF},
+	{TReloadRegH, "TReloadRegH", []RegisterField{regField("TReloadVal_Hi", 0xFF)}, 0xFF},
+	{TReloadRegL, "TReloadRegL", []RegisterField{regField("TReloadVal_Lo", 0xFF)}, 0xFF},
+	{TCounterValueRegH, "TCounterValueRegH", []RegisterField{regField("TCounterVal_Hi", 0xFF)}, 0},
+	{TCounterValueRegL, "TCounterValueRegL", []RegisterField{regField("TCounterVal_Lo", 0xFF)}, 0},
+
+	// Page 3: Test
+	{TestSel1Reg, "TestSel1Reg", []RegisterField{regField("TstBusBitSel", 0x07)}, 0},
+	{TestSel2Reg, "TestSel2Reg", []RegisterField{regField("TstBusFlip", 0x80), regField("PRBS9", 0x40), regField("PRBS15", 0x20), regField("TestBusSel", 0x1F)}, 0},
+	{TestPinEnReg, "TestPinEnReg", []RegisterField{regField("RS232LineEn", 0x80), regField("TestPinEn", 0x7E)}, 0},
+	{TestPinValueReg, "TestPinValueReg", []RegisterField{regField("UseIO", 0x80), regField("TestPinValue", 0x7E)}, 0},
+	{TestBusReg, "TestBusReg", []RegisterField{regField("TestBus", 0xFF)}, 0},
+	{AutoTestReg, "AutoTestReg", []RegisterField{fieldAmpRcv, fieldSelfTest}, 0},
+	{VersionReg, "VersionReg", []RegisterField{regField("Version", 0xFF)}, 0},
+	{AnalogTestReg, "AnalogTestReg", []RegisterField{regField("AnalogSelAux1", 0xF0), regField("AnalogSelAux2", 0x0F)}, 0},
+	{TestDAC1Reg, "TestDAC1Reg", []RegisterField{regField("TestDAC1", 0x3F)}, 0},
+	{TestDAC2Reg, "TestDAC2Reg", []RegisterField{regField("TestDAC2", 0x3F)}, 0},
+	{TestADCReg, "TestADCReg", []RegisterField{regField("ADC_I", 0xF0), regField("ADC_Q", 0x0F)}, 0},
+}
+
+/**
+ * The register at address, nil for a reserved address.
+ */
+func RegisterAt(address byte) *Register {
+	for i := range Registers {
+		if Registers[i].Address == address {
+			return &Registers[i]
+		}
+	}
+	return nil
+}
+
+/**
+ * The register called name, for example "TxModeReg", nil if there is none.
+ */
+func RegisterByName(name string) *Register {
+	for i := range Registers {
+		if Registers[i].Name == name {
+			return &Registers[i]
+		}
+	}
+	return nil
+}
+
+/**
+ * The fields of value as "TxCRCEn=1 TxSpeed=2 InvMod=0".
+ */
+func (reg *Register) Format(value byte) string {
+	fields := make([]string, len(reg.Fields))
+	for i, f := range reg.Fields {
+		fields[i] = fmt.Sprintf("%s=%d", f.Name, f.Get(value))
+	}
+	return strings.Join(fields, " ")
+}
+
+// Values of all 64 registers at Time, reserved addresses included
+type RegisterSnapshot struct {
+	Time   time.Time
+	Values [64]byte // FIFODataReg is not read, the read would take a byte out of the FIFO
+}
+
+/**
+ * Reads all registers. Reading has no side effects on the chip.
+ */
+func (r *MFRC522) PCD_Snapshot() (*RegisterSnapshot, error) {
+	r.lock()
+	defer r.unlock()
+	return r.snapshot()
+}
+
+func (r *MFRC522) snapshot() (*RegisterSnapshot, error) {
+	s := &RegisterSnapshot{Time: time.Now()}
+	for address := range s.Values {
+		if address == FIFODataReg {
+			continue
+		}
+		value, err := r.readRegister(byte(address))
+		if err != nil {
+			return nil, err
+		}
+		s.Values[address] = value
+	}
+	return s, nil
+}
+
+/**
+ * Value of the field of a register by names, false if there is no such field.
+ */
+func (s *RegisterSnapshot) Field(register, field string) (byte, bool) {
+	reg := RegisterByName(register)
+	if reg == nil {
+		return 0, false
+	}
+	for _, f := range reg.Fields {
+		if f.Name == field {
+			return f.Get(s.Values[reg.Address]), true
+		}
+	}
+	return 0, false
+}
+
+/**
+ * One line per register: address, name, value and the fields.
+ */
+func (s *RegisterSnapshot) WriteText(w io.Writer) error {
+	var buff bytes.Buffer
+	fmt.Fprintf(&buff, "MFRC522 registers %s\n", s.Time.Format(time.RFC3339Nano))
+	for i := range Registers {
+		reg := &Registers[i]
+		fmt.Fprintf(&buff, "%02x %-17s %02x  %s\n", reg.Address, reg.Name, s.Values[reg.Address], reg.Format(s.Values[reg.Address]))
+	}
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+func (s *RegisterSnapshot) String() string {
+	var buff bytes.Buffer
+	s.WriteText(&buff)
+	return buff.String()
+}
+
+type FieldChange struct {
+	Name     string
+	Old, New byte
+}
+
+// A register with different values in two snapshots
+type RegisterChange struct {
+	Register *Register
+	Old, New byte
+	Fields   []FieldChange
+}
+
+func (c RegisterChange) String() string {
+	fields := make([]string, len(c.Fields))
+	for i, f := range c.Fields {
+		fields[i] = fmt.Sprintf("%s %d -> %d", f.Name, f.Old, f.New)
+	}
+	return fmt.Sprintf("%s %02x -> %02x: %s", c.Register.Name, c.Old, c.New, strings.Join(fields, ", "))
+}
+
+/**
+ * The registers that differ from s in other, in address order.
+ */
+func (s *RegisterSnapshot) Diff(other *RegisterSnapshot) []RegisterChange {
+	var changes []RegisterChange
+	for i := range Registers {
+		reg := &Registers[i]
+		before, after := s.Values[reg.Address], other.Values[reg.Address]
+		if before == after {
+			continue
+		}
+		change := RegisterChange{Register: reg, Old: before, New: after}
+		for _, f := range reg.Fields {
+			if f.Get(before) != f.Get(after) {
+				change.Fields = append(change.Fields, FieldChange{f.Name, f.Get(before), f.Get(after)})
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+/**
+ * Writes the configuration registers of the snapshot back, see Register.Restore.
+ * Commands, interrupt requests, status and test registers are not written.
+ */
+func (r *MFRC522) PCD_Restore(s *RegisterSnapshot) error {
+	r.lock()
+	defer r.unlock()
+	return r.restore(s)
+}
+
+func (r *MFRC522) restore(s *RegisterSnapshot) error {
+	for _, reg := range Registers {
+		if reg.Restore == 0 {
+			continue
+		}
+		value := s.Values[reg.Address] & reg.Restore
+		if reg.Restore != 0xFF {
+			current, err := r.readRegister(reg.Address)
+			if err != nil {
+				return err
+			}
+			value |= current &^ reg.Restore
+		}
+		if err := r.writeRegister(reg.Address, value); err != nil {
+			return err
+		}
+	}
+
+	// The cached settings follow the restored registers
+	r.timerPeriod = 0
+	txMode, rxMode := DecodeTxMode(s.Values[TxModeReg]), DecodeRxMode(s.Values[RxModeReg])
+	r.framing = RawConfig{
+		ParityDisable: DecodeMfRx(s.Values[MfRxReg]).ParityDisable,
+		TxCRC:         txMode.TxCRCEn,
+		RxCRC:         rxMode.RxCRCEn,
+	}
+	r.txRate, r.rxRate = txMode.TxSpeed, rxMode.RxSpeed
+	return nil
+}
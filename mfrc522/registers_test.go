@@ -0,0 +1,66 @@
+package mfrc522
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestRegisterField(t *testing.T) {
+	is := is.New(t)
+	reg := RegisterByName("DemodReg")
+	is.Equal(reg.Address, byte(DemodReg))
+	is.Equal(reg.Format(0x4D), "AddIQ=1 FixIQ=0 TPrescalEven=0 TauRcv=3 TauSync=1")
+	is.True(RegisterAt(0x0F) == nil) // reserved
+	tauRcv := reg.Fields[3]
+	is.Equal(tauRcv.Set(3), byte(0x0C))
+	is.Equal(tauRcv.Set(7), byte(0x0C)) // the bits beyond the field are dropped
+	is.Equal(tauRcv.Get(tauRcv.Set(2)), byte(2))
+
+	// The fields of a register do not overlap
+	for _, reg := range Registers {
+		var used byte
+		for _, f := range reg.Fields {
+			is.Equal(used&f.Mask, byte(0))
+			used |= f.Mask
+		}
+	}
+}
+
+func TestSnapshotDiffRestore(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t)
+
+	before, err := reader.PCD_Snapshot()
+	is.NoErr(err)
+	version, ok := before.Field("VersionReg", "Version")
+	is.True(ok)
+	is.Equal(version, byte(VER_2_0))
+	is.True(strings.Contains(before.String(), "RFCfgReg          48  RxGain=4"))
+
+	// An experiment with the RF profile and the bit rate
+	is.NoErr(reader.PCD_SetRFConfig(RFConfig{RxGain: RX_GAIN_48DB, CWGsN: 8, ModGsN: 8, CWGsP: 0x3F, ModGsP: 0x20,
+		MinLevel: 8, CollLevel: 4, AddIQ: 1, TauRcv: 3, TauSync: 1}))
+	is.NoErr(reader.PCD_SetBitRate(BIT_RATE_424, BIT_RATE_424))
+	after, err := reader.PCD_Snapshot()
+	is.NoErr(err)
+
+	var changed []string
+	for _, change := range before.Diff(after) {
+		changed = append(changed, change.Register.Name)
+	}
+	is.Equal(strings.Join(changed, " "), "TxModeReg RxModeReg ModWidthReg RFCfgReg CWGsPReg")
+	is.Equal(before.Diff(after)[0].String(), "TxModeReg 00 -> 20: TxSpeed 0 -> 2")
+
+	is.NoErr(reader.PCD_Restore(before))
+	restored, err := reader.PCD_Snapshot()
+	is.NoErr(err)
+	is.Equal(len(before.Diff(restored)), 0)
+	tx, rx := reader.PCD_BitRate()
+	is.Equal(tx, BIT_RATE_106)
+	is.Equal(rx, BIT_RATE_106)
+
+	// The reader works with the restored registers
+	is.True(!reader.PICC_IsNewCardPresent())
+}
@@ -0,0 +1,340 @@
+// RF front end: receiver gain, driver conductances, bit decoder and demodulator.
+// Datasheet 9.3.2.8, 9.3.2.9 and 9.3.3.6 - 9.3.3.9.
+
+package mfrc522
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RxGain[2:0] of RFCfgReg, not shifted
+type RxGain byte
+
+const (
+	RX_GAIN_18DB RxGain = 0x00
+	RX_GAIN_23DB RxGain = 0x01
+	RX_GAIN_33DB RxGain = 0x04
+	RX_GAIN_38DB RxGain = 0x05
+	RX_GAIN_43DB RxGain = 0x06
+	RX_GAIN_48DB RxGain = 0x07
+)
+
+// 010 and 011 are duplicates of 18 dB and 23 dB
+var rxGainDB = [...]int{18, 23, 18, 23, 33, 38, 43, 48}
+
+/**
+ * Gain in dB.
+ */
+func (g RxGain) DB() int {
+	return rxGainDB[g&0x07]
+}
+
+func (g RxGain) String() string {
+	return fmt.Sprintf("%d dB", g.DB())
+}
+
+/**
+ * The RxGain of db, one of 18, 23, 33, 38, 43 and 48.
+ */
+func RxGainFromDB(db int) (RxGain, error) {
+	for _, g := range []RxGain{RX_GAIN_18DB, RX_GAIN_23DB, RX_GAIN_33DB, RX_GAIN_38DB, RX_GAIN_43DB, RX_GAIN_48DB} {
+		if g.DB() == db {
+			return g, nil
+		}
+	}
+	return 0, UsageError(fmt.Sprintf("Unexpected receiver gain: %d dB", db))
+}
+
+// Settings of the RF front end. The reset values are in DefaultRFConfig.
+type RFConfig struct {
+	RxGain RxGain // RFCfgReg RxGain
+
+	CWGsN  byte // GsNReg CWGsN, n-driver conductance without modulation, 0..15
+	ModGsN byte // GsNReg ModGsN, n-driver conductance during modulation, 0..15
+	CWGsP  byte // CWGsPReg, p-driver conductance without modulation, 0..63
+	ModGsP byte // ModGsPReg, p-driver conductance during modulation, 0..63
+
+	MinLevel  byte // RxThresholdReg MinLevel, minimum signal strength of the decoder, 0..15
+	CollLevel byte // RxThresholdReg CollLevel, minimum strength of a collision, 0..7
+
+	AddIQ   byte // DemodReg AddIQ, use of the I and Q channel, 0..3
+	FixIQ   bool // DemodReg FixIQ, only the channel of AddIQ
+	TauRcv  byte // DemodReg TauRcv, PLL time constant during data reception, 0..3
+	TauSync byte // DemodReg TauSync, PLL time constant during burst, 0..3
+}
+
+var DefaultRFConfig = RFConfig{
+	RxGain:    RX_GAIN_33DB,
+	CWGsN:     0x08,
+	ModGsN:    0x08,
+	CWGsP:     0x20,
+	ModGsP:    0x20,
+	MinLevel:  0x08,
+	CollLevel: 0x04,
+	AddIQ:     0x01,
+	TauRcv:    0x03,
+	TauSync:   0x01,
+}
+
+func (c RFConfig) String() string {
+	return fmt.Sprintf("RxGain %s CWGsN %d ModGsN %d CWGsP %d ModGsP %d MinLevel %d CollLevel %d AddIQ %d FixIQ %t TauRcv %d TauSync %d",
+		c.RxGain, c.CWGsN, c.ModGsN, c.CWGsP, c.ModGsP, c.MinLevel, c.CollLevel, c.AddIQ, c.FixIQ, c.TauRcv, c.TauSync)
+}
+
+func (c RFConfig) validate() error {
+	for _, field := range []struct {
+		name       string
+		value, max byte
+	}{
+		{"RxGain", byte(c.RxGain), 0x07},
+		{"CWGsN", c.CWGsN, 0x0F},
+		{"ModGsN", c.ModGsN, 0x0F},
+		{"CWGsP", c.CWGsP, 0x3F},
+		{"ModGsP", c.ModGsP, 0x3F},
+		{"MinLevel", c.MinLevel, 0x0F},
+		{"CollLevel", c.CollLevel, 0x07},
+		{"AddIQ", c.AddIQ, 0x03},
+		{"TauRcv", c.TauRcv, 0x03},
+		{"TauSync", c.TauSync, 0x03},
+	} {
+		if field.value > field.max {
+			return UsageError(fmt.Sprintf("%s out of range [0, %d]: %d", field.name, field.max, field.value))
+		}
+	}
+	return nil
+}
+
+/**
+ * Reads the RF front end settings.
+ */
+func (r *MFRC522) PCD_GetRFConfig() (RFConfig, error) {
+	r.lock()
+	defer r.unlock()
+	return r.getRFConfig()
+}
+
+func (r *MFRC522) getRFConfig() (c RFConfig, err error) {
+	var regs [6]byte
+	for i, address := range []byte{RFCfgReg, GsNReg, CWGsPReg, ModGsPReg, RxThresholdReg, DemodReg} {
+		if regs[i], err = r.readRegister(address); err != nil {
+			return
+		}
+	}
+	gsN, threshold, demod := DecodeGsN(regs[1]), DecodeRxThreshold(regs[4]), DecodeDemod(regs[5])
+	c.RxGain = DecodeRFCfg(regs[0]).RxGain
+	c.CWGsN, c.ModGsN = gsN.CWGsN, gsN.ModGsN
+	c.CWGsP, c.ModGsP = DecodeCWGsP(regs[2]).CWGsP, DecodeModGsP(regs[3]).ModGsP
+	c.MinLevel, c.CollLevel = threshold.MinLevel, threshold.CollLevel
+	c.AddIQ, c.FixIQ = demod.AddIQ, demod.FixIQ
+	c.TauRcv, c.TauSync = demod.TauRcv, demod.TauSync
+	return
+}
+
+/**
+ * Writes the RF front end settings, TPrescalEven is kept.
+ * The reserved bits are written with their reset values.
+ */
+func (r *MFRC522) PCD_SetRFConfig(c RFConfig) error {
+	r.lock()
+	defer r.unlock()
+	return r.setRFConfig(c)
+}
+
+func (r *MFRC522) setRFConfig(c RFConfig) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	demod, err := r.readRegister(DemodReg)
+	if err != nil {
+		return err
+	}
+	for _, value := range []RegisterValue{
+		RFCfg{RxGain: c.RxGain},
+		GsN{CWGsN: c.CWGsN, ModGsN: c.ModGsN},
+		CWGsP{CWGsP: c.CWGsP},
+		ModGsP{ModGsP: c.ModGsP},
+		RxThreshold{MinLevel: c.MinLevel, CollLevel: c.CollLevel},
+		Demod{AddIQ: c.AddIQ, FixIQ: c.FixIQ, TPrescalEven: DecodeDemod(demod).TPrescalEven, TauRcv: c.TauRcv, TauSync: c.TauSync},
+	} {
+		if err := r.writeReg(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// Antenna calibration
+/////////////////////////////////////////////////////////////////////////////////////
+
+// Values tried by Calibrate, every combination is a configuration.
+// An empty list keeps the value of the base configuration.
+type CalibrationSweep struct {
+	Base     RFConfig // DefaultRFConfig if zero
+	RxGains  []RxGain
+	CWGsP    []byte
+	ModGsP   []byte
+	CWGsN    []byte
+	MinLevel []byte
+	Attempts int // reads of the reference card per configuration, 10 by default
+
+	// Read of the reference card after select, nil only selects the card.
+	// For example a Session.Read of a block the card allows to read.
+	// The reader is locked, use only the Session methods.
+	Probe func(ctx context.Context, session *Session) error
+}
+
+/**
+ * All combinations of the sweep, RxGain varies the slowest.
+ */
+func (s CalibrationSweep) Configs() []RFConfig {
+	base := s.Base
+	if base == (RFConfig{}) {
+		base = DefaultRFConfig
+	}
+	configs := []RFConfig{base}
+	vary := func(values []byte, set func(c *RFConfig, v byte)) {
+		if len(values) == 0 {
+			return
+		}
+		var next []RFConfig
+		for _, c := range configs {
+			for _, v := range values {
+				set(&c, v)
+				next = append(next, c)
+			}
+		}
+		configs = next
+	}
+	gains := make([]byte, len(s.RxGains))
+	for i, g := range s.RxGains {
+		gains[i] = byte(g)
+	}
+	vary(gains, func(c *RFConfig, v byte) { c.RxGain = RxGain(v) })
+	vary(s.CWGsP, func(c *RFConfig, v byte) { c.CWGsP = v })
+	vary(s.ModGsP, func(c *RFConfig, v byte) { c.ModGsP = v })
+	vary(s.CWGsN, func(c *RFConfig, v byte) { c.CWGsN = v })
+	vary(s.MinLevel, func(c *RFConfig, v byte) { c.MinLevel = v })
+	return configs
+}
+
+// Reads of the reference card with one configuration
+type CalibrationResult struct {
+	Config    RFConfig
+	Attempts  int
+	Successes int
+}
+
+func (c CalibrationResult) SuccessRate() float64 {
+	if c.Attempts == 0 {
+		return 0
+	}
+	return float64(c.Successes) / float64(c.Attempts)
+}
+
+type CalibrationReport struct {
+	Results     []CalibrationResult // in the order of CalibrationSweep.Configs
+	Recommended RFConfig
+	SuccessRate float64 // of Recommended
+}
+
+/**
+ * Sweeps the RF settings with a reference card on the antenna. Each attempt
+ * resets the field, wakes up and selects the card and runs Probe.
+ * The recommended profile is in the middle of the configurations with the best
+ * success rate, the neighbours leave some margin. The RF settings of the chip
+ * are restored, use PCD_SetRFConfig or WithRFConfig to apply the profile.
+ */
+func (r *MFRC522) Calibrate(ctx context.Context, sweep CalibrationSweep) (*CalibrationReport, error) {
+	if err := r.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+
+	configs := sweep.Configs()
+	for _, c := range configs {
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+	}
+	attempts := sweep.Attempts
+	if attempts <= 0 {
+		attempts = 10
+	}
+
+	saved, err := r.getRFConfig()
+	if err != nil {
+		return nil, err
+	}
+	defer r.setRFConfig(saved)
+
+	report := &CalibrationReport{}
+	for _, c := range configs {
+		if err := r.setRFConfig(c); err != nil {
+			return nil, err
+		}
+		result := CalibrationResult{Config: c, Attempts: attempts}
+		for i := 0; i < attempts; i++ {
+			ok, err := r.calibrationAttempt(ctx, sweep.Probe)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				result.Successes++
+			}
+		}
+		r.logger.Log(LOG_DEBUG, "calibration", "config", c, "successes", result.Successes, "attempts", attempts)
+		report.Results = append(report.Results, result)
+	}
+
+	var best []CalibrationResult
+	for _, result := range report.Results {
+		switch {
+		case len(best) == 0 || result.Successes > best[0].Successes:
+			best = []CalibrationResult{result}
+		case result.Successes == best[0].Successes:
+			best = append(best, result)
+		}
+	}
+	if len(best) > 0 {
+		report.Recommended = best[len(best)/2].Config
+		report.SuccessRate = best[len(best)/2].SuccessRate()
+	}
+	return report, nil
+}
+
+/**
+ * One read of the reference card, false if the card fails.
+ * Only errors of the chip and ctx stop the calibration.
+ */
+func (r *MFRC522) calibrationAttempt(ctx context.Context, probe func(ctx context.Context, session *Session) error) (bool, error) {
+	if err := r.antennaOff(); err != nil {
+		return false, err
+	}
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-time.After(FIELD_RESET_TIME):
+	}
+	if err := r.antennaOn(); err != nil {
+		return false, err
+	}
+	if _, err := r.requestWUPA(ctx); err != nil {
+		return false, ctx.Err()
+	}
+	uid, err := r.selectPICC(ctx)
+	if err != nil {
+		return false, ctx.Err()
+	}
+	session := &Session{UID: uid, reader: r}
+	if probe != nil {
+		err = probe(ctx, session)
+	}
+	r.haltA(ctx)
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	return err == nil, nil
+}
@@ -0,0 +1,109 @@
+package mfrc522
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestRxGain(t *testing.T) {
+	is := is.New(t)
+	is.Equal(RX_GAIN_33DB.DB(), 33)
+	is.Equal(RxGain(0x02).DB(), 18)
+	g, err := RxGainFromDB(43)
+	is.NoErr(err)
+	is.Equal(g, RX_GAIN_43DB)
+	_, err = RxGainFromDB(40)
+	is.True(err != nil)
+}
+
+func TestRFConfig(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t)
+
+	config, err := reader.PCD_GetRFConfig()
+	is.NoErr(err)
+	is.Equal(config, DefaultRFConfig)
+	gain, err := reader.PCD_GetAntennaGain()
+	is.NoErr(err)
+	is.Equal(RxGain(gain>>4), RX_GAIN_33DB)
+
+	config = RFConfig{RxGain: RX_GAIN_48DB, CWGsN: 0x0F, ModGsN: 0x04, CWGsP: 0x3F, ModGsP: 0x11,
+		MinLevel: 0x05, CollLevel: 0x02, AddIQ: 0x02, FixIQ: true, TauRcv: 0x01, TauSync: 0x02}
+	is.NoErr(reader.PCD_SetRFConfig(config))
+	read, err := reader.PCD_GetRFConfig()
+	is.NoErr(err)
+	is.Equal(read, config)
+	demod, err := reader.readRegister(DemodReg)
+	is.NoErr(err)
+	is.Equal(demod, byte(0xA6))
+
+	config.CWGsP = 0x40
+	is.True(reader.PCD_SetRFConfig(config) != nil)
+}
+
+// Answers only if the receiver gain and the carrier are strong enough
+type weakAntennaField struct {
+	*VirtualField
+	sim *MFRC522Simulator
+}
+
+func (f *weakAntennaField) Transceive(frame Frame) (Frame, int, bool) {
+	// The simulator holds its lock during Transceive
+	if DecodeRFCfg(f.sim.regs[RFCfgReg]).RxGain.DB() < 43 || DecodeCWGsP(f.sim.regs[CWGsPReg]).CWGsP < 0x20 {
+		return Frame{}, -1, false
+	}
+	return f.VirtualField.Transceive(frame)
+}
+
+func TestCalibrate(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	sim.Field = &weakAntennaField{VirtualField: NewVirtualField(NewVirtualMifareClassic1K([]byte{0x9c, 0x59, 0x9b, 0x32})), sim: sim}
+	reader := newSimulatedMFRC522(t, sim)
+	is.NoErr(reader.PCD_Init())
+	is.NoErr(reader.PCD_AntennaOn())
+	ctx := context.Background()
+
+	probes := 0
+	report, err := reader.Calibrate(ctx, CalibrationSweep{
+		RxGains:  []RxGain{RX_GAIN_33DB, RX_GAIN_38DB, RX_GAIN_43DB, RX_GAIN_48DB},
+		CWGsP:    []byte{0x10, 0x20, 0x30},
+		Attempts: 2,
+		Probe: func(ctx context.Context, session *Session) error {
+			probes++
+			if err := session.AuthentificateKeyA(ctx, defaultKey, 0); err != nil {
+				return err
+			}
+			_, err := session.Read(ctx, 1)
+			return err
+		},
+	})
+	is.NoErr(err)
+	is.Equal(len(report.Results), 12)
+	is.Equal(probes, 8)
+	is.Equal(report.Results[0].Config.RxGain, RX_GAIN_33DB)
+	is.Equal(report.Results[1].Config.CWGsP, byte(0x20))
+	for _, result := range report.Results {
+		if result.Config.RxGain.DB() >= 43 && result.Config.CWGsP >= 0x20 {
+			is.Equal(result.SuccessRate(), 1.0)
+		} else {
+			is.Equal(result.Successes, 0)
+		}
+	}
+	is.Equal(report.Recommended.RxGain, RX_GAIN_48DB)
+	is.Equal(report.Recommended.CWGsP, byte(0x20))
+	is.Equal(report.SuccessRate, 1.0)
+
+	// The settings of the chip are restored
+	config, err := reader.PCD_GetRFConfig()
+	is.NoErr(err)
+	is.Equal(config, DefaultRFConfig)
+
+	_, err = reader.PICC_RequestWUPA()
+	is.True(err != nil)
+	is.NoErr(reader.PCD_SetRFConfig(report.Recommended))
+	_, err = reader.PICC_RequestWUPA()
+	is.NoErr(err)
+}
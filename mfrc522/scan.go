@@ -0,0 +1,189 @@
+// Strategies to wait for a card.
+
+package mfrc522
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+/**
+ * ScanStrategy waits until a card answers REQA. On success the antenna is on
+ * and the card is READY, PICC_Select follows.
+ */
+type ScanStrategy interface {
+	WaitForCard(ctx context.Context, r *MFRC522) error
+}
+
+/**
+ * Antenna always on, REQA every Interval.
+ */
+type ContinuousScan struct {
+	Interval time.Duration
+}
+
+func (s ContinuousScan) WaitForCard(ctx context.Context, r *MFRC522) error {
+	if err := r.PCD_AntennaOn(); err != nil {
+		return err
+	}
+	for {
+		if r.PICC_IsNewCardPresentContext(ctx) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.Interval):
+		}
+	}
+}
+
+const (
+	LOW_POWER_PROBE_TIMEOUT = time.Millisecond // REQA timer, ATQA comes after ~100µs
+	LOW_POWER_WAKEUP        = 50 * time.Millisecond
+	LOW_POWER_SETTLE        = 5 * time.Millisecond // oscillator and field after the wake up, ISO/IEC 14443-3 gives the PICC 5ms
+)
+
+/**
+ * Duty cycled scan: the chip sleeps in soft power-down, wakes up every
+ * Sleep, lets the field settle, sends one REQA with a ProbeTimeout timer
+ * and goes back to sleep. A failure of the reader ends the scan.
+ */
+type LowPowerScan struct {
+	Sleep        time.Duration
+	ProbeTimeout time.Duration
+}
+
+/**
+ * Low power scan with the antenna on for dutyCycle of period. Every wake up
+ * has the field on for LOW_POWER_SETTLE and at least LOW_POWER_PROBE_TIMEOUT,
+ * a smaller duty cycle is raised to that.
+ */
+func NewLowPowerScan(period time.Duration, dutyCycle float64) (LowPowerScan, error) {
+	if dutyCycle <= 0 || dutyCycle > 1 {
+		return LowPowerScan{}, UsageError(fmt.Sprintf("Duty cycle %v out of range (0, 1]", dutyCycle))
+	}
+	if period <= LOW_POWER_SETTLE+LOW_POWER_PROBE_TIMEOUT {
+		return LowPowerScan{}, UsageError(fmt.Sprintf("Period %s must be longer than %s", period, LOW_POWER_SETTLE+LOW_POWER_PROBE_TIMEOUT))
+	}
+	probe := time.Duration(float64(period)*dutyCycle) - LOW_POWER_SETTLE
+	if probe < LOW_POWER_PROBE_TIMEOUT {
+		probe = LOW_POWER_PROBE_TIMEOUT
+	}
+	return LowPowerScan{Sleep: period - LOW_POWER_SETTLE - probe, ProbeTimeout: probe}, nil
+}
+
+/**
+ * Fraction of the time the antenna is on: LOW_POWER_SETTLE and ProbeTimeout of every period.
+ */
+func (s LowPowerScan) DutyCycle() float64 {
+	on := LOW_POWER_SETTLE + s.ProbeTimeout
+	return float64(on) / float64(s.Sleep+on)
+}
+
+/**
+ * On cancellation the chip stays in soft power-down, PCD_SoftPowerUp wakes it up.
+ */
+func (s LowPowerScan) WaitForCard(ctx context.Context, r *MFRC522) error {
+	for {
+		found, err := s.probe(ctx, r)
+		if err != nil || found {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.Sleep):
+		}
+	}
+}
+
+func (s LowPowerScan) probe(ctx context.Context, r *MFRC522) (bool, error) {
+	if err := r.lockContext(ctx); err != nil {
+		return false, err
+	}
+	defer r.unlock()
+
+	if err := r.softPowerUp(ctx); err != nil {
+		return false, err
+	}
+	if err := r.antennaOn(); err != nil {
+		return false, err
+	}
+	// TxControlReg keeps Tx1RFEn and Tx2RFEn in soft power-down, so antennaOn
+	// doesn't wait: the field starts with the oscillator.
+	select {
+	case <-ctx.Done():
+		r.softPowerDown()
+		return false, ctx.Err()
+	case <-time.After(LOW_POWER_SETTLE):
+	}
+
+	// The timer of the REQA is set to ProbeTimeout
+	validBits := byte(7)
+	atqa, err := r.communicateWithPICC(ctx, PCD_Transceive, []byte{PICC_CMD_REQA}, &validBits, s.ProbeTimeout)
+	if ctx.Err() != nil {
+		r.softPowerDown()
+		return false, ctx.Err()
+	}
+	if err == nil && len(atqa) == 2 {
+		return true, nil
+	}
+	if err != nil && !isCardError(err) {
+		r.softPowerDown()
+		return false, err
+	}
+	r.logger.Log(LOG_TRACE, "low power probe", "err", err)
+	return false, r.softPowerDown()
+}
+
+/**
+ * Enters soft power-down (datasheet 8.6.2): the oscillator and the RF field
+ * are off, the register values are kept.
+ */
+func (r *MFRC522) PCD_SoftPowerDown() error {
+	r.lock()
+	defer r.unlock()
+	return r.softPowerDown()
+}
+
+func (r *MFRC522) softPowerDown() error {
+	return r.writeReg(Command{PowerDown: true, Command: PCD_Idle})
+}
+
+/**
+ * Leaves soft power-down and waits for the oscillator: the PowerDown bit
+ * stays set until the chip is ready.
+ */
+func (r *MFRC522) PCD_SoftPowerUp(ctx context.Context) error {
+	if err := r.lockContext(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+	return r.softPowerUp(ctx)
+}
+
+func (r *MFRC522) softPowerUp(ctx context.Context) error {
+	val, err := r.readRegister(CommandReg)
+	if err != nil || !DecodeCommand(val).PowerDown {
+		return err
+	}
+	if err := r.clearBits(Command{PowerDown: true}); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(LOW_POWER_WAKEUP)
+	for {
+		if val, err = r.readRegister(CommandReg); err != nil || !DecodeCommand(val).PowerDown {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return &CommunicationError{Command: DecodeCommand(val).Command, Err: ErrTimeout} // PowerDown bit not cleared
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(IRQ_POLL_INTERVAL):
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package mfrc522
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestLowPowerScan(t *testing.T) {
+	is := is.New(t)
+	card := NewVirtualMifareClassic1K([]byte{0x11, 0x22, 0x33, 0x44})
+	sim := NewMFRC522Simulator()
+	field := NewVirtualField()
+	probes := 0
+	sim.Field = SimFieldFunc(func(frame Frame) (Frame, int, bool) {
+		probes++
+		return field.Transceive(frame)
+	})
+	reader := newSimulatedMFRC522(t, sim)
+	is.NoErr(reader.PCD_Init())
+
+	scan, err := NewLowPowerScan(20*time.Millisecond, 0.5)
+	is.NoErr(err)
+	is.Equal(scan.ProbeTimeout, 5*time.Millisecond)
+	is.Equal(scan.Sleep, 10*time.Millisecond)
+	is.Equal(scan.DutyCycle(), 0.5)
+
+	// The settle time is on too
+	short, err := NewLowPowerScan(10*time.Millisecond, 0.1)
+	is.NoErr(err)
+	is.Equal(short.ProbeTimeout, LOW_POWER_PROBE_TIMEOUT)
+	is.Equal(short.DutyCycle(), 0.6)
+	for _, test := range []struct {
+		period    time.Duration
+		dutyCycle float64
+	}{{6 * time.Millisecond, 0.5}, {time.Second, 0}, {time.Second, 1.5}} {
+		_, err = NewLowPowerScan(test.period, test.dutyCycle)
+		is.True(errors.Is(err, ErrUsage))
+	}
+
+	// No card: the chip sleeps between the probes
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Millisecond)
+	defer cancel()
+	is.Equal(scan.WaitForCard(ctx, reader), context.DeadlineExceeded)
+	is.True(probes >= 2 && probes <= 7)
+	command, err := reader.PCD_ReadRegister(CommandReg)
+	is.NoErr(err)
+	is.Equal(command&0x10, byte(0x10)) // PowerDown
+
+	// The field settles after every wake up
+	start := time.Now()
+	found, err := scan.probe(context.Background(), reader)
+	is.NoErr(err)
+	is.True(!found)
+	is.True(time.Since(start) >= LOW_POWER_SETTLE)
+
+	reader.lock()
+	field.Add(card)
+	reader.unlock()
+	is.NoErr(scan.WaitForCard(context.Background(), reader))
+	_, err = reader.PICC_Select()
+	is.NoErr(err)
+}
+
+func TestLowPowerScanReaderFailure(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	sim.Field = NewVirtualField()
+	spi, err := NewSPITransport(sim)
+	is.NoErr(err)
+	transport := &breakingTransport{Transport: spi}
+	reader, err := NewMFRC522(transport, sim.ResetPin(), sim.IRQPin())
+	is.NoErr(err)
+	is.NoErr(reader.PCD_Init())
+	transport.broken = true
+
+	scan, err := NewLowPowerScan(20*time.Millisecond, 0.5)
+	is.NoErr(err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = scan.WaitForCard(ctx, reader)
+	is.True(err != nil && err != context.DeadlineExceeded) // the scan doesn't loop on a dead bus
+}
@@ -0,0 +1,185 @@
+// Serialization of the chip access and exclusive card sessions.
+
+package mfrc522
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/**
+ * Every exported method holds the lock for the whole operation, the
+ * unexported implementations expect the lock to be held.
+ */
+func (r *MFRC522) lock() {
+	r.sem <- struct{}{}
+}
+
+/**
+ * lock that gives up with ctx.Err() when ctx is done first.
+ */
+func (r *MFRC522) lockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case r.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *MFRC522) unlock() {
+	<-r.sem
+}
+
+/**
+ * Instructs an ACTIVE PICC to go to state HALT.
+ * The PICC doesn't answer HLTA, a timeout means success.
+ */
+func (r *MFRC522) PICC_HaltA() error {
+	return r.PICC_HaltAContext(context.Background())
+}
+
+/**
+ * PICC_HaltA with a context, returns ctx.Err() if ctx is done.
+ */
+func (r *MFRC522) PICC_HaltAContext(ctx context.Context) error {
+	if err := r.lockContext(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+	return r.haltA(ctx)
+}
+
+func (r *MFRC522) haltA(ctx context.Context) error {
+	// An authenticated PICC expects an encrypted HLTA
+	result, err := r.transceivePICC(ctx, []byte{PICC_CMD_HLTA, 0x00}, false, r.timeouts.Anticollision)
+	r.crypto1 = nil
+	if errors.Is(err, ErrTimeout) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return UnexpectedResponse(fmt.Sprintf("HLTA answered: [% x]", result.Data))
+}
+
+/**
+ * Exclusive use of the reader and of the selected card. Other callers
+ * block until Release. A Session is used by one goroutine.
+ *
+ * The lock is not reentrant: the exported methods of the MFRC522 block
+ * until Release, also in the goroutine holding the session, which then
+ * deadlocks. Use only the Session methods while the session is held,
+ * they run the unlocked implementations.
+ */
+type Session struct {
+	UID    *UID
+	ATS    *ATS // set by ActivateISO14443_4
+	reader *MFRC522
+}
+
+/**
+ * Waits for the reader, wakes up (WUPA) and selects a card.
+ * The card is halted by Release, so the next session wakes it up again.
+ * Don't use the reader directly before Release, see Session.
+ */
+func (r *MFRC522) AcquireSession(ctx context.Context) (*Session, error) {
+	if err := r.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := r.requestWUPA(ctx); err != nil {
+		r.unlock()
+		return nil, err
+	}
+	uid, err := r.selectPICC(ctx)
+	if err != nil {
+		r.unlock()
+		return nil, err
+	}
+	return &Session{UID: uid, reader: r}, nil
+}
+
+/**
+ * Halts the card and releases the reader. Calling Release more than once is a no-op.
+ * An ISO/IEC 14443-4 card is deselected.
+ */
+func (s *Session) Release() error {
+	if s.reader == nil {
+		return nil
+	}
+	r := s.reader
+	s.reader = nil
+	defer r.unlock()
+	var err error
+	if s.ATS != nil {
+		err = r.deselect(context.Background(), s.ATS)
+	} else {
+		err = r.haltA(context.Background())
+	}
+	if rateErr := r.setBitRate(BIT_RATE_106, BIT_RATE_106); err == nil {
+		err = rateErr
+	}
+	return err
+}
+
+func (s *Session) active() (*MFRC522, error) {
+	if s.reader == nil {
+		return nil, UsageError("Session is released")
+	}
+	return s.reader, nil
+}
+
+/**
+ * MIFARE Classic authentication of the selected card with key A.
+ */
+func (s *Session) AuthentificateKeyA(ctx context.Context, key []byte, sector byte) error {
+	r, err := s.active()
+	if err != nil {
+		return err
+	}
+	return r.authentificateKeyA(ctx, *s.UID, key, sector)
+}
+
+func (s *Session) Read(ctx context.Context, blockAddr byte) ([]byte, error) {
+	r, err := s.active()
+	if err != nil {
+		return nil, err
+	}
+	return r.mifareRead(ctx, blockAddr)
+}
+
+func (s *Session) Write(ctx context.Context, blockAddr byte, data []byte) error {
+	r, err := s.active()
+	if err != nil {
+		return err
+	}
+	return r.mifareWrite(ctx, blockAddr, data)
+}
+
+/**
+ * Sends data as it is and returns the answer of the card.
+ */
+func (s *Session) Transceive(ctx context.Context, data []byte) ([]byte, error) {
+	r, err := s.active()
+	if err != nil {
+		return nil, err
+	}
+	return s.TransceiveTimeout(ctx, data, r.timeouts.Default)
+}
+
+/**
+ * Transceive with the timer set to timeout, for example FrameWaitingTime of an ISO 14443-4 PICC.
+ */
+func (s *Session) TransceiveTimeout(ctx context.Context, data []byte, timeout time.Duration) ([]byte, error) {
+	r, err := s.active()
+	if err != nil {
+		return nil, err
+	}
+	validBits := byte(0)
+	return r.communicateWithPICC(ctx, PCD_Transceive, data, &validBits, timeout)
+}
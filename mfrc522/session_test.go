@@ -0,0 +1,85 @@
+package mfrc522
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestSessionExclusive(t *testing.T) {
+	is := is.New(t)
+	uid := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	reader, _ := newVirtualReader(t, NewVirtualNTAG213(uid))
+
+	session, err := reader.AcquireSession(context.Background())
+	is.NoErr(err)
+	is.True(bytes.Compare(session.UID.Uid, uid) == 0)
+
+	// The reader is busy
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = reader.PICC_RequestAContext(ctx)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+
+	data, err := session.Read(context.Background(), 0)
+	is.NoErr(err)
+	is.True(bytes.Compare(data[:3], uid[:3]) == 0)
+
+	is.NoErr(session.Release())
+	is.NoErr(session.Release())
+	_, err = session.Read(context.Background(), 0)
+	is.True(errors.Is(err, ErrUsage))
+
+	// The card is halted, REQA is ignored
+	is.True(!reader.PICC_IsNewCardPresent())
+}
+
+func TestSessionConcurrent(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t, NewVirtualNTAG213([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 8; i++ {
+		page := byte(4 + i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			session, err := reader.AcquireSession(context.Background())
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer session.Release()
+			data := []byte{page, page, page, page, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+			if err := session.Write(context.Background(), page, data); err != nil {
+				errs <- err
+				return
+			}
+			read, err := session.Read(context.Background(), page)
+			if err == nil && bytes.Compare(read[:4], data[:4]) != 0 {
+				err = UnexpectedResponse("read back")
+			}
+			if err != nil {
+				errs <- err
+			}
+		}()
+		// Register access in between
+		go func() {
+			defer wg.Done()
+			if _, err := reader.PCD_GetAntennaGain(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		is.NoErr(err)
+	}
+}
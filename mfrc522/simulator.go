@@ -0,0 +1,633 @@
+// Register level MFRC522 simulator.
+// Lets the driver run without a Raspberry Pi: MFRC522Simulator implements
+// spi.Port and spi.Conn and decodes the SPI frames described in datasheet 8.1.2.
+
+package mfrc522
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+)
+
+const (
+	SIM_FIFO_SIZE       = 64
+	SIM_INTERNAL_BUFFER = 25
+	SIM_CLOCK           = 13560000 // Hz
+)
+
+// Register values after reset (datasheet 9.2)
+var simResetValues = map[byte]byte{
+	CommandReg:     0x20,
+	ComIEnReg:      0x80,
+	ComIrqReg:      0x14,
+	Status1Reg:     0x21,
+	WaterLevelReg:  0x08,
+	ControlReg:     0x10,
+	CollReg:        0xA0,
+	ModeReg:        0x3F,
+	TxControlReg:   0x80,
+	TxSelReg:       0x10,
+	RxSelReg:       0x84,
+	RxThresholdReg: 0x84,
+	DemodReg:       0x4D,
+	MfTxReg:        0x62,
+	SerialSpeedReg: 0xEB,
+	CRCResultRegH:  0xFF,
+	CRCResultRegL:  0xFF,
+	ModWidthReg:    0x26,
+	RFCfgReg:       0x48,
+	GsNReg:         0x88,
+	CWGsPReg:       0x20,
+	ModGsPReg:      0x20,
+	TestPinEnReg:   0x80,
+	AutoTestReg:    0x40,
+}
+
+// SimField is the RF field of MFRC522Simulator.
+// Transceive gets the frame transmitted by the simulated chip and returns the PICC response.
+// collision is the index of the first bit where PICC responses collide, -1 if there is none.
+// ok is false if no PICC answers.
+type SimField interface {
+	Transceive(frame Frame) (resp Frame, collision int, ok bool)
+}
+
+// Optionally implemented by SimField, called when the antenna drivers are switched off
+type simFieldOff interface {
+	FieldOff()
+}
+
+// Optionally implemented by SimField, gets TxSpeed and RxSpeed before each frame
+type simFieldBitRate interface {
+	SetBitRate(tx, rx BitRate)
+}
+
+// SimFieldFunc adapts a function to SimField
+type SimFieldFunc func(frame Frame) (Frame, int, bool)
+
+func (f SimFieldFunc) Transceive(frame Frame) (Frame, int, bool) {
+	return f(frame)
+}
+
+// MFRC522Simulator models registers, the 64 byte FIFO, the command set,
+// the interrupt request registers and the timer of the MFRC522.
+type MFRC522Simulator struct {
+	Version byte             // VersionReg value, VER_2_0 by default
+	Field   SimField         // PICCs in the RF field, nil if there are none
+	Now     func() time.Time // clock used by the timer, time.Now by default
+
+	mu         sync.Mutex
+	regs       [64]byte
+	fifo       []byte
+	internal   [SIM_INTERNAL_BUFFER]byte
+	crc        uint16
+	timerStart time.Time
+	timerOn    bool
+	resetLevel gpio.Level
+}
+
+func NewMFRC522Simulator() *MFRC522Simulator {
+	s := &MFRC522Simulator{Version: VER_2_0, Now: time.Now, resetLevel: gpio.High}
+	s.reset()
+	return s
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// spi.Port and spi.Conn
+/////////////////////////////////////////////////////////////////////////////////////
+
+func (s *MFRC522Simulator) String() string {
+	return "MFRC522Simulator"
+}
+
+func (s *MFRC522Simulator) Connect(f physic.Frequency, mode spi.Mode, bits int) (spi.Conn, error) {
+	if bits != 8 {
+		return nil, UsageError(fmt.Sprintf("Unsupported bits per word: %d", bits))
+	}
+	if mode&spi.Mode3 != spi.Mode0 {
+		return nil, UsageError(fmt.Sprintf("Unsupported SPI mode: %s", mode))
+	}
+	return s, nil
+}
+
+func (s *MFRC522Simulator) Duplex() conn.Duplex {
+	return conn.Full
+}
+
+/**
+ * One SPI transaction (CS low .. CS high).
+ * Read:  MOSI addr0 addr1 .. addrN 00, MISO X data0 .. dataN
+ * Write: MOSI addr data0 .. dataN, all data goes to the same register
+ * The address byte is 1 (read) / 0 (write), 6 address bits and a zero LSB.
+ */
+func (s *MFRC522Simulator) Tx(w, r []byte) error {
+	if r != nil && len(r) != len(w) {
+		return UsageError(fmt.Sprintf("Tx: len(w) %d != len(r) %d", len(w), len(r)))
+	}
+	if len(w) == 0 {
+		return nil
+	}
+	if w[0]&0x01 != 0 {
+		return UsageError(fmt.Sprintf("Tx: address byte LSB must be 0: %02x", w[0]))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	address := (w[0] >> 1) & 0x3F
+	if w[0]&0x80 != 0 {
+		for i := 1; i < len(w); i++ {
+			val := s.readRegister(address)
+			if r != nil {
+				r[i] = val
+			}
+			address = (w[i] >> 1) & 0x3F
+		}
+	} else {
+		for i := 1; i < len(w); i++ {
+			s.writeRegister(address, w[i])
+		}
+	}
+	return nil
+}
+
+func (s *MFRC522Simulator) TxPackets(p []spi.Packet) error {
+	var w, r []byte
+	for _, pkt := range p {
+		w = append(w, pkt.W...)
+		if pkt.R != nil {
+			r = append(r, make([]byte, len(pkt.W))...)
+		}
+		if !pkt.KeepCS {
+			if err := s.Tx(w, r); err != nil {
+				return err
+			}
+			w, r = nil, nil
+		}
+	}
+	if len(w) > 0 {
+		return s.Tx(w, r)
+	}
+	return nil
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// Pins
+/////////////////////////////////////////////////////////////////////////////////////
+
+type simResetPin struct {
+	*gpiotest.Pin
+	sim *MFRC522Simulator
+}
+
+/**
+ * Returns the NRSTPD pin. A rising edge performs a hard reset.
+ */
+func (s *MFRC522Simulator) ResetPin() gpio.PinOut {
+	return &simResetPin{Pin: &gpiotest.Pin{N: "SIM_RST", L: gpio.High}, sim: s}
+}
+
+func (p *simResetPin) Out(l gpio.Level) error {
+	p.sim.mu.Lock()
+	if p.sim.resetLevel == gpio.Low && l == gpio.High {
+		p.sim.reset()
+	}
+	p.sim.resetLevel = l
+	p.sim.mu.Unlock()
+	return p.Pin.Out(l)
+}
+
+type simIRQPin struct {
+	*gpiotest.Pin
+	sim *MFRC522Simulator
+}
+
+/**
+ * Returns the IRQ pin. Its level follows ComIEnReg/DivIEnReg and the interrupt request bits.
+ */
+func (s *MFRC522Simulator) IRQPin() gpio.PinIn {
+	return &simIRQPin{Pin: &gpiotest.Pin{N: "SIM_IRQ"}, sim: s}
+}
+
+func (p *simIRQPin) Read() gpio.Level {
+	p.sim.mu.Lock()
+	defer p.sim.mu.Unlock()
+	return p.sim.irqLevel()
+}
+
+func (p *simIRQPin) WaitForEdge(timeout time.Duration) bool {
+	initial := p.Read()
+	start := time.Now()
+	for timeout < 0 || time.Since(start) < timeout {
+		if p.Read() != initial {
+			return true
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+	return false
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// Chip model. s.mu must be held.
+/////////////////////////////////////////////////////////////////////////////////////
+
+func (s *MFRC522Simulator) reset() {
+	for i := range s.regs {
+		s.regs[i] = simResetValues[byte(i)]
+	}
+	s.regs[VersionReg] = s.Version
+	s.fifo = nil
+	s.timerOn = false
+	s.updateStatus()
+}
+
+func (s *MFRC522Simulator) readRegister(address byte) byte {
+	s.updateTimer()
+	switch address {
+	case FIFODataReg:
+		if len(s.fifo) == 0 {
+			return 0
+		}
+		val := s.fifo[0]
+		s.fifo = s.fifo[1:]
+		s.updateStatus()
+		return val
+	case FIFOLevelReg:
+		return byte(len(s.fifo))
+	case TCounterValueRegH:
+		return byte(s.timerCounter() >> 8)
+	case TCounterValueRegL:
+		return byte(s.timerCounter())
+	}
+	return s.regs[address]
+}
+
+func (s *MFRC522Simulator) writeRegister(address, value byte) {
+	s.updateTimer()
+	switch address {
+	case FIFODataReg:
+		if len(s.fifo) >= SIM_FIFO_SIZE {
+			s.regs[ErrorReg] |= 0x10 // BufferOvfl
+			s.setIrq(ComIrqReg, 0x02)
+			return
+		}
+		s.fifo = append(s.fifo, value)
+		if s.regs[CommandReg]&0x0F == PCD_CalcCRC {
+			s.calculateCRC()
+		}
+	case FIFOLevelReg:
+		if value&0x80 != 0 { // FlushBuffer
+			s.fifo = nil
+			s.regs[ErrorReg] &^= 0x10
+		}
+	case ComIrqReg, DivIrqReg:
+		if value&0x80 != 0 { // Set1, Set2
+			s.regs[address] |= value & 0x7F
+		} else {
+			s.regs[address] &^= value & 0x7F
+		}
+	case ControlReg:
+		s.regs[ControlReg] = s.regs[ControlReg]&0x07 | value&0x38
+		if value&0x80 != 0 { // TStopNow
+			s.timerOn = false
+		}
+		if value&0x40 != 0 { // TStartNow
+			s.startTimer()
+		}
+	case CommandReg:
+		powerDown := s.regs[CommandReg]&0x10 == 0 && value&0x10 != 0
+		s.regs[CommandReg] = value & 0x3F
+		if powerDown { // the oscillator stops, so do the timer and the RF field
+			s.timerOn = false
+			if off, ok := s.Field.(simFieldOff); ok && s.regs[TxControlReg]&0x03 != 0 {
+				off.FieldOff()
+			}
+		}
+		s.execute(value & 0x0F)
+	case TxControlReg:
+		on := s.regs[TxControlReg]&0x03 != 0
+		s.regs[TxControlReg] = value
+		if off, ok := s.Field.(simFieldOff); ok && on && value&0x03 == 0 {
+			off.FieldOff()
+		}
+	case BitFramingReg:
+		s.regs[BitFramingReg] = value
+		if value&0x80 != 0 && s.regs[CommandReg]&0x0F == PCD_Transceive {
+			s.transceive()
+		}
+	case ErrorReg, Status1Reg, VersionReg, TCounterValueRegH, TCounterValueRegL:
+		// read only
+	case Status2Reg:
+		// MFCrypto1On can only be cleared by software
+		s.regs[Status2Reg] = s.regs[Status2Reg]&0x07 | value&0xC0 | value&s.regs[Status2Reg]&0x08
+	default:
+		s.regs[address] = value
+	}
+	s.updateStatus()
+}
+
+func (s *MFRC522Simulator) execute(command byte) {
+	switch command {
+	case PCD_Idle, PCD_NoCmdChange:
+	case PCD_Mem:
+		if len(s.fifo) > 0 {
+			n := copy(s.internal[:], s.fifo)
+			s.fifo = s.fifo[n:]
+		} else {
+			s.fifo = append(s.fifo, s.internal[:]...)
+		}
+		s.idle()
+	case PCD_GenerateRandomID:
+		rand.Read(s.internal[:10])
+		s.idle()
+	case PCD_CalcCRC:
+		if s.regs[AutoTestReg]&0x0F == 0x09 {
+			s.fifo = append([]byte{}, s.selfTestResult()...)
+			s.setIrq(DivIrqReg, 0x04)
+			return
+		}
+		switch s.regs[ModeReg] & 0x03 {
+		case 0x00:
+			s.crc = CRC_RESET_VALUE_ZERO
+		case 0x01:
+			s.crc = CRC_RESET_VALUE_6363
+		case 0x02:
+			s.crc = CRC_RESET_VALUE_A671
+		default:
+			s.crc = CRC_RESET_VALUE_FFFF
+		}
+		s.calculateCRC()
+	case PCD_Transmit:
+		s.fifo = nil
+		s.setIrq(ComIrqReg, 0x40) // TxIRq
+		s.idle()
+	case PCD_Receive, PCD_Transceive:
+		// Transceive waits for StartSend, Receive waits for a frame which never comes
+	case PCD_MFAuthent:
+		s.regs[ErrorReg] |= 0x01 // ProtocolErr, authentication is not modelled
+		s.setIrq(ComIrqReg, 0x02)
+		s.idle()
+	case PCD_SoftReset:
+		s.reset()
+	default:
+		s.idle()
+	}
+}
+
+// Command terminated itself
+func (s *MFRC522Simulator) idle() {
+	s.regs[CommandReg] &^= 0x0F
+	s.setIrq(ComIrqReg, 0x10) // IdleIRq
+}
+
+func (s *MFRC522Simulator) setIrq(reg, mask byte) {
+	s.regs[reg] |= mask
+}
+
+func (s *MFRC522Simulator) selfTestResult() []byte {
+	switch s.Version {
+	case VER_0_0:
+		return MFRC522_VER_0_0
+	case VER_FM17522:
+		return FM17522_SELF_TEST
+	case VER_1_0:
+		return MFRC522_VER_1_0
+	case VER_2_0:
+		return MFRC522_VER_2_0
+	}
+	return make([]byte, SIM_FIFO_SIZE)
+}
+
+// The CRC coprocessor consumes the FIFO content while CalcCRC is active
+func (s *MFRC522Simulator) calculateCRC() {
+	s.crc = crc16A(s.crc, s.fifo)
+	s.fifo = nil
+	s.regs[CRCResultRegL] = byte(s.crc)
+	s.regs[CRCResultRegH] = byte(s.crc >> 8)
+	s.regs[Status1Reg] |= 0x20 // CRCReady
+	if s.crc == 0 {
+		s.regs[Status1Reg] |= 0x40 // CRCOk
+	} else {
+		s.regs[Status1Reg] &^= 0x40
+	}
+	s.setIrq(DivIrqReg, 0x04) // CRCIRq
+}
+
+func (s *MFRC522Simulator) transceive() {
+	data := s.fifo
+	s.fifo = nil
+	txLastBits := s.regs[BitFramingReg] & 0x07
+	s.regs[ErrorReg] &^= 0x0F // CollErr CRCErr ParityErr ProtocolErr
+	s.setIrq(ComIrqReg, 0x40) // TxIRq
+	s.timerOn = false
+	if s.regs[TModeReg]&0x80 != 0 { // TAuto
+		s.startTimer()
+	}
+
+	if s.Field == nil || s.regs[TxControlReg]&0x03 == 0 || s.regs[CommandReg]&0x10 != 0 {
+		return // nobody answers
+	}
+
+	frame := Frame{Data: append([]byte{}, data...), LastBits: txLastBits}
+	parityDisable := s.regs[MfRxReg]&0x10 != 0
+	if parityDisable { // the FIFO holds the bit stream with the parity bits
+		frame = UnpackParity(frame)
+	}
+	if s.regs[TxModeReg]&0x80 != 0 && frame.LastBits == 0 { // TxCRCEn
+		frame.Data = append(frame.Data, ISO14443aCRC(frame.Data)...)
+		if frame.Parity != nil {
+			frame.Parity = append(frame.Parity, OddParity(frame.Data[len(frame.Data)-2]), OddParity(frame.Data[len(frame.Data)-1]))
+		}
+	}
+
+	if f, ok := s.Field.(simFieldBitRate); ok {
+		f.SetBitRate(BitRate(s.regs[TxModeReg]>>4&0x07), BitRate(s.regs[RxModeReg]>>4&0x07))
+	}
+	resp, collision, ok := s.Field.Transceive(frame)
+	if !ok {
+		return
+	}
+	s.timerOn = false // the timer stops on the first received bit
+
+	if s.regs[RxModeReg]&0x80 != 0 && resp.LastBits == 0 && collision < 0 { // RxCRCEn
+		if len(resp.Data) < 3 || bytes.Compare(ISO14443aCRC(resp.Data[:len(resp.Data)-2]), resp.Data[len(resp.Data)-2:]) != 0 {
+			s.regs[ErrorReg] |= 0x04 // CRCErr
+			s.setIrq(ComIrqReg, 0x02)
+		} else { // CRC_A is not written to the FIFO
+			resp.Data = resp.Data[:len(resp.Data)-2]
+			if len(resp.Parity) > len(resp.Data) {
+				resp.Parity = resp.Parity[:len(resp.Data)]
+			}
+		}
+	}
+	if parityDisable {
+		resp = PackParity(resp)
+		if collision >= 0 {
+			collision += collision / 8 // position in the bit stream
+		}
+	} else {
+		for i := range resp.Parity {
+			if i < len(resp.Data) && resp.Parity[i]&1 != OddParity(resp.Data[i]) {
+				s.regs[ErrorReg] |= 0x02 // ParityErr
+				s.setIrq(ComIrqReg, 0x02)
+				break
+			}
+		}
+	}
+
+	// RxAlign: position of the first received bit in the first FIFO byte
+	rxAlign := int(s.regs[BitFramingReg]>>4) & 0x07
+	total := rxAlign + resp.BitLen()
+	received := make([]byte, (total+7)/8)
+	valuesAfterColl := s.regs[CollReg]&0x80 != 0
+	for i := 0; i < resp.BitLen(); i++ {
+		if collision >= 0 && i >= collision && !valuesAfterColl {
+			break
+		}
+		pos := rxAlign + i
+		received[pos/8] |= resp.Bit(i) << uint(pos%8)
+	}
+
+	if collision >= 0 {
+		s.regs[ErrorReg] |= 0x08 // CollErr
+		s.setIrq(ComIrqReg, 0x02)
+		pos := rxAlign + collision + 1
+		if pos > 32 {
+			s.regs[CollReg] = s.regs[CollReg]&0x80 | 0x20 // CollPosNotValid
+		} else {
+			s.regs[CollReg] = s.regs[CollReg]&0x80 | byte(pos)&0x1F // 32 is reported as 0
+		}
+	} else {
+		s.regs[CollReg] |= 0x20
+	}
+
+	if len(received) > SIM_FIFO_SIZE {
+		received = received[:SIM_FIFO_SIZE]
+		s.regs[ErrorReg] |= 0x10 // BufferOvfl
+		s.setIrq(ComIrqReg, 0x02)
+	}
+	s.fifo = append(s.fifo, received...)
+	s.regs[ControlReg] = s.regs[ControlReg]&^0x07 | byte(total%8)
+	s.setIrq(ComIrqReg, 0x20) // RxIRq
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// Timer, see datasheet 8.5
+/////////////////////////////////////////////////////////////////////////////////////
+
+func (s *MFRC522Simulator) timerPrescaler() uint32 {
+	return uint32(s.regs[TModeReg]&0x0F)<<8 | uint32(s.regs[TPrescalerReg])
+}
+
+func (s *MFRC522Simulator) timerReload() uint32 {
+	return uint32(s.regs[TReloadRegH])<<8 | uint32(s.regs[TReloadRegL])
+}
+
+// One timer tick: (2*TPrescaler+1)/13.56 MHz
+func (s *MFRC522Simulator) timerTick() time.Duration {
+	return time.Duration(uint64(2*s.timerPrescaler()+1) * uint64(time.Second) / SIM_CLOCK)
+}
+
+// Time from the timer start up to TimerIRq: (TReload+1) ticks
+func (s *MFRC522Simulator) TimerPeriod() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timerPeriod()
+}
+
+func (s *MFRC522Simulator) timerPeriod() time.Duration {
+	return time.Duration(s.timerReload()+1) * s.timerTick()
+}
+
+func (s *MFRC522Simulator) startTimer() {
+	s.timerOn = true
+	s.timerStart = s.Now()
+}
+
+func (s *MFRC522Simulator) updateTimer() {
+	if !s.timerOn {
+		return
+	}
+	period := s.timerPeriod()
+	if s.Now().Sub(s.timerStart) < period {
+		return
+	}
+	s.setIrq(ComIrqReg, 0x01)       // TimerIRq
+	if s.regs[TModeReg]&0x10 != 0 { // TAutoRestart
+		s.timerStart = s.timerStart.Add(period)
+	} else {
+		s.timerOn = false
+	}
+	s.updateStatus()
+}
+
+func (s *MFRC522Simulator) timerCounter() uint16 {
+	if !s.timerOn {
+		return 0
+	}
+	ticks := uint32(s.Now().Sub(s.timerStart) / s.timerTick())
+	if ticks > s.timerReload() {
+		return 0
+	}
+	return uint16(s.timerReload() - ticks)
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// Status and IRQ
+/////////////////////////////////////////////////////////////////////////////////////
+
+func (s *MFRC522Simulator) updateStatus() {
+	level := byte(len(s.fifo))
+	water := s.regs[WaterLevelReg] & 0x3F
+	status := s.regs[Status1Reg] & 0x60 // CRCOk CRCReady
+	if level <= water {
+		status |= 0x01 // LoAlert
+		s.setIrq(ComIrqReg, 0x04)
+	}
+	if SIM_FIFO_SIZE-level <= water {
+		status |= 0x02 // HiAlert
+		s.setIrq(ComIrqReg, 0x08)
+	}
+	if s.timerOn {
+		status |= 0x08 // TRunning
+	}
+	if s.irqActive() {
+		status |= 0x10 // IRq
+	}
+	s.regs[Status1Reg] = status
+}
+
+func (s *MFRC522Simulator) irqActive() bool {
+	return s.regs[ComIEnReg]&s.regs[ComIrqReg]&0x7F != 0 ||
+		s.regs[DivIEnReg]&s.regs[DivIrqReg]&0x14 != 0
+}
+
+// IRqInv in ComIEnReg inverts the pin. The open drain mode (IRqPushPull = 0)
+// is not distinguished, the pin is expected to have a pull-up.
+func (s *MFRC522Simulator) irqLevel() gpio.Level {
+	s.updateTimer()
+	active := s.irqActive()
+	if s.regs[ComIEnReg]&0x80 != 0 {
+		return gpio.Level(!active)
+	}
+	return gpio.Level(active)
+}
+
+// CRC_A with the given preset, see ISO/IEC 14443-3 Annex B
+func crc16A(crc uint16, data []byte) uint16 {
+	for _, bt := range data {
+		bt ^= byte(crc & 0xff)
+		bt ^= bt << 4
+		bt16 := uint16(bt)
+		crc = (crc >> 8) ^ (bt16 << 8) ^ (bt16 << 3) ^ (bt16 >> 4)
+	}
+	return crc
+}
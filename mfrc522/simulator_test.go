@@ -0,0 +1,165 @@
+package mfrc522
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func newSimulatedMFRC522(t *testing.T, sim *MFRC522Simulator) *MFRC522 {
+	transport, err := NewSPITransport(sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := NewMFRC522(transport, sim.ResetPin(), sim.IRQPin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+func TestSimulatorRegisters(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	reader := newSimulatedMFRC522(t, sim)
+
+	val, err := reader.PCD_ReadRegister(VersionReg)
+	is.NoErr(err)
+	is.Equal(val, byte(VER_2_0))
+
+	is.NoErr(reader.PCD_WriteRegister(TxASKReg, 0x40))
+	val, err = reader.PCD_ReadRegister(TxASKReg)
+	is.NoErr(err)
+	is.Equal(val, byte(0x40))
+
+	// FIFO
+	is.NoErr(reader.PCD_WriteFIFOBuffer([]byte{1, 2, 3}))
+	val, err = reader.PCD_ReadRegister(FIFOLevelReg)
+	is.NoErr(err)
+	is.Equal(val, byte(3))
+	data, err := reader.PCD_ReadFIFOBuffer(3)
+	is.NoErr(err)
+	is.True(bytes.Compare(data, []byte{1, 2, 3}) == 0)
+
+	// Set1 / clear of ComIrqReg
+	is.NoErr(reader.PCD_WriteRegister(ComIrqReg, 0x7F))
+	val, err = reader.PCD_ReadRegister(ComIrqReg)
+	is.NoErr(err)
+	is.Equal(val&0x73, byte(0)) // LoAlertIRq is raised again by the empty FIFO
+	is.NoErr(reader.PCD_WriteRegister(ComIrqReg, 0x80|0x01))
+	val, err = reader.PCD_ReadRegister(ComIrqReg)
+	is.NoErr(err)
+	is.Equal(val&0x01, byte(0x01))
+
+	// Soft reset restores the defaults
+	is.NoErr(reader.PCD_WriteRegister(CommandReg, PCD_SoftReset))
+	val, err = reader.PCD_ReadRegister(TxASKReg)
+	is.NoErr(err)
+	is.Equal(val, byte(0x00))
+}
+
+type countingConn struct {
+	*MFRC522Simulator
+	transactions int
+}
+
+func (c *countingConn) Tx(w, r []byte) error {
+	c.transactions++
+	return c.MFRC522Simulator.Tx(w, r)
+}
+
+func TestFIFOBurst(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	reader := newSimulatedMFRC522(t, sim)
+	conn := &countingConn{MFRC522Simulator: sim}
+	reader.transport = &SPITransport{Conn: conn}
+
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = byte(i * 3)
+	}
+	is.NoErr(reader.PCD_WriteFIFOBuffer(data))
+	is.Equal(conn.transactions, 1)
+
+	val, err := reader.PCD_ReadRegister(FIFOLevelReg)
+	is.NoErr(err)
+	is.Equal(val, byte(64))
+
+	conn.transactions = 0
+	read, err := reader.PCD_ReadFIFOBuffer(64)
+	is.NoErr(err)
+	is.Equal(conn.transactions, 1)
+	is.True(bytes.Compare(read, data) == 0)
+}
+
+func TestSimulatorSelfTest(t *testing.T) {
+	is := is.New(t)
+	for _, version := range []byte{VER_1_0, VER_2_0} {
+		sim := NewMFRC522Simulator()
+		sim.Version = version
+		reader := newSimulatedMFRC522(t, sim)
+		is.NoErr(reader.PCD_PerformSelfTest())
+	}
+
+	// No chip answers
+	sim := NewMFRC522Simulator()
+	sim.Version = 0x00
+	reader := newSimulatedMFRC522(t, sim)
+	is.True(reader.PCD_PerformSelfTest() != nil)
+}
+
+func TestSimulatorCalculateCRC(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	reader := newSimulatedMFRC522(t, sim)
+
+	data := []byte{0x93, 0x70, 0xc2, 0xa8, 0x2d, 0xf4, 0xb3}
+	crc, err := reader.PCD_CalculateCRC(ISO_14443_CRC_RESET, data, INTERUPT_TIMEOUT)
+	is.NoErr(err)
+	is.True(bytes.Compare(crc, ISO14443aCRC(data)) == 0)
+	is.True(bytes.Compare(crc, []byte{0xba, 0xa3}) == 0)
+}
+
+func TestSimulatorTransceive(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	reader := newSimulatedMFRC522(t, sim)
+	is.NoErr(reader.PCD_Init())
+	is.NoErr(reader.PCD_AntennaOn())
+
+	var sent Frame
+	sim.Field = SimFieldFunc(func(frame Frame) (Frame, int, bool) {
+		sent = frame
+		return Frame{Data: []byte{0x04, 0x00}}, -1, true
+	})
+
+	atqa, err := reader.PICC_RequestA()
+	is.NoErr(err)
+	is.True(bytes.Compare(sent.Data, []byte{PICC_CMD_REQA}) == 0)
+	is.Equal(sent.BitLen(), 7)
+	is.True(bytes.Compare(atqa, []byte{0x04, 0x00}) == 0)
+}
+
+func TestSimulatorTimer(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	reader := newSimulatedMFRC522(t, sim)
+	is.NoErr(reader.PCD_Init())
+	is.NoErr(reader.PCD_AntennaOn())
+
+	// PCD_Init: f_timer = 40kHz, 1000 ticks
+	period := sim.TimerPeriod()
+	is.True(period > 24*time.Millisecond && period < 26*time.Millisecond)
+
+	// Nobody answers, the timer expires before the driver checks ComIrqReg
+	is.NoErr(reader.PCD_WriteRegister(TReloadRegH, 0x00))
+	is.NoErr(reader.PCD_WriteRegister(TReloadRegL, 0x10))
+	_, err := reader.PICC_RequestA()
+	is.True(err != nil)
+	irq, err := reader.PCD_ReadRegister(ComIrqReg)
+	is.NoErr(err)
+	is.Equal(irq&0x21, byte(0x01)) // TimerIRq without RxIRq
+}
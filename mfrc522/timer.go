@@ -0,0 +1,126 @@
+// Internal timer of the MFRC522, datasheet section 8.5.
+
+package mfrc522
+
+import (
+	"fmt"
+	"time"
+)
+
+const PCD_CLOCK = 13560000 // Hz
+
+/**
+ * Timer periods of the PICC commands. The timer starts at the end of the
+ * transmission (TAuto) and its TimerIRq ends the wait for the answer.
+ */
+type Timeouts struct {
+	Anticollision time.Duration // REQA, WUPA, anticollision, select and HLTA
+	Default       time.Duration // authentication, read and other commands
+	Write         time.Duration // MIFARE write, the PICC programs its memory
+}
+
+var DefaultTimeouts = Timeouts{
+	Anticollision: 2 * time.Millisecond, // FDT of the answer is below 100µs
+	Default:       INTERUPT_TIMEOUT,
+	Write:         10 * time.Millisecond,
+}
+
+// The host waits that long after the timer period before it gives up on TimerIRq.
+// It also covers the transmission which is before the start of the timer.
+const TIMER_MARGIN = 10 * time.Millisecond
+
+/**
+ * Timer registers for the period d: f_timer = 13.56 MHz / (2*prescaler+1),
+ * period = (reload+1) / f_timer. The smallest prescaler is used.
+ */
+func TimerSettings(d time.Duration) (prescaler, reload uint16, err error) {
+	cycles := d.Seconds() * PCD_CLOCK
+	presc := 0
+	if cycles > 0x10000 {
+		presc = int((cycles/0x10000 - 1) / 2)
+		for float64(2*presc+1)*0x10000 < cycles {
+			presc++
+		}
+	}
+	if presc > 0x0FFF {
+		return 0, 0, UsageError(fmt.Sprintf("Timer period too long: %s", d))
+	}
+	ticks := int(cycles/float64(2*presc+1) + 0.5)
+	if ticks < 1 {
+		return 0, 0, UsageError(fmt.Sprintf("Timer period too short: %s", d))
+	}
+	return uint16(presc), uint16(ticks - 1), nil
+}
+
+/**
+ * Inverse of TimerSettings.
+ */
+func TimerPeriod(prescaler, reload uint16) time.Duration {
+	return time.Duration(float64(2*int(prescaler)+1) * float64(int(reload)+1) * float64(time.Second) / PCD_CLOCK)
+}
+
+/**
+ * Frame waiting time of ISO/IEC 14443-4 with the ΔFWT of the PCD:
+ * FWT = (256*16/fc) * 2^FWI, FWI 15 is reserved and means the default 4.
+ */
+func FrameWaitingTime(fwi byte) time.Duration {
+	if fwi > 14 {
+		fwi = 4
+	}
+	cycles := (256*16)<<fwi + 49152
+	return time.Duration(float64(cycles) * float64(time.Second) / PCD_CLOCK)
+}
+
+/**
+ * Sets the timer to the period d, TAuto and the other TModeReg bits are kept.
+ * With QUIRK_TIMER_DRIFT the registers are corrected by the drift of WithTimerDrift.
+ */
+func (r *MFRC522) PCD_SetTimer(d time.Duration) error {
+	r.lock()
+	defer r.unlock()
+	return r.setTimer(d)
+}
+
+func (r *MFRC522) setTimer(d time.Duration) error {
+	if d == r.timerPeriod {
+		return nil
+	}
+	period := d
+	if r.chip.Quirks&QUIRK_TIMER_DRIFT != 0 {
+		period = time.Duration(float64(d) / (1 + r.timerDrift))
+	}
+	prescaler, reload, err := TimerSettings(period)
+	if err != nil {
+		return err
+	}
+	if err := r.writeTimer(prescaler, reload); err != nil {
+		return err
+	}
+	r.timerPeriod = d
+	return nil
+}
+
+/**
+ * Writes the timer registers as they are, without the quirks of the chip.
+ * The period of setTimer is forgotten.
+ */
+func (r *MFRC522) writeTimer(prescaler, reload uint16) error {
+	value, err := r.readRegister(TModeReg)
+	if err != nil {
+		return err
+	}
+	mode := DecodeTMode(value)
+	mode.TPrescalerHi = byte(prescaler >> 8)
+	r.timerPeriod = 0
+	for _, reg := range []struct{ address, value byte }{
+		{TModeReg, mode.Encode()},
+		{TPrescalerReg, byte(prescaler)},
+		{TReloadRegH, byte(reload >> 8)},
+		{TReloadRegL, byte(reload)},
+	} {
+		if err := r.writeRegister(reg.address, reg.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
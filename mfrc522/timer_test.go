@@ -0,0 +1,55 @@
+package mfrc522
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestTimerSettings(t *testing.T) {
+	is := is.New(t)
+	for _, d := range []time.Duration{100 * time.Microsecond, time.Millisecond, 25 * time.Millisecond, time.Second} {
+		prescaler, reload, err := TimerSettings(d)
+		is.NoErr(err)
+		tick := float64(2*int(prescaler)+1) / PCD_CLOCK
+		period := float64(int(reload)+1) * tick
+		is.True(math.Abs(period-d.Seconds()) <= tick)
+	}
+	_, _, err := TimerSettings(time.Minute)
+	is.True(errors.Is(err, ErrUsage))
+}
+
+func TestFrameWaitingTime(t *testing.T) {
+	is := is.New(t)
+	is.Equal(FrameWaitingTime(0).Round(time.Microsecond), 3927*time.Microsecond) // 302µs + ΔFWT
+	is.Equal(FrameWaitingTime(4).Round(time.Microsecond), 8458*time.Microsecond) // 4833µs + ΔFWT
+	is.Equal(FrameWaitingTime(15), FrameWaitingTime(4))
+	is.Equal(FrameWaitingTime(14).Round(time.Millisecond), 4953*time.Millisecond)
+}
+
+func TestTimerIRqTimeout(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	reader := newSimulatedMFRC522(t, sim)
+	is.NoErr(reader.PCD_Init())
+	is.NoErr(reader.PCD_AntennaOn())
+
+	is.NoErr(reader.PCD_SetTimer(3 * time.Millisecond))
+	is.True(sim.TimerPeriod()-3*time.Millisecond < time.Microsecond)
+
+	// No PICC: the TimerIRq of the anticollision timeout ends the wait
+	start := time.Now()
+	_, err := reader.PICC_RequestA()
+	is.True(time.Since(start) < DefaultTimeouts.Anticollision+TIMER_MARGIN/2)
+	var commErr *CommunicationError
+	is.True(errors.As(err, &commErr))
+	is.Equal(commErr.ComIrqReg&0x01, byte(0x01))
+	is.True(sim.TimerPeriod()-DefaultTimeouts.Anticollision < time.Microsecond)
+
+	prescaler, reload, err := TimerSettings(DefaultTimeouts.Anticollision)
+	is.NoErr(err)
+	is.True(TimerPeriod(prescaler, reload)-DefaultTimeouts.Anticollision < time.Microsecond)
+}
@@ -0,0 +1,512 @@
+// Frame tracing: every frame exchanged with the PICC, as a text log or as
+// pcapng with the ISO 14443 link type for Wireshark.
+
+package mfrc522
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TraceDirection int
+
+const (
+	TRACE_PCD_TO_PICC TraceDirection = iota
+	TRACE_PICC_TO_PCD
+)
+
+func (d TraceDirection) String() string {
+	switch d {
+	case TRACE_PCD_TO_PICC:
+		return "PCD"
+	case TRACE_PICC_TO_PCD:
+		return "PICC"
+	}
+	return fmt.Sprintf("TraceDirection(%d)", int(d))
+}
+
+// Parity and CRC status of a traced frame
+type TraceCheck int
+
+const (
+	TRACE_UNCHECKED TraceCheck = iota // not generated or checked by the chip, no CRC_A found
+	TRACE_OK                          // generated or checked by the chip, or a valid CRC_A ends the frame
+	TRACE_ERROR                       // ParityErr or CRCErr of a received frame
+)
+
+func (c TraceCheck) String() string {
+	switch c {
+	case TRACE_UNCHECKED:
+		return "unchecked"
+	case TRACE_OK:
+		return "ok"
+	case TRACE_ERROR:
+		return "error"
+	}
+	return fmt.Sprintf("TraceCheck(%d)", int(c))
+}
+
+// A frame sent or received by the chip. Time is the host time when the
+// transmission started or the reception was signalled.
+type TraceFrame struct {
+	Time        time.Time
+	Direction   TraceDirection
+	Frame                  // as in the FIFO without the parity bits, Parity is set with ParityDisable
+	ParityCheck TraceCheck // parity bits generated or checked by the chip
+	CRCCheck    TraceCheck
+	ChipCRC     bool   // the chip appended CRC_A, or checked and removed it
+	Encrypted   bool   // Crypto1 cipher text
+	Collision   bool   // CollErr of a received frame, the bits after the collision are unknown
+	Command     string // decoded command or response, empty if unknown
+	Err         error  // error of a received frame
+}
+
+/**
+ * The bytes on the air without the parity bits: the CRC_A appended or removed
+ * by the chip is included.
+ */
+func (f TraceFrame) AirData() []byte {
+	data := append([]byte{}, f.Data...)
+	if f.ChipCRC && f.CRCCheck == TRACE_OK {
+		data = append(data, ISO14443aCRC(f.Data)...)
+	}
+	return data
+}
+
+/**
+ * Number of bits on the air without the parity bits.
+ */
+func (f TraceFrame) AirBits() int {
+	if f.ChipCRC && f.CRCCheck == TRACE_OK {
+		return f.BitLen() + 16
+	}
+	return f.BitLen()
+}
+
+func (f TraceFrame) String() string {
+	command := f.Command
+	if command == "" {
+		command = "-"
+	}
+	var flags []string
+	if f.ParityCheck == TRACE_ERROR {
+		flags = append(flags, "parity error")
+	}
+	if f.Parity != nil {
+		var bits bytes.Buffer
+		for _, p := range f.Parity {
+			bits.WriteByte('0' + p&1)
+		}
+		flags = append(flags, "parity "+bits.String())
+	}
+	switch {
+	case f.CRCCheck == TRACE_OK && f.ChipCRC:
+		flags = append(flags, "crc ok (chip)")
+	case f.CRCCheck == TRACE_OK:
+		flags = append(flags, "crc ok")
+	case f.CRCCheck == TRACE_ERROR:
+		flags = append(flags, "crc error")
+	}
+	if f.Encrypted {
+		flags = append(flags, "encrypted")
+	}
+	if f.Collision {
+		flags = append(flags, "collision")
+	}
+	if f.Err != nil {
+		flags = append(flags, "error: "+f.Err.Error())
+	}
+	s := fmt.Sprintf("%-4s  %-17s %4d bits  % x", f.Direction, command, f.AirBits(), f.AirData())
+	if len(flags) > 0 {
+		s += "  [" + strings.Join(flags, ", ") + "]"
+	}
+	return s
+}
+
+// Tracer receives the frames of the transceive path, see WithTracer.
+// Trace is called with the lock of the driver held and should return quickly.
+type Tracer interface {
+	Trace(frame TraceFrame)
+}
+
+// TraceFunc is a Tracer function
+type TraceFunc func(frame TraceFrame)
+
+func (fn TraceFunc) Trace(frame TraceFrame) {
+	fn(frame)
+}
+
+// TraceRecorder keeps the traced frames in memory for WriteText and WritePcapng
+type TraceRecorder struct {
+	mu     sync.Mutex
+	frames []TraceFrame
+	limit  int
+}
+
+/**
+ * A recorder of the last limit frames, 0 keeps all frames.
+ */
+func NewTraceRecorder(limit int) *TraceRecorder {
+	return &TraceRecorder{limit: limit}
+}
+
+func (t *TraceRecorder) Trace(frame TraceFrame) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.frames = append(t.frames, frame)
+	if t.limit > 0 && len(t.frames) > t.limit {
+		t.frames = append([]TraceFrame{}, t.frames[len(t.frames)-t.limit:]...)
+	}
+}
+
+/**
+ * The recorded frames, oldest first.
+ */
+func (t *TraceRecorder) Frames() []TraceFrame {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceFrame{}, t.frames...)
+}
+
+func (t *TraceRecorder) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.frames = nil
+}
+
+func (t *TraceRecorder) WriteText(w io.Writer) error {
+	return WriteTraceText(w, t.Frames())
+}
+
+func (t *TraceRecorder) WritePcapng(w io.Writer) error {
+	return WritePcapng(w, t.Frames())
+}
+
+// TextTracer writes every frame as a line of the text log when it is traced
+type TextTracer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+}
+
+func NewTextTracer(w io.Writer) *TextTracer {
+	return &TextTracer{w: w}
+}
+
+func (t *TextTracer) Trace(frame TraceFrame) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.start.IsZero() {
+		t.start = frame.Time
+	}
+	fmt.Fprintf(t.w, "%12.6f  %s\n", frame.Time.Sub(t.start).Seconds(), frame)
+}
+
+/**
+ * Writes one line per frame, the time in seconds from the first frame.
+ */
+func WriteTraceText(w io.Writer, frames []TraceFrame) error {
+	var buff bytes.Buffer
+	for _, frame := range frames {
+		fmt.Fprintf(&buff, "%12.6f  %s\n", frame.Time.Sub(frames[0].Time).Seconds(), frame)
+	}
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+// Link type of the ISO 14443 pseudo-header, https://www.kaiser.cx/pcap-iso14443.html
+const LINKTYPE_ISO_14443 = 264
+
+const (
+	iso14443EventPICCToPCD = 0xFF
+	iso14443EventPCDToPICC = 0xFE
+)
+
+const (
+	pcapngSectionHeader        = 0x0A0D0D0A
+	pcapngInterfaceDescription = 0x00000001
+	pcapngEnhancedPacket       = 0x00000006
+	pcapngOptComment           = 1
+)
+
+/**
+ * Writes the frames as a pcapng file with one LINKTYPE_ISO_14443 interface
+ * and microsecond timestamps. The text of the frame is the packet comment,
+ * frames without data (errors) are left out.
+ */
+func WritePcapng(w io.Writer, frames []TraceFrame) error {
+	var buff bytes.Buffer
+	le := binary.LittleEndian
+	block := func(blockType uint32, body []byte) {
+		var head [8]byte
+		le.PutUint32(head[0:], blockType)
+		le.PutUint32(head[4:], uint32(12+len(body)))
+		buff.Write(head[:])
+		buff.Write(body)
+		buff.Write(head[4:])
+	}
+	pad := func(b []byte) []byte {
+		return append(b, make([]byte, -len(b)&3)...)
+	}
+
+	// Byte-order magic, version 1.0, section length unknown
+	shb := make([]byte, 16)
+	le.PutUint32(shb[0:], 0x1A2B3C4D)
+	le.PutUint16(shb[4:], 1)
+	le.PutUint64(shb[8:], 0xFFFFFFFFFFFFFFFF)
+	block(pcapngSectionHeader, shb)
+
+	// Link type, no snapshot length, the default timestamp resolution is 1µs
+	idb := make([]byte, 8)
+	le.PutUint16(idb[0:], LINKTYPE_ISO_14443)
+	block(pcapngInterfaceDescription, idb)
+
+	for _, frame := range frames {
+		data := frame.AirData()
+		if len(data) == 0 {
+			continue
+		}
+		event := byte(iso14443EventPCDToPICC)
+		if frame.Direction == TRACE_PICC_TO_PCD {
+			event = iso14443EventPICCToPCD
+		}
+		// Pseudo-header: version 0, event, big endian length
+		packet := append([]byte{0, event, byte(len(data) >> 8), byte(len(data))}, data...)
+
+		epb := make([]byte, 20)
+		ts := uint64(frame.Time.UnixNano() / int64(time.Microsecond))
+		le.PutUint32(epb[4:], uint32(ts>>32))
+		le.PutUint32(epb[8:], uint32(ts))
+		le.PutUint32(epb[12:], uint32(len(packet)))
+		le.PutUint32(epb[16:], uint32(len(packet)))
+		epb = append(epb, pad(packet)...)
+
+		comment := []byte(frame.String())
+		option := make([]byte, 4)
+		le.PutUint16(option[0:], pcapngOptComment)
+		le.PutUint16(option[2:], uint16(len(comment)))
+		epb = append(epb, option...)
+		epb = append(epb, pad(comment)...)
+		epb = append(epb, 0, 0, 0, 0) // opt_endofopt
+		block(pcapngEnhancedPacket, epb)
+	}
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+/**
+ * Sets the tracer of the transceive path, nil disables tracing.
+ * The frames are logged with LOG_TRACE as well.
+ */
+func (r *MFRC522) SetTracer(tracer Tracer) {
+	r.lock()
+	defer r.unlock()
+	r.tracer = tracer
+}
+
+/**
+ * Records a frame of communicate. errorReg is the ErrorReg value of a received frame.
+ */
+func (r *MFRC522) trace(at time.Time, direction TraceDirection, frame Frame, config RawConfig, errorReg byte, err error) {
+	if _, nop := r.logger.(nopLogger); nop && r.tracer == nil {
+		return
+	}
+	errs := DecodeErrorFlags(errorReg)
+	f := TraceFrame{Time: at, Direction: direction, Collision: errs.CollErr, Err: err}
+	if config.ParityDisable {
+		f.Frame = UnpackParity(frame)
+		f.Encrypted = config.cipher != cipherNone
+	} else {
+		f.Frame = Frame{Data: append([]byte{}, frame.Data...), LastBits: frame.LastBits}
+		f.ParityCheck = TRACE_OK
+		if errs.ParityErr {
+			f.ParityCheck = TRACE_ERROR
+		}
+	}
+
+	chipCRC := config.TxCRC
+	if direction == TRACE_PICC_TO_PCD {
+		chipCRC = config.RxCRC
+	}
+	n := len(f.Data)
+	switch {
+	case chipCRC && f.LastBits == 0:
+		f.ChipCRC, f.CRCCheck = true, TRACE_OK
+		if errs.CRCErr {
+			f.CRCCheck = TRACE_ERROR
+		}
+	case !f.Encrypted && f.LastBits == 0 && n >= 3 && bytes.Equal(ISO14443aCRC(f.Data[:n-2]), f.Data[n-2:]):
+		f.CRCCheck = TRACE_OK
+	}
+
+	f.Command = r.traceDecoder.name(f, config.cipher)
+	if r.tracer != nil {
+		r.tracer.Trace(f)
+	}
+	r.logger.Log(LOG_TRACE, "frame", "trace", f)
+}
+
+/**
+ * Traces a frame received with an error. The FIFO is only read for a tracer.
+ */
+func (r *MFRC522) traceReceived(at time.Time, command byte, config RawConfig, errorReg byte, err error) {
+	if command != PCD_Transceive {
+		return
+	}
+	var frame Frame
+	if r.tracer != nil {
+		frame, _ = r.readFrame()
+	}
+	r.trace(at, TRACE_PICC_TO_PCD, frame, config, errorReg, err)
+}
+
+// The Crypto1 frames of the driver. The cipher text can't be decoded, the
+// frames of an authentication are named by their step.
+type traceCipher int
+
+const (
+	cipherNone       traceCipher = iota
+	cipherData                   // commands and data of the authenticated PICC
+	cipherNestedAuth             // AUTH and NT of a nested authentication
+	cipherAuthReader             // {nr}{ar} and AT, encrypted with the new key
+)
+
+// Names the frames, the response by the last command
+type traceDecoder struct {
+	request string
+	auth    string // "AUTH" or "AUTH (nested)", the prefix of the last authentication
+	isoDEP  bool   // a RATS was sent, the frames are ISO-DEP blocks
+}
+
+func (d *traceDecoder) name(f TraceFrame, cipher traceCipher) string {
+	if f.Direction == TRACE_PCD_TO_PICC {
+		d.request = d.command(f, cipher)
+		return d.request
+	}
+	if cipher == cipherData || len(f.Data) == 0 {
+		return ""
+	}
+	if f.BitLen() == 4 {
+		if f.Data[0]&0x0F == MF_ACK {
+			return "ACK"
+		}
+		return "NAK"
+	}
+	if d.isoDEP && d.request != "RATS" {
+		return isoDEPBlockName(f.Data[0])
+	}
+	switch {
+	case d.request == "REQA" || d.request == "WUPA":
+		return "ATQA"
+	case strings.HasPrefix(d.request, "ANTICOLLISION"):
+		return "UID"
+	case strings.HasPrefix(d.request, "SELECT"):
+		return "SAK"
+	case d.request == d.auth+" NR AR":
+		return d.auth + " AT"
+	case strings.HasPrefix(d.request, "AUTH"):
+		return d.auth + " NT"
+	case d.request == "":
+		return ""
+	}
+	return d.request + " response"
+}
+
+func (d *traceDecoder) command(f TraceFrame, cipher traceCipher) string {
+	data := f.Data
+	switch cipher {
+	case cipherData:
+		return "encrypted"
+	case cipherNestedAuth:
+		d.auth = "AUTH (nested)"
+		return d.auth + " KEY A" // the driver authenticates with key A only
+	case cipherAuthReader:
+		return d.auth + " NR AR"
+	}
+	if len(data) == 0 {
+		return ""
+	}
+	if f.BitLen() == 7 {
+		switch data[0] {
+		case PICC_CMD_REQA:
+			d.isoDEP = false
+			return "REQA"
+		case PICC_CMD_WUPA:
+			d.isoDEP = false
+			return "WUPA"
+		}
+	}
+	// The length of the command without a CRC_A appended on the host
+	n := len(data)
+	if f.CRCCheck == TRACE_OK && !f.ChipCRC {
+		n -= 2
+	}
+	switch data[0] {
+	case PICC_CMD_SEL_CL1, PICC_CMD_SEL_CL2, PICC_CMD_SEL_CL3:
+		level := (data[0]-PICC_CMD_SEL_CL1)/2 + 1
+		if n > 1 && data[1] == 0x70 {
+			return fmt.Sprintf("SELECT CL%d", level)
+		}
+		return fmt.Sprintf("ANTICOLLISION CL%d", level)
+	}
+	if d.isoDEP {
+		name := isoDEPBlockName(data[0])
+		if name == "S(DESELECT)" {
+			d.isoDEP = false
+		}
+		return name
+	}
+	switch {
+	case data[0] == PICC_CMD_HLTA && n == 2 && data[1] == 0:
+		return "HLTA"
+	case data[0] == PICC_CMD_RATS:
+		d.isoDEP = true
+		return "RATS"
+	case data[0]&0xF0 == PICC_CMD_PPS:
+		return "PPS"
+	case data[0] == PICC_CMD_GET_VERSION && n == 1:
+		return "GET_VERSION"
+	case data[0] == PICC_CMD_MF_AUTH_KEY_A:
+		d.auth = "AUTH"
+		return "AUTH KEY A"
+	case data[0] == PICC_CMD_MF_AUTH_KEY_B:
+		d.auth = "AUTH"
+		return "AUTH KEY B"
+	case data[0] == PICC_CMD_MF_READ:
+		return "READ"
+	case data[0] == PICC_CMD_MF_WRITE:
+		return "WRITE"
+	case data[0] == PICC_CMD_UL_WRITE:
+		return "UL WRITE"
+	case data[0] == PICC_CMD_MF_DECREMENT:
+		return "DECREMENT"
+	case data[0] == PICC_CMD_MF_INCREMENT:
+		return "INCREMENT"
+	case data[0] == PICC_CMD_MF_RESTORE:
+		return "RESTORE"
+	case data[0] == PICC_CMD_MF_TRANSFER:
+		return "TRANSFER"
+	}
+	return ""
+}
+
+func isoDEPBlockName(pcb byte) string {
+	switch {
+	case pcb&0xC2 == 0x02:
+		return "I-block"
+	case pcb&0xE6 == 0xA2 && pcb&0x10 != 0:
+		return "R(NAK)"
+	case pcb&0xE6 == 0xA2:
+		return "R(ACK)"
+	case pcb&0xF7 == PCB_DESELECT:
+		return "S(DESELECT)"
+	case pcb&0xF7 == 0xF2:
+		return "S(WTX)"
+	}
+	return ""
+}
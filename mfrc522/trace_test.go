@@ -0,0 +1,168 @@
+package mfrc522
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestTraceClassic(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t, NewVirtualMifareClassic1K([]byte{0x9c, 0x59, 0x9b, 0x32}))
+	recorder := NewTraceRecorder(0)
+	reader.SetTracer(recorder)
+
+	is.True(reader.PICC_IsNewCardPresent())
+	uid, err := reader.PICC_Select()
+	is.NoErr(err)
+	is.NoErr(reader.PICC_AuthentificateKeyA(*uid, defaultKey, 4))
+	_, err = reader.MIFARE_Read(5)
+	is.NoErr(err)
+
+	var text bytes.Buffer
+	is.NoErr(recorder.WriteText(&text))
+
+	var names []string
+	for _, frame := range recorder.Frames() {
+		names = append(names, frame.Command)
+	}
+	is.Equal(strings.Join(names, ","), "REQA,ATQA,ANTICOLLISION CL1,UID,SELECT CL1,SAK,AUTH KEY A,AUTH NT,AUTH NR AR,AUTH AT,encrypted,")
+
+	frames := recorder.Frames()
+	is.Equal(frames[0].AirBits(), 7)
+	is.Equal(frames[0].Direction, TRACE_PCD_TO_PICC)
+	is.Equal(frames[1].Direction, TRACE_PICC_TO_PCD)
+	is.Equal(frames[4].CRCCheck, TRACE_OK) // SELECT with the CRC_A of the host
+	is.Equal(frames[3].CRCCheck, TRACE_UNCHECKED)
+	is.True(!frames[6].Encrypted && !frames[7].Encrypted) // AUTH KEY A and NT are plain text
+	is.True(frames[8].Encrypted && frames[9].Encrypted)
+	is.True(frames[10].Encrypted)
+	is.Equal(len(frames[10].Parity), 4)
+	is.True(strings.Contains(text.String(), "PICC  ATQA                16 bits  04 00"))
+}
+
+func TestTraceNestedAuth(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t, NewVirtualMifareClassic1K([]byte{0x9c, 0x59, 0x9b, 0x32}))
+	is.True(reader.PICC_IsNewCardPresent())
+	uid, err := reader.PICC_Select()
+	is.NoErr(err)
+	is.NoErr(reader.PICC_AuthentificateKeyA(*uid, defaultKey, 4))
+
+	// Every frame is encrypted, none is decoded as a command
+	recorder := NewTraceRecorder(0)
+	reader.SetTracer(recorder)
+	is.NoErr(reader.PICC_AuthentificateKeyA(*uid, defaultKey, 8))
+	_, err = reader.MIFARE_Read(8)
+	is.NoErr(err)
+
+	var names []string
+	for _, frame := range recorder.Frames() {
+		names = append(names, frame.Command)
+		is.True(frame.Encrypted)
+	}
+	is.Equal(strings.Join(names, ","), "AUTH (nested) KEY A,AUTH (nested) NT,AUTH (nested) NR AR,AUTH (nested) AT,encrypted,")
+	is.True(!reader.traceDecoder.isoDEP)
+}
+
+func TestTraceCollision(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t,
+		NewVirtualMifareClassic1K([]byte{0x11, 0x22, 0x33, 0x44}), NewVirtualMifareClassic1K([]byte{0x11, 0x22, 0x37, 0x44}))
+	recorder := NewTraceRecorder(0)
+	reader.SetTracer(recorder)
+	is.True(reader.PICC_IsNewCardPresent())
+	_, err := reader.PICC_Select()
+	is.NoErr(err)
+
+	// The first UID is received with a collision in bit 18
+	var uids []TraceFrame
+	for _, frame := range recorder.Frames() {
+		if frame.Command == "UID" {
+			uids = append(uids, frame)
+		}
+	}
+	is.True(len(uids) > 1)
+	is.True(uids[0].Collision)
+	is.True(uids[0].Err == nil)
+	is.True(strings.Contains(uids[0].String(), "collision"))
+	is.True(!uids[len(uids)-1].Collision)
+}
+
+func TestTraceChipCRC(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	sim.Field = NewVirtualField(NewVirtualNTAG213([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}))
+	var frames []TraceFrame
+	reader, err := New(SPIPort(sim), WithHardwareCRC(), WithTracer(TraceFunc(func(f TraceFrame) {
+		frames = append(frames, f)
+	})))
+	is.NoErr(err)
+	is.NoErr(reader.PCD_Init())
+	is.NoErr(reader.PCD_AntennaOn())
+	is.True(reader.PICC_IsNewCardPresent())
+	_, err = reader.PICC_Select()
+	is.NoErr(err)
+
+	// SAK checked by the chip, the CRC_A is part of the air frame
+	sak := frames[len(frames)-1]
+	is.Equal(sak.Command, "SAK")
+	is.True(sak.ChipCRC)
+	is.Equal(sak.CRCCheck, TRACE_OK)
+	is.Equal(len(sak.Data), 1)
+	is.Equal(sak.AirData()[1:], ISO14443aCRC(sak.Data))
+	is.Equal(sak.AirBits(), 24)
+
+	// Nothing is received after HLTA, only the command is traced
+	n := len(frames)
+	_, err = transceive(reader, append([]byte{PICC_CMD_HLTA, 0x00}, ISO14443aCRC([]byte{PICC_CMD_HLTA, 0x00})...))
+	is.True(err != nil)
+	is.Equal(len(frames), n+1)
+	is.Equal(frames[n].Command, "HLTA")
+}
+
+func TestTracePcapng(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t, NewVirtualMifareClassic1K([]byte{0x9c, 0x59, 0x9b, 0x32}))
+	recorder := NewTraceRecorder(3)
+	reader.SetTracer(recorder)
+	is.True(reader.PICC_IsNewCardPresent())
+	_, err := reader.PICC_Select()
+	is.NoErr(err)
+	frames := recorder.Frames()
+	is.Equal(len(frames), 3) // the last ones: UID, SELECT, SAK
+
+	var buff bytes.Buffer
+	is.NoErr(recorder.WritePcapng(&buff))
+	data := buff.Bytes()
+	le := binary.LittleEndian
+
+	var types []uint32
+	var packets [][]byte
+	for len(data) > 0 {
+		length := le.Uint32(data[4:])
+		is.Equal(length%4, uint32(0))
+		is.Equal(le.Uint32(data[length-4:]), length) // trailing block length
+		types = append(types, le.Uint32(data))
+		switch le.Uint32(data) {
+		case pcapngSectionHeader:
+			is.Equal(le.Uint32(data[8:]), uint32(0x1A2B3C4D))
+		case pcapngInterfaceDescription:
+			is.Equal(le.Uint16(data[8:]), uint16(LINKTYPE_ISO_14443))
+		case pcapngEnhancedPacket:
+			captured := le.Uint32(data[20:])
+			packets = append(packets, data[28:28+captured])
+		}
+		data = data[length:]
+	}
+	is.Equal(types, []uint32{pcapngSectionHeader, pcapngInterfaceDescription, pcapngEnhancedPacket, pcapngEnhancedPacket, pcapngEnhancedPacket})
+
+	// Pseudo-header: version, event, length
+	sel := packets[1]
+	is.Equal(sel[:4], []byte{0, iso14443EventPCDToPICC, 0, 9})
+	is.Equal(sel[4:6], []byte{PICC_CMD_SEL_CL1, 0x70})
+	is.Equal(packets[2][:5], []byte{0, iso14443EventPICCToPCD, 0, 3, 0x08})
+}
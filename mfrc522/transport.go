@@ -0,0 +1,192 @@
+// Host interfaces of the MFRC522, datasheet section 8.1.
+
+package mfrc522
+
+import (
+	"fmt"
+	"io"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+)
+
+// Default I2C address: ADR_0..ADR_5 pins and the fixed 0101 prefix (datasheet 8.1.3.2)
+const MFRC522_I2C_ADDR = 0x28
+
+/**
+ * Transport does the address framing of a host interface.
+ * Several bytes of the same register (FIFODataReg) are transferred at once,
+ * the register address is not incremented.
+ */
+type Transport interface {
+	// Writes values to the register
+	WriteRegister(address byte, values ...byte) error
+	// Reads len(values) bytes from the register
+	ReadRegister(address byte, values []byte) error
+}
+
+/**
+ * SPI interface, datasheet section 8.1.2.
+ * Address byte: bit 7 read, bits 6..1 address, bit 0 zero.
+ */
+type SPITransport struct {
+	Conn spi.Conn
+	port spi.Port
+}
+
+const (
+	SPI_DEFAULT_SPEED = 10 * physic.MegaHertz // maximum of the chip
+	SPI_DEFAULT_MODE  = spi.Mode0
+)
+
+/**
+ * Connects to the port with 10MHz, mode 0 and 8 bits.
+ */
+func NewSPITransport(port spi.Port) (*SPITransport, error) {
+	t := SPIPort(port)
+	if err := t.connect(SPI_DEFAULT_SPEED, SPI_DEFAULT_MODE); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+/**
+ * Returns a transport that is not connected yet, New connects it with
+ * the WithSPISpeed and WithSPIMode options.
+ */
+func SPIPort(port spi.Port) *SPITransport {
+	return &SPITransport{port: port}
+}
+
+func (t *SPITransport) connect(speed physic.Frequency, mode spi.Mode) error {
+	conn, err := t.port.Connect(speed, mode, 8)
+	if err != nil {
+		return err
+	}
+	t.Conn = conn
+	return nil
+}
+
+/**
+ * All bytes are sent in one SPI transaction: the address byte followed by the data.
+ */
+func (t *SPITransport) WriteRegister(address byte, values ...byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+	data := make([]byte, len(values)+1)
+	data[0] = (address << 1) & 0x7E
+	copy(data[1:], values)
+	return t.Conn.Tx(data, nil)
+}
+
+/**
+ * All bytes are read in one SPI transaction: the address byte is repeated
+ * len(values) times and terminated with 00, byte n of the answer is the
+ * value for address byte n-1.
+ */
+func (t *SPITransport) ReadRegister(address byte, values []byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+	data := make([]byte, len(values)+1)
+	for ind := range values {
+		data[ind] = ((address << 1) & 0x7E) | 0x80
+	}
+	out := make([]byte, len(data))
+	if err := t.Conn.Tx(data, out); err != nil {
+		return err
+	}
+	copy(values, out[1:])
+	return nil
+}
+
+func (t *SPITransport) String() string {
+	if t.Conn == nil {
+		return fmt.Sprintf("SPI(%s, not connected)", t.port)
+	}
+	return fmt.Sprintf("SPI(%s)", t.Conn)
+}
+
+/**
+ * I2C interface, datasheet section 8.1.3.
+ * The register address is sent after the device address, a read is a write
+ * of the register address followed by a repeated START.
+ */
+type I2CTransport struct {
+	Dev *i2c.Dev
+}
+
+/**
+ * addr is the 7 bit device address, MFRC522_I2C_ADDR by default.
+ */
+func NewI2CTransport(bus i2c.Bus, addr uint16) *I2CTransport {
+	return &I2CTransport{Dev: &i2c.Dev{Bus: bus, Addr: addr}}
+}
+
+func (t *I2CTransport) WriteRegister(address byte, values ...byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return t.Dev.Tx(append([]byte{address & 0x3F}, values...), nil)
+}
+
+func (t *I2CTransport) ReadRegister(address byte, values []byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return t.Dev.Tx([]byte{address & 0x3F}, values)
+}
+
+func (t *I2CTransport) String() string {
+	return fmt.Sprintf("I2C(%s)", t.Dev)
+}
+
+/**
+ * UART interface, datasheet section 8.1.4.
+ * Address byte: bit 7 read, bit 6 reserved, bits 5..0 address.
+ * A read returns the data byte, a write is acknowledged by the echo of the
+ * address byte. There is no burst access, every byte is a separate exchange.
+ */
+type UARTTransport struct {
+	Port io.ReadWriter
+}
+
+func NewUARTTransport(port io.ReadWriter) *UARTTransport {
+	return &UARTTransport{Port: port}
+}
+
+func (t *UARTTransport) WriteRegister(address byte, values ...byte) error {
+	addr := address & 0x3F
+	echo := make([]byte, 1)
+	for _, val := range values {
+		if _, err := t.Port.Write([]byte{addr, val}); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(t.Port, echo); err != nil {
+			return err
+		}
+		if echo[0] != addr {
+			return UnexpectedResponse(fmt.Sprintf("UART: unexpected echo %02x, address %02x", echo[0], addr))
+		}
+	}
+	return nil
+}
+
+func (t *UARTTransport) ReadRegister(address byte, values []byte) error {
+	addr := (address & 0x3F) | 0x80
+	for ind := range values {
+		if _, err := t.Port.Write([]byte{addr}); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(t.Port, values[ind:ind+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *UARTTransport) String() string {
+	return "UART"
+}
@@ -0,0 +1,89 @@
+package mfrc522
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/matryer/is"
+	"periph.io/x/periph/conn/physic"
+)
+
+// I2C bus with the simulator at MFRC522_I2C_ADDR
+type simI2CBus struct {
+	sim *MFRC522Simulator
+}
+
+func (b *simI2CBus) String() string                    { return "simI2C" }
+func (b *simI2CBus) SetSpeed(f physic.Frequency) error { return nil }
+
+func (b *simI2CBus) Tx(addr uint16, w, r []byte) error {
+	if addr != MFRC522_I2C_ADDR {
+		return UsageError("no ACK")
+	}
+	address := w[0] & 0x3F
+	if len(r) == 0 {
+		return b.sim.Tx(append([]byte{address << 1}, w[1:]...), nil)
+	}
+	data := make([]byte, len(r)+1)
+	for i := range r {
+		data[i] = address<<1 | 0x80
+	}
+	out := make([]byte, len(data))
+	err := b.sim.Tx(data, out)
+	copy(r, out[1:])
+	return err
+}
+
+// UART line with the simulator, answers are queued until read
+type simUART struct {
+	sim      *MFRC522Simulator
+	pending  []byte
+	response bytes.Buffer
+}
+
+func (u *simUART) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if len(u.pending) == 0 && b&0x80 != 0 {
+			out := make([]byte, 2)
+			if err := u.sim.Tx([]byte{(b&0x3F)<<1 | 0x80, 0}, out); err != nil {
+				return 0, err
+			}
+			u.response.WriteByte(out[1])
+			continue
+		}
+		u.pending = append(u.pending, b)
+		if len(u.pending) == 2 {
+			if err := u.sim.Tx([]byte{u.pending[0] << 1, u.pending[1]}, nil); err != nil {
+				return 0, err
+			}
+			u.response.WriteByte(u.pending[0])
+			u.pending = nil
+		}
+	}
+	return len(p), nil
+}
+
+func (u *simUART) Read(p []byte) (int, error) {
+	return u.response.Read(p)
+}
+
+func TestTransports(t *testing.T) {
+	for name, newTransport := range map[string]func(sim *MFRC522Simulator) Transport{
+		"SPI":  func(sim *MFRC522Simulator) Transport { return &SPITransport{Conn: sim} },
+		"I2C":  func(sim *MFRC522Simulator) Transport { return NewI2CTransport(&simI2CBus{sim}, MFRC522_I2C_ADDR) },
+		"UART": func(sim *MFRC522Simulator) Transport { return NewUARTTransport(&simUART{sim: sim}) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			sim := NewMFRC522Simulator()
+			reader, err := NewMFRC522(newTransport(sim), sim.ResetPin(), sim.IRQPin())
+			is.NoErr(err)
+
+			is.NoErr(reader.PCD_WriteFIFOBuffer([]byte{1, 2, 3}))
+			data, err := reader.PCD_ReadFIFOBuffer(3)
+			is.NoErr(err)
+			is.True(bytes.Compare(data, []byte{1, 2, 3}) == 0)
+			is.NoErr(reader.PCD_PerformSelfTest())
+		})
+	}
+}
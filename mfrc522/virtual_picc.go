@@ -0,0 +1,688 @@
+// Virtual PICC models for testing the driver without cards.
+// Plug a VirtualField into MFRC522Simulator.Field, the driver then talks to
+// the cards through the regular PCD_CommunicateWithPICC path.
+
+package mfrc522
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+)
+
+// VirtualPICC is a card model answering frames of a PCD
+type VirtualPICC interface {
+	// Exchange gets a frame sent by the PCD. ok is false if the PICC stays silent.
+	Exchange(frame Frame) (resp Frame, ok bool)
+	// FieldOff brings the PICC into the POWER-OFF state
+	FieldOff()
+}
+
+// VirtualField holds the PICCs in the RF field. It implements SimField,
+// responses of several PICCs are merged bit by bit like on the air.
+type VirtualField struct {
+	mu     sync.Mutex
+	piccs  []VirtualPICC
+	txRate BitRate // PCD to PICC
+	rxRate BitRate // PICC to PCD
+}
+
+func NewVirtualField(piccs ...VirtualPICC) *VirtualField {
+	return &VirtualField{piccs: piccs}
+}
+
+/**
+ * Puts a PICC into the field.
+ */
+func (f *VirtualField) Add(picc VirtualPICC) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	picc.FieldOff()
+	f.piccs = append(f.piccs, picc)
+}
+
+/**
+ * Takes a PICC out of the field.
+ */
+func (f *VirtualField) Remove(picc VirtualPICC) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, p := range f.piccs {
+		if p == picc {
+			f.piccs = append(f.piccs[:i], f.piccs[i+1:]...)
+			picc.FieldOff()
+			return
+		}
+	}
+}
+
+func (f *VirtualField) FieldOff() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, p := range f.piccs {
+		p.FieldOff()
+	}
+}
+
+func (f *VirtualField) SetBitRate(tx, rx BitRate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.txRate, f.rxRate = tx, rx
+}
+
+// Optionally implemented by VirtualPICC, the PICC only hears frames at its bit rates
+type bitRatePICC interface {
+	bitRates() (dr, ds BitRate)
+}
+
+func (f *VirtualField) Transceive(frame Frame) (Frame, int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var responses []Frame
+	for _, p := range f.piccs {
+		if b, ok := p.(bitRatePICC); ok {
+			if dr, ds := b.bitRates(); dr != f.txRate || ds != f.rxRate {
+				continue
+			}
+		}
+		if resp, ok := p.Exchange(Frame{Data: append([]byte{}, frame.Data...), LastBits: frame.LastBits, Parity: frame.Parity}); ok {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		return Frame{}, -1, false
+	}
+
+	// Bitwise merge, the first bit with different values is a collision
+	result := responses[0]
+	collision := -1
+	for _, resp := range responses[1:] {
+		n := result.BitLen()
+		if resp.BitLen() > n {
+			n = resp.BitLen()
+		}
+		bits := make([]byte, n)
+		for i := 0; i < n; i++ {
+			var a, b byte
+			if i < result.BitLen() {
+				a = result.Bit(i)
+			}
+			if i < resp.BitLen() {
+				b = resp.Bit(i)
+			}
+			if (a != b || i >= result.BitLen() || i >= resp.BitLen()) && (collision < 0 || i < collision) {
+				collision = i
+			}
+			bits[i] = a | b
+		}
+		result = FrameFromBits(bits)
+	}
+	return result, collision, true
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// ISO/IEC 14443-3 type A state machine
+/////////////////////////////////////////////////////////////////////////////////////
+
+type piccState int
+
+const (
+	piccStateIdle piccState = iota
+	piccStateReady
+	piccStateActive
+	piccStateHalt
+)
+
+// Common part of all virtual PICCs: REQA, WUPA, anticollision, select and HLTA
+type iso14443aPICC struct {
+	uid    []byte
+	atqa   []byte // as sent on the air, LSB first
+	sak    byte
+	state  piccState
+	halted bool // return to HALT instead of IDLE
+	level  int  // cascade level in READY state, 0 based
+
+	// Frames received in ACTIVE state
+	active func(frame Frame) (Frame, bool)
+	// Called on leaving ACTIVE state
+	deactivate func()
+	// True if frames in ACTIVE state carry encrypted parity, checked by active
+	encrypted func() bool
+	// Bit rates set by PPS, 106 kbit/s out of ACTIVE state
+	dr, ds BitRate
+}
+
+func (p *iso14443aPICC) bitRates() (dr, ds BitRate) {
+	return p.dr, p.ds
+}
+
+func (p *iso14443aPICC) FieldOff() {
+	p.toIdle(false)
+}
+
+func (p *iso14443aPICC) toIdle(halted bool) {
+	if p.state == piccStateActive && p.deactivate != nil {
+		p.deactivate()
+	}
+	p.halted = halted
+	p.dr, p.ds = BIT_RATE_106, BIT_RATE_106
+	if halted {
+		p.state = piccStateHalt
+	} else {
+		p.state = piccStateIdle
+	}
+	p.level = 0
+}
+
+// Unexpected frame: back to IDLE or HALT
+func (p *iso14443aPICC) fail() (Frame, bool) {
+	p.toIdle(p.halted)
+	return Frame{}, false
+}
+
+func (p *iso14443aPICC) Exchange(frame Frame) (Frame, bool) {
+	if p.state != piccStateActive || p.encrypted == nil || !p.encrypted() {
+		for i := range frame.Parity {
+			if i < len(frame.Data) && frame.Parity[i]&1 != OddParity(frame.Data[i]) {
+				return p.fail()
+			}
+		}
+	}
+
+	if frame.BitLen() == 7 {
+		switch frame.Data[0] & 0x7F {
+		case PICC_CMD_REQA:
+			if p.state != piccStateIdle {
+				return p.fail()
+			}
+		case PICC_CMD_WUPA:
+			if p.state != piccStateIdle && p.state != piccStateHalt {
+				return p.fail()
+			}
+		default:
+			return p.fail()
+		}
+		p.state = piccStateReady
+		p.level = 0
+		return Frame{Data: append([]byte{}, p.atqa...)}, true
+	}
+
+	switch p.state {
+	case piccStateReady:
+		return p.selectLevel(frame)
+	case piccStateActive:
+		if isHLTA(frame) {
+			p.toIdle(true)
+			return Frame{}, false
+		}
+		if p.active != nil {
+			return p.active(frame)
+		}
+		return p.fail()
+	}
+	return Frame{}, false
+}
+
+func isHLTA(frame Frame) bool {
+	return frame.LastBits == 0 && len(frame.Data) == 4 && frame.Data[0] == PICC_CMD_HLTA && frame.Data[1] == 0 &&
+		bytes.Compare(ISO14443aCRC(frame.Data[:2]), frame.Data[2:]) == 0
+}
+
+// UID CLn of the cascade level followed by BCC
+func (p *iso14443aPICC) cascadeLevel(level int) []byte {
+	var cl []byte
+	switch {
+	case len(p.uid) == 4:
+		cl = append(cl, p.uid...)
+	case len(p.uid) == 7 && level == 0:
+		cl = append([]byte{PICC_CMD_CT}, p.uid[:3]...)
+	case len(p.uid) == 7:
+		cl = append(cl, p.uid[3:]...)
+	case level < 2:
+		cl = append([]byte{PICC_CMD_CT}, p.uid[level*3:level*3+3]...)
+	default:
+		cl = append(cl, p.uid[6:]...)
+	}
+	return append(cl, cl[0]^cl[1]^cl[2]^cl[3])
+}
+
+func (p *iso14443aPICC) lastLevel() int {
+	switch len(p.uid) {
+	case 4:
+		return 0
+	case 7:
+		return 1
+	}
+	return 2
+}
+
+func (p *iso14443aPICC) selectLevel(frame Frame) (Frame, bool) {
+	selByte := []byte{PICC_CMD_SEL_CL1, PICC_CMD_SEL_CL2, PICC_CMD_SEL_CL3}[p.level]
+	if frame.BitLen() < 16 || frame.Data[0] != selByte {
+		return p.fail()
+	}
+	nvb := frame.Data[1]
+	cl := p.cascadeLevel(p.level)
+
+	// SELECT
+	if nvb == 0x70 {
+		if frame.BitLen() != 9*8 || bytes.Compare(ISO14443aCRC(frame.Data[:7]), frame.Data[7:]) != 0 {
+			return p.fail()
+		}
+		if bytes.Compare(frame.Data[2:7], cl) != 0 {
+			return Frame{}, false // another PICC is selected, stay in READY
+		}
+		sak := p.sak
+		if p.level < p.lastLevel() {
+			sak = 0x04 // Cascade bit, UID not complete
+			p.level++
+		} else {
+			p.state = piccStateActive
+		}
+		return Frame{Data: append([]byte{sak}, ISO14443aCRC([]byte{sak})...)}, true
+	}
+
+	// ANTICOLLISION: NVB is the number of valid bits sent including SEL and NVB
+	known := int(nvb>>4-2)*8 + int(nvb&0x0F)
+	if known < 0 || known >= 40 || frame.BitLen() != 16+known {
+		return p.fail()
+	}
+	clFrame := Frame{Data: cl}
+	for i := 0; i < known; i++ {
+		if frame.Bit(16+i) != clFrame.Bit(i) {
+			return Frame{}, false // UID doesn't match, stay silent in READY
+		}
+	}
+	bits := make([]byte, 0, 40-known)
+	for i := known; i < 40; i++ {
+		bits = append(bits, clFrame.Bit(i))
+	}
+	return FrameFromBits(bits), true
+}
+
+// Checks and strips CRC_A
+func checkCRC(data []byte) ([]byte, bool) {
+	if len(data) < 3 || bytes.Compare(ISO14443aCRC(data[:len(data)-2]), data[len(data)-2:]) != 0 {
+		return nil, false
+	}
+	return data[:len(data)-2], true
+}
+
+func withCRC(data []byte) Frame {
+	return Frame{Data: append(append([]byte{}, data...), ISO14443aCRC(data)...)}
+}
+
+func ackFrame(ack byte) Frame {
+	return Frame{Data: []byte{ack & 0x0F}, LastBits: 4}
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// MIFARE Classic 1K
+/////////////////////////////////////////////////////////////////////////////////////
+
+// VirtualMifareClassic is a MIFARE Classic 1K with 4 byte UID. The card side of
+// the Crypto1 authentication is real, access conditions are not checked.
+type VirtualMifareClassic struct {
+	iso14443aPICC
+	Blocks [64][16]byte
+	Nonce  func() uint32 // tag nonce generator, random PRNG values by default
+
+	crypto       *Crypto1
+	nt           uint32
+	authSector   int
+	waitReader   bool // nt sent, waiting for {nr}{ar}
+	pendingWrite int  // block of a WRITE waiting for data, -1 if none
+}
+
+func NewVirtualMifareClassic1K(uid []byte) *VirtualMifareClassic {
+	c := &VirtualMifareClassic{
+		iso14443aPICC: iso14443aPICC{uid: append([]byte{}, uid[:4]...), atqa: []byte{0x04, 0x00}, sak: 0x08},
+		pendingWrite:  -1,
+	}
+	c.active = c.handle
+	c.deactivate = c.resetAuth
+	c.encrypted = func() bool { return c.crypto != nil }
+
+	// Manufacturer block
+	copy(c.Blocks[0][:], uid[:4])
+	c.Blocks[0][4] = uid[0] ^ uid[1] ^ uid[2] ^ uid[3]
+	c.Blocks[0][5] = c.sak
+	copy(c.Blocks[0][6:], c.atqa)
+	// Transport configuration of the sector trailers
+	for sector := 0; sector < 16; sector++ {
+		copy(c.Blocks[sector*4+3][:], []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+			0xFF, 0x07, 0x80, 0x69, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+	}
+	return c
+}
+
+func (c *VirtualMifareClassic) resetAuth() {
+	c.crypto = nil
+	c.waitReader = false
+	c.pendingWrite = -1
+}
+
+func (c *VirtualMifareClassic) nonce() uint32 {
+	if c.Nonce != nil {
+		return c.Nonce()
+	}
+	return PRNGSuccessor(uint32(rand.Intn(0x10000)), 16)
+}
+
+func (c *VirtualMifareClassic) encrypt(resp Frame) Frame {
+	if c.crypto == nil {
+		return resp
+	}
+	if resp.LastBits == 4 {
+		resp.Data[0] ^= c.crypto.Bits(4)
+		return resp
+	}
+	resp.Parity = c.crypto.Crypt(resp.Data, false)
+	return resp
+}
+
+// Encrypted frames carry encrypted parity bits, a PICC doesn't answer a parity error
+func parityMatches(frame Frame, parity []byte) bool {
+	for i := range parity {
+		if frame.ParityBit(i) != parity[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *VirtualMifareClassic) handle(frame Frame) (Frame, bool) {
+	data := append([]byte{}, frame.Data...)
+
+	if c.waitReader {
+		c.waitReader = false
+		if len(data) != 8 || frame.LastBits != 0 {
+			return c.fail()
+		}
+		// {nr} is fed into the LFSR, {ar} is encrypted with the keystream only
+		parity := make([]byte, 8)
+		for i := 0; i < 4; i++ {
+			data[i] ^= c.crypto.Byte(data[i], true)
+			parity[i] = OddParity(data[i]) ^ c.crypto.ParityBit()
+		}
+		copy(parity[4:], c.crypto.Crypt(data[4:], true))
+		if !parityMatches(frame, parity) || bytesToUint32(data[4:]) != PRNGSuccessor(c.nt, 64) {
+			return c.fail()
+		}
+		at := uint32ToBytes(PRNGSuccessor(c.nt, 96))
+		return Frame{Data: at, Parity: c.crypto.Crypt(at, false)}, true
+	}
+
+	if c.crypto != nil {
+		if !parityMatches(frame, c.crypto.Crypt(data, true)) {
+			return c.fail()
+		}
+	}
+	cmd, ok := checkCRC(data)
+	if !ok {
+		return c.encrypt(ackFrame(MF_NAK1)), true
+	}
+
+	if c.pendingWrite >= 0 {
+		block := c.pendingWrite
+		c.pendingWrite = -1
+		if len(cmd) != 16 {
+			return c.encrypt(ackFrame(MF_NAK)), true
+		}
+		copy(c.Blocks[block][:], cmd)
+		return c.encrypt(ackFrame(MF_ACK)), true
+	}
+
+	switch {
+	case len(cmd) == 2 && (cmd[0] == PICC_CMD_MF_AUTH_KEY_A || cmd[0] == PICC_CMD_MF_AUTH_KEY_B):
+		if int(cmd[1]) >= len(c.Blocks) {
+			return c.fail()
+		}
+		sector := int(cmd[1]) / 4
+		trailer := c.Blocks[sector*4+3]
+		var key [6]byte
+		copy(key[:], trailer[:6])
+		if cmd[0] == PICC_CMD_MF_AUTH_KEY_B {
+			copy(key[:], trailer[10:])
+		}
+		c.nt = c.nonce()
+		crypto := NewCrypto1(key)
+		nt := uint32ToBytes(c.nt)
+		resp := Frame{Data: append([]byte{}, nt...), Parity: make([]byte, 4)}
+		for i, b := range uint32ToBytes(bytesToUint32(c.uid) ^ c.nt) {
+			ks := crypto.Byte(b, false)
+			resp.Parity[i] = OddParity(nt[i]) ^ crypto.ParityBit()
+			if c.crypto != nil { // nested authentication, nt goes encrypted
+				resp.Data[i] ^= ks
+			}
+		}
+		if c.crypto == nil {
+			resp.Parity = nil
+		}
+		c.crypto = crypto
+		c.authSector = sector
+		c.waitReader = true
+		return resp, true
+
+	case len(cmd) == 2 && cmd[0] == PICC_CMD_MF_READ:
+		if c.crypto == nil || int(cmd[1])/4 != c.authSector {
+			return c.encrypt(ackFrame(MF_NAK)), true
+		}
+		block := c.Blocks[cmd[1]]
+		return c.encrypt(withCRC(block[:])), true
+
+	case len(cmd) == 2 && cmd[0] == PICC_CMD_MF_WRITE:
+		if c.crypto == nil || int(cmd[1])/4 != c.authSector || cmd[1] == 0 {
+			return c.encrypt(ackFrame(MF_NAK)), true
+		}
+		c.pendingWrite = int(cmd[1])
+		return c.encrypt(ackFrame(MF_ACK)), true
+
+	case len(cmd) == 2 && cmd[0] == PICC_CMD_HLTA && cmd[1] == 0:
+		c.toIdle(true)
+		return Frame{}, false
+	}
+	return c.encrypt(ackFrame(MF_NAK)), true
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// MIFARE Ultralight / NTAG21x
+/////////////////////////////////////////////////////////////////////////////////////
+
+// VirtualUltralight is a MIFARE Ultralight or NTAG21x with 7 byte UID
+type VirtualUltralight struct {
+	iso14443aPICC
+	Pages   [][4]byte
+	Version []byte // GET_VERSION response, nil for MIFARE Ultralight
+
+	pendingWrite int // page of a COMPATIBILITY WRITE waiting for data, -1 if none
+}
+
+/**
+ * MIFARE Ultralight, 16 pages.
+ */
+func NewVirtualUltralight(uid []byte) *VirtualUltralight {
+	return newVirtualUltralight(uid, 16, nil)
+}
+
+/**
+ * NTAG213, 45 pages with an empty NDEF capability container.
+ */
+func NewVirtualNTAG213(uid []byte) *VirtualUltralight {
+	ul := newVirtualUltralight(uid, 45, []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x0F, 0x03})
+	ul.Pages[3] = [4]byte{0xE1, 0x10, 0x12, 0x00} // CC: NDEF 1.0, 144 bytes, read/write
+	ul.Pages[4] = [4]byte{0x03, 0x00, 0xFE, 0x00} // empty NDEF message TLV, terminator
+	return ul
+}
+
+func newVirtualUltralight(uid []byte, pages int, version []byte) *VirtualUltralight {
+	ul := &VirtualUltralight{
+		iso14443aPICC: iso14443aPICC{uid: append([]byte{}, uid[:7]...), atqa: []byte{0x44, 0x00}, sak: 0x00},
+		Pages:         make([][4]byte, pages),
+		Version:       version,
+		pendingWrite:  -1,
+	}
+	ul.active = ul.handle
+	// Serial number pages with BCC0 and BCC1
+	ul.Pages[0] = [4]byte{uid[0], uid[1], uid[2], PICC_CMD_CT ^ uid[0] ^ uid[1] ^ uid[2]}
+	ul.Pages[1] = [4]byte{uid[3], uid[4], uid[5], uid[6]}
+	ul.Pages[2][0] = uid[3] ^ uid[4] ^ uid[5] ^ uid[6]
+	return ul
+}
+
+func (ul *VirtualUltralight) handle(frame Frame) (Frame, bool) {
+	cmd, ok := checkCRC(frame.Data)
+	if !ok || frame.LastBits != 0 {
+		return ackFrame(MF_NAK1), true
+	}
+
+	if ul.pendingWrite >= 0 {
+		page := ul.pendingWrite
+		ul.pendingWrite = -1
+		if len(cmd) != 16 {
+			return ackFrame(MF_NAK), true
+		}
+		copy(ul.Pages[page][:], cmd[:4])
+		return ackFrame(MF_ACK), true
+	}
+
+	switch {
+	case len(cmd) == 2 && cmd[0] == PICC_CMD_MF_READ:
+		if int(cmd[1]) >= len(ul.Pages) {
+			return ackFrame(MF_NAK), true
+		}
+		// 4 pages, rolls over to page 0
+		result := make([]byte, 0, 16)
+		for i := 0; i < 4; i++ {
+			page := ul.Pages[(int(cmd[1])+i)%len(ul.Pages)]
+			result = append(result, page[:]...)
+		}
+		return withCRC(result), true
+
+	case len(cmd) == 6 && cmd[0] == PICC_CMD_UL_WRITE:
+		if cmd[1] < 2 || int(cmd[1]) >= len(ul.Pages) {
+			return ackFrame(MF_NAK), true
+		}
+		copy(ul.Pages[cmd[1]][:], cmd[2:])
+		return ackFrame(MF_ACK), true
+
+	case len(cmd) == 2 && cmd[0] == PICC_CMD_MF_WRITE:
+		if cmd[1] < 2 || int(cmd[1]) >= len(ul.Pages) {
+			return ackFrame(MF_NAK), true
+		}
+		ul.pendingWrite = int(cmd[1])
+		return ackFrame(MF_ACK), true
+
+	case len(cmd) == 1 && cmd[0] == PICC_CMD_GET_VERSION && ul.Version != nil:
+		return withCRC(ul.Version), true
+	}
+	return ackFrame(MF_NAK), true
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// ISO/IEC 14443-4
+/////////////////////////////////////////////////////////////////////////////////////
+
+// APDUHandler returns the response APDU for a command APDU
+type APDUHandler func(apdu []byte) []byte
+
+// VirtualISO14443_4 is an ISO/IEC 14443-4 PICC. Command APDUs of I-blocks are
+// passed to Handler. CID and NAD are not supported.
+type VirtualISO14443_4 struct {
+	iso14443aPICC
+	ATS     []byte // without CRC
+	Handler APDUHandler
+
+	layer4    bool   // RATS received
+	pps       bool   // PPS is allowed, only the first block after ATS
+	blockNum  byte   // PICC block number
+	chained   []byte // received chained I-blocks
+	lastBlock []byte // last sent block without CRC
+}
+
+func NewVirtualISO14443_4(uid []byte, handler APDUHandler) *VirtualISO14443_4 {
+	p := &VirtualISO14443_4{
+		iso14443aPICC: iso14443aPICC{uid: append([]byte{}, uid...), atqa: []byte{0x04, 0x00}, sak: 0x20},
+		// TL T0 TA TB TC: FSCI 8 (256 bytes), 106 kbit/s only, FWI 8 SFGI 1, CID not supported
+		ATS:     []byte{0x05, 0x78, 0x80, 0x81, 0x00},
+		Handler: handler,
+	}
+	if len(uid) == 7 {
+		p.atqa = []byte{0x44, 0x00}
+	}
+	p.active = p.handle
+	p.deactivate = func() {
+		p.layer4 = false
+		p.blockNum = 0
+		p.chained = nil
+		p.lastBlock = nil
+	}
+	return p
+}
+
+func (p *VirtualISO14443_4) send(block []byte) (Frame, bool) {
+	p.lastBlock = block
+	return withCRC(block), true
+}
+
+func (p *VirtualISO14443_4) handle(frame Frame) (Frame, bool) {
+	block, ok := checkCRC(frame.Data)
+	if !ok || frame.LastBits != 0 {
+		return Frame{}, false // transmission error, the PCD has to retry
+	}
+
+	if !p.layer4 {
+		if len(block) == 2 && block[0] == PICC_CMD_RATS {
+			p.layer4 = true
+			p.pps = true
+			p.blockNum = 1 // the first I-block of the PCD has block number 0
+			return withCRC(p.ATS), true
+		}
+		return p.fail()
+	}
+
+	pcb := block[0]
+	pps := p.pps
+	p.pps = false
+	if pps && len(block) == 3 && pcb == PICC_CMD_PPS && block[1] == PPS_PPS0_PPS1 {
+		ats, err := ParseATS(p.ATS)
+		dr, ds := BitRate(block[2]&0x03), BitRate(block[2]>>2&0x03)
+		if err != nil || !supportsBitRate(ats.DR, dr) || !supportsBitRate(ats.DS, ds) || ats.SameD && dr != ds {
+			return Frame{}, false
+		}
+		// The answer goes at the old bit rate
+		p.dr, p.ds = dr, ds
+		return withCRC([]byte{PICC_CMD_PPS}), true
+	}
+
+	switch {
+	case pcb&0xC2 == 0x02: // I-block
+		if pcb&0x0C != 0 {
+			return Frame{}, false // CID or NAD
+		}
+		p.blockNum = pcb & 0x01
+		p.chained = append(p.chained, block[1:]...)
+		if pcb&0x10 != 0 { // chaining, R(ACK)
+			return p.send([]byte{0xA2 | p.blockNum})
+		}
+		apdu := p.chained
+		p.chained = nil
+		var resp []byte
+		if p.Handler != nil {
+			resp = p.Handler(apdu)
+		}
+		return p.send(append([]byte{0x02 | p.blockNum}, resp...))
+
+	case pcb&0xE6 == 0xA2: // R-block
+		if pcb&0x10 != 0 && pcb&0x01 == p.blockNum && p.lastBlock != nil { // R(NAK) for the last block
+			return p.send(p.lastBlock)
+		}
+		return p.send([]byte{0xA2 | p.blockNum}) // R(ACK)
+
+	case pcb&0xF7 == 0xC2: // S(DESELECT)
+		p.toIdle(true)
+		return withCRC([]byte{0xC2}), true
+	}
+	return Frame{}, false
+}
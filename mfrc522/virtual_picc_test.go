@@ -0,0 +1,221 @@
+package mfrc522
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+var defaultKey = []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// MFRC522 driver -> MFRC522Simulator -> VirtualField -> PICCs
+func newVirtualReader(t *testing.T, piccs ...VirtualPICC) (*MFRC522, *VirtualField) {
+	sim := NewMFRC522Simulator()
+	field := NewVirtualField(piccs...)
+	sim.Field = field
+	reader := newSimulatedMFRC522(t, sim)
+	if err := reader.PCD_Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.PCD_AntennaOn(); err != nil {
+		t.Fatal(err)
+	}
+	return reader, field
+}
+
+func transceive(reader *MFRC522, data []byte) ([]byte, error) {
+	validBits := byte(0)
+	return reader.PCD_CommunicateWithPICC(PCD_Transceive, data, &validBits, INTERUPT_TIMEOUT)
+}
+
+func TestVirtualClassicSelect(t *testing.T) {
+	is := is.New(t)
+	uid := []byte{0x9c, 0x59, 0x9b, 0x32}
+	reader, _ := newVirtualReader(t, NewVirtualMifareClassic1K(uid))
+
+	is.True(reader.PICC_IsNewCardPresent())
+	selected, err := reader.PICC_Select()
+	is.NoErr(err)
+	is.True(bytes.Compare(selected.Uid, uid) == 0)
+	is.Equal(selected.Sak, byte(0x08))
+	is.Equal(selected.PicType, PICC_TYPE_MIFARE_1K)
+
+	// HLTA: REQA is ignored, WUPA wakes the PICC up
+	_, err = transceive(reader, append([]byte{PICC_CMD_HLTA, 0x00}, ISO14443aCRC([]byte{PICC_CMD_HLTA, 0x00})...))
+	is.True(err != nil) // no answer means success
+	is.True(!reader.PICC_IsNewCardPresent())
+	atqa, err := reader.PICC_RequestWUPA()
+	is.NoErr(err)
+	is.True(bytes.Compare(atqa, []byte{0x04, 0x00}) == 0)
+}
+
+func TestVirtualClassicAuthentication(t *testing.T) {
+	is := is.New(t)
+	card := NewVirtualMifareClassic1K([]byte{0x9c, 0x59, 0x9b, 0x32})
+	reader, _ := newVirtualReader(t, card)
+
+	is.True(reader.PICC_IsNewCardPresent())
+	uid, err := reader.PICC_Select()
+	is.NoErr(err)
+	is.NoErr(reader.PICC_AuthentificateKeyA(*uid, defaultKey, 4))
+
+	// Wrong key: the card doesn't answer {nr}{ar}
+	card.Blocks[7][0] = 0x00
+	is.True(reader.PICC_AuthentificateKeyA(*uid, defaultKey, 4) != nil)
+}
+
+func TestClassicAuthenticationKeyLength(t *testing.T) {
+	is := is.New(t)
+	reader, _ := newVirtualReader(t, NewVirtualMifareClassic1K([]byte{0x9c, 0x59, 0x9b, 0x32}))
+	is.True(reader.PICC_IsNewCardPresent())
+	uid, err := reader.PICC_Select()
+	is.NoErr(err)
+
+	// Rejected before AUTH is sent
+	recorder := NewTraceRecorder(0)
+	reader.SetTracer(recorder)
+	for _, key := range [][]byte{defaultKey[:5], make([]byte, 16)} {
+		err = reader.PICC_AuthentificateKeyA(*uid, key, 4)
+		is.True(errors.Is(err, ErrUsage))
+	}
+	is.Equal(len(recorder.Frames()), 0)
+	is.NoErr(reader.PICC_AuthentificateKeyA(*uid, defaultKey, 4))
+}
+
+func TestVirtualClassicEncrypted(t *testing.T) {
+	is := is.New(t)
+	card := NewVirtualMifareClassic1K([]byte{0x9c, 0x59, 0x9b, 0x32})
+	reader, _ := newVirtualReader(t, card)
+
+	is.True(reader.PICC_IsNewCardPresent())
+	uid, err := reader.PICC_Select()
+	is.NoErr(err)
+	is.NoErr(reader.PICC_AuthentificateKeyA(*uid, defaultKey, 4))
+
+	// Commands, data, parity and the 4 bit ACK are encrypted
+	data := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	is.NoErr(reader.MIFARE_Write(5, data))
+	is.True(bytes.Compare(card.Blocks[5][:], data) == 0)
+	read, err := reader.MIFARE_Read(5)
+	is.NoErr(err)
+	is.True(bytes.Compare(read, data) == 0)
+
+	// Nested authentication of another sector
+	is.NoErr(reader.PICC_AuthentificateKeyA(*uid, defaultKey, 8))
+	read, err = reader.MIFARE_Read(8)
+	is.NoErr(err)
+	is.True(bytes.Compare(read, card.Blocks[8][:]) == 0)
+
+	// The encrypted HLTA is accepted
+	is.NoErr(reader.PICC_HaltA())
+	is.True(!reader.PICC_IsNewCardPresent())
+}
+
+func TestVirtualUltralight(t *testing.T) {
+	is := is.New(t)
+	uid := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	reader, _ := newVirtualReader(t, NewVirtualNTAG213(uid))
+
+	is.True(reader.PICC_IsNewCardPresent())
+	selected, err := reader.PICC_Select()
+	is.NoErr(err)
+	is.True(bytes.Compare(selected.Uid, uid) == 0)
+	is.Equal(selected.PicType, PICC_TYPE_MIFARE_UL)
+
+	// READ page 0: UID0-2 BCC0 UID3-6 BCC1 ...
+	cmd := []byte{PICC_CMD_MF_READ, 0x00}
+	data, err := transceive(reader, append(cmd, ISO14443aCRC(cmd)...))
+	is.NoErr(err)
+	is.Equal(len(data), 18)
+	is.True(bytes.Compare(data[:3], uid[:3]) == 0)
+	is.True(bytes.Compare(data[4:8], uid[3:]) == 0)
+	is.True(bytes.Compare(data[16:], ISO14443aCRC(data[:16])) == 0)
+
+	// WRITE page 5 and read it back
+	cmd = []byte{PICC_CMD_UL_WRITE, 0x05, 0xde, 0xad, 0xbe, 0xef}
+	ack, err := transceive(reader, append(cmd, ISO14443aCRC(cmd)...))
+	is.NoErr(err)
+	is.True(bytes.Compare(ack, []byte{MF_ACK}) == 0)
+	cmd = []byte{PICC_CMD_MF_READ, 0x05}
+	data, err = transceive(reader, append(cmd, ISO14443aCRC(cmd)...))
+	is.NoErr(err)
+	is.True(bytes.Compare(data[:4], []byte{0xde, 0xad, 0xbe, 0xef}) == 0)
+}
+
+func TestVirtualISO14443_4(t *testing.T) {
+	is := is.New(t)
+	selectAID := []byte{0x00, 0xA4, 0x04, 0x00, 0x07, 0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01, 0x00}
+	card := NewVirtualISO14443_4([]byte{0x08, 0x12, 0x34, 0x56}, func(apdu []byte) []byte {
+		if bytes.Compare(apdu, selectAID) == 0 {
+			return []byte{0x90, 0x00}
+		}
+		return []byte{0x6A, 0x82}
+	})
+	reader, _ := newVirtualReader(t, card)
+
+	is.True(reader.PICC_IsNewCardPresent())
+	selected, err := reader.PICC_Select()
+	is.NoErr(err)
+	is.Equal(selected.PicType, PICC_TYPE_ISO_14443_4)
+
+	cmd := []byte{PICC_CMD_RATS, 0x80}
+	ats, err := transceive(reader, append(cmd, ISO14443aCRC(cmd)...))
+	is.NoErr(err)
+	is.True(bytes.Compare(ats[:len(ats)-2], card.ATS) == 0)
+
+	block := append([]byte{0x02}, selectAID...)
+	resp, err := transceive(reader, append(block, ISO14443aCRC(block)...))
+	is.NoErr(err)
+	is.True(bytes.Compare(resp[:len(resp)-2], []byte{0x02, 0x90, 0x00}) == 0)
+
+	block = []byte{0x03, 0x00, 0xB0, 0x00, 0x00, 0x00}
+	resp, err = transceive(reader, append(block, ISO14443aCRC(block)...))
+	is.NoErr(err)
+	is.True(bytes.Compare(resp[:len(resp)-2], []byte{0x03, 0x6A, 0x82}) == 0)
+}
+
+func TestVirtualFieldAnticollision(t *testing.T) {
+	is := is.New(t)
+	first := NewVirtualMifareClassic1K([]byte{0x11, 0x22, 0x33, 0x44})
+	second := NewVirtualMifareClassic1K([]byte{0x11, 0x22, 0x37, 0x44})
+	reader, field := newVirtualReader(t, first, second)
+
+	// Same ATQA, no collision
+	is.True(reader.PICC_IsNewCardPresent())
+
+	// UID CL1 collides in bit 18
+	resp, collision, ok := field.Transceive(Frame{Data: []byte{PICC_CMD_SEL_CL1, 0x20}})
+	is.True(ok)
+	is.Equal(collision, 18)
+	is.Equal(resp.BitLen(), 40)
+
+	// Known bits select one of the PICCs: 16 bits of UID and the bit 18 set to 1
+	resp, collision, ok = field.Transceive(Frame{Data: []byte{PICC_CMD_SEL_CL1, 0x43, 0x11, 0x22, 0x07}, LastBits: 3})
+	is.True(ok)
+	is.Equal(collision, -1)
+	is.Equal(resp.BitLen(), 40-19)
+
+	// The driver resolves the collision: the PICC with bit 18 set to 1 first
+	field.FieldOff()
+	is.True(reader.PICC_IsNewCardPresent())
+	selected, err := reader.PICC_Select()
+	is.NoErr(err)
+	is.True(bytes.Compare(selected.Uid, []byte{0x11, 0x22, 0x37, 0x44}) == 0)
+
+	// The halted PICC doesn't answer REQA, the other one is selected now
+	is.NoErr(reader.PICC_HaltA())
+	is.True(reader.PICC_IsNewCardPresent())
+	selected, err = reader.PICC_Select()
+	is.NoErr(err)
+	is.True(bytes.Compare(selected.Uid, []byte{0x11, 0x22, 0x33, 0x44}) == 0)
+
+	// Only one PICC is left
+	field.Remove(second)
+	field.FieldOff()
+	is.True(reader.PICC_IsNewCardPresent())
+	selected, err = reader.PICC_Select()
+	is.NoErr(err)
+	is.True(bytes.Compare(selected.Uid, []byte{0x11, 0x22, 0x33, 0x44}) == 0)
+}
@@ -0,0 +1,163 @@
+// Presence of a card on one reader.
+
+package mfrc522
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type PresenceEventType int
+
+const (
+	CardArrived PresenceEventType = iota
+	CardRemoved
+	CardSwapped // another card replaced the card before its removal was detected
+)
+
+func (t PresenceEventType) String() string {
+	switch t {
+	case CardArrived:
+		return "CardArrived"
+	case CardRemoved:
+		return "CardRemoved"
+	case CardSwapped:
+		return "CardSwapped"
+	}
+	return fmt.Sprintf("PresenceEventType(%d)", int(t))
+}
+
+type PresenceEvent struct {
+	Type     PresenceEventType
+	UID      *UID // the arrived card, the removed card for CardRemoved
+	Previous *UID // the replaced card for CardSwapped
+	Time     time.Time
+}
+
+const (
+	WATCHER_POLL_INTERVAL   = 50 * time.Millisecond
+	WATCHER_REMOVAL_TIMEOUT = 100 * time.Millisecond
+)
+
+/**
+ * Watcher polls with WUPA and selects the card every PollInterval.
+ * WUPA also wakes up the halted card, so the same card answers every poll.
+ */
+type Watcher struct {
+	PollInterval   time.Duration
+	Debounce       time.Duration // a new card is reported when it was seen for this duration
+	RemovalTimeout time.Duration // the card is removed when it wasn't seen for this duration
+
+	reader         *MFRC522
+	current        *UID
+	lastSeen       time.Time
+	candidate      *UID
+	candidateSince time.Time
+}
+
+func NewWatcher(reader *MFRC522) *Watcher {
+	return &Watcher{
+		PollInterval:   WATCHER_POLL_INTERVAL,
+		RemovalTimeout: WATCHER_REMOVAL_TIMEOUT,
+		reader:         reader,
+	}
+}
+
+/**
+ * Polls until ctx is done and returns ctx.Err(). The antenna must be on.
+ * A failure of the reader or of its transport ends Run with the error,
+ * a card error only makes the poll inconclusive.
+ */
+func (w *Watcher) Run(ctx context.Context, events chan<- PresenceEvent) error {
+	for {
+		uid, err := w.detect(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && !isCardError(err) {
+			return err
+		}
+		if err != nil {
+			w.log("watcher: inconclusive poll", "err", err)
+		} else {
+			for _, event := range w.update(uid, time.Now()) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.PollInterval):
+		}
+	}
+}
+
+// The logger is set under the lock of the reader
+func (w *Watcher) log(msg string, keyvals ...interface{}) {
+	w.reader.lock()
+	defer w.reader.unlock()
+	w.reader.logger.Log(LOG_DEBUG, msg, keyvals...)
+}
+
+/**
+ * Returns the card in the field, nil if there is none.
+ * A card error (collision, CRC error, ...) makes the poll inconclusive.
+ */
+func (w *Watcher) detect(ctx context.Context) (*UID, error) {
+	session, err := w.reader.AcquireSession(ctx)
+	if errors.Is(err, ErrTimeout) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	uid := session.UID
+	session.Release()
+	return uid, nil
+}
+
+/**
+ * Updates the state with the result of a poll at now and returns the events.
+ */
+func (w *Watcher) update(uid *UID, now time.Time) []PresenceEvent {
+	if uid == nil {
+		w.candidate = nil
+		if w.current != nil && now.Sub(w.lastSeen) >= w.RemovalTimeout {
+			removed := w.current
+			w.current = nil
+			return []PresenceEvent{{Type: CardRemoved, UID: removed, Time: now}}
+		}
+		return nil
+	}
+
+	if w.current != nil && bytes.Compare(uid.Uid, w.current.Uid) == 0 {
+		w.lastSeen = now
+		w.candidate = nil
+		return nil
+	}
+
+	if w.candidate == nil || bytes.Compare(uid.Uid, w.candidate.Uid) != 0 {
+		w.candidate = uid
+		w.candidateSince = now
+	}
+	if now.Sub(w.candidateSince) < w.Debounce {
+		return nil
+	}
+
+	event := PresenceEvent{Type: CardArrived, UID: uid, Time: now}
+	if w.current != nil {
+		event.Type = CardSwapped
+		event.Previous = w.current
+	}
+	w.current = uid
+	w.lastSeen = now
+	w.candidate = nil
+	return []PresenceEvent{event}
+}
@@ -0,0 +1,96 @@
+package mfrc522
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWatcherUpdate(t *testing.T) {
+	is := is.New(t)
+	w := &Watcher{Debounce: 20 * time.Millisecond, RemovalTimeout: 50 * time.Millisecond}
+	first := &UID{Uid: []byte{1, 2, 3, 4}}
+	second := &UID{Uid: []byte{5, 6, 7, 8}}
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	is.Equal(len(w.update(first, at(0))), 0) // debounce
+	is.Equal(len(w.update(nil, at(10))), 0)
+	is.Equal(len(w.update(first, at(20))), 0) // debounce restarts
+	events := w.update(first, at(40))
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Type, CardArrived)
+
+	// Missed polls shorter than the removal timeout
+	is.Equal(len(w.update(nil, at(60))), 0)
+	is.Equal(len(w.update(first, at(80))), 0)
+
+	// Swapped without removal
+	is.Equal(len(w.update(second, at(90))), 0)
+	events = w.update(second, at(110))
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Type, CardSwapped)
+	is.Equal(events[0].Previous, first)
+	is.Equal(events[0].UID, second)
+
+	is.Equal(len(w.update(nil, at(150))), 0)
+	events = w.update(nil, at(160))
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Type, CardRemoved)
+	is.Equal(events[0].UID, second)
+}
+
+func TestWatcherRun(t *testing.T) {
+	is := is.New(t)
+	uid := []byte{0x11, 0x22, 0x33, 0x44}
+	card := NewVirtualMifareClassic1K(uid)
+	reader, field := newVirtualReader(t, card)
+
+	w := NewWatcher(reader)
+	w.PollInterval = time.Millisecond
+	w.RemovalTimeout = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan PresenceEvent)
+	done := make(chan error)
+	go func() { done <- w.Run(ctx, events) }()
+
+	event := <-events
+	is.Equal(event.Type, CardArrived)
+	is.True(bytes.Compare(event.UID.Uid, uid) == 0)
+
+	// Lift the card, the reader isn't used meanwhile
+	reader.lock()
+	field.Remove(card)
+	reader.unlock()
+	event = <-events
+	is.Equal(event.Type, CardRemoved)
+
+	cancel()
+	is.Equal(<-done, context.Canceled)
+}
+
+func TestWatcherReaderFailure(t *testing.T) {
+	is := is.New(t)
+	sim := NewMFRC522Simulator()
+	sim.Field = NewVirtualField()
+	spi, err := NewSPITransport(sim)
+	is.NoErr(err)
+	transport := &breakingTransport{Transport: spi}
+	reader, err := NewMFRC522(transport, sim.ResetPin(), sim.IRQPin())
+	is.NoErr(err)
+	is.NoErr(reader.PCD_Init())
+	is.NoErr(reader.PCD_AntennaOn())
+	transport.broken = true
+
+	// The transport error ends Run, the kiosk doesn't wait for CardRemoved forever
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = NewWatcher(reader).Run(ctx, make(chan PresenceEvent))
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "SPI transfer failed"))
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	_ "bytes"
+	"context"
 	_ "encoding/binary"
+	"flag"
 	_ "fmt"
+	"io"
 	"log"
 	"os"
 	"rfidreader/mfrc522"
+	"strings"
 	"time"
 
 	"periph.io/x/periph/conn/gpio/gpioreg"
@@ -19,24 +23,59 @@ const (
 	RST = "GPIO25"
 )
 
+var (
+	configPath = flag.String("config", "", "reader configuration file, see reader.json.sample")
+	diagnose   = flag.Bool("diagnose", false, "print the hardware diagnostics report and exit")
+	jsonOutput = flag.Bool("json", false, "diagnostics report in JSON")
+	tracePath  = flag.String("trace", "", "frame trace file: pcapng for Wireshark if it ends with .pcapng, text otherwise")
+)
+
 func run() int {
 
 	if _, err := host.Init(); err != nil {
 		log.Printf(err.Error())
 		return 1
 	}
-	// Use spireg SPI port registry to find the first available SPI bus.
-	spiPort, err := spireg.Open("")
+
+	mfrc522dev, closer, err := open()
 	if err != nil {
 		log.Printf(err.Error())
 		return 1
 	}
-	defer spiPort.Close()
+	defer closer.Close()
 
-	rstPin := gpioreg.ByName(RST)
-	irqPin := gpioreg.ByName(IRQ)
+	if *tracePath != "" {
+		file, err := os.Create(*tracePath)
+		if err != nil {
+			log.Printf(err.Error())
+			return 1
+		}
+		defer file.Close()
+		if strings.HasSuffix(*tracePath, ".pcapng") {
+			recorder := mfrc522.NewTraceRecorder(0)
+			mfrc522dev.SetTracer(recorder)
+			defer recorder.WritePcapng(file)
+		} else {
+			mfrc522dev.SetTracer(mfrc522.NewTextTracer(file))
+		}
+	}
 
-	mfrc522dev, err := mfrc522.NewMFRC522(spiPort, rstPin, irqPin)
+	if *diagnose {
+		report, err := mfrc522dev.PCD_Diagnostics(context.Background())
+		if err != nil {
+			log.Printf(err.Error())
+			return 1
+		}
+		if *jsonOutput {
+			report.WriteJSON(os.Stdout)
+		} else {
+			report.WriteText(os.Stdout)
+		}
+		if report.Replace {
+			return 2
+		}
+		return 0
+	}
 
 	//if err := reader.PCD_HardReset(); err != nil {
 	//	log.Fatal(err.Error())
@@ -208,7 +247,40 @@ func run() int {
 
 }
 
+/**
+ * The reader of the -config file, the wiring constants without it.
+ */
+func open() (*mfrc522.MFRC522, io.Closer, error) {
+	if *configPath != "" {
+		config, err := mfrc522.LoadConfig(*configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return config.Open()
+	}
+
+	// Use spireg SPI port registry to find the first available SPI bus.
+	spiPort, err := spireg.Open("")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rstPin := gpioreg.ByName(RST)
+	irqPin := gpioreg.ByName(IRQ)
+
+	mfrc522dev, err := mfrc522.New(mfrc522.SPIPort(spiPort),
+		mfrc522.WithResetPin(rstPin),
+		mfrc522.WithIRQPin(irqPin),
+		mfrc522.WithLogger(mfrc522.NewStdLogger(log.New(os.Stderr, "", log.LstdFlags), mfrc522.LOG_DEBUG)))
+	if err != nil {
+		spiPort.Close()
+		return nil, nil, err
+	}
+	return mfrc522dev, spiPort, nil
+}
+
 func main() {
+	flag.Parse()
 	os.Exit(run())
 
 	/*val := uint16(0x0145)